@@ -0,0 +1,94 @@
+// Package diffcmd provides the semantic diff command for CLI.
+package diffcmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/pubgo/redant"
+	"google.golang.org/protobuf/reflect/protoreflect"
+
+	"github.com/pubgo/protobuild/internal/typex"
+)
+
+// ProjectConfig holds project configuration from protobuf.yaml.
+type ProjectConfig struct {
+	// Root directories containing proto files
+	Root []string
+	// Vendor directory
+	Vendor string
+	// Include paths
+	Includes []string
+}
+
+// New creates a new diff command.
+// The configProvider function is called to get project configuration.
+func New(name string, configProvider func() *ProjectConfig) *redant.Command {
+	var format = "text"
+
+	return &redant.Command{
+		Use:   name,
+		Short: "Show API changes between two proto trees",
+		Long: `Compare two proto trees at the descriptor level and print the added, removed
+and changed packages, messages, fields, enums, services and RPCs. Formatting and
+comment-only changes are ignored.
+
+Each side can be a directory of proto files, a descriptor set file (binary, or
+JSON with a .json extension) or a git ref. For git refs the root directories of
+protobuf.yaml are compared. When only <old> is given, it is compared with the
+working tree.
+
+Examples:
+  # Changes since main, for API review in a PR
+  protobuild diff origin/main --format markdown
+
+  # Compare two directories
+  protobuild diff old/proto proto
+
+  # Compare descriptor sets as JSON
+  protobuild diff v1.binpb v2.binpb --format json`,
+		Options: typex.Options{
+			redant.Option{
+				Flag:        "format",
+				Shorthand:   "f",
+				Description: "Output format: text, json or markdown",
+				Default:     "text",
+				Value:       redant.StringOf(&format),
+			},
+		},
+		Handler: func(ctx context.Context, inv *redant.Invocation) error {
+			if len(inv.Args) == 0 || len(inv.Args) > 2 {
+				return fmt.Errorf("usage: %s <old> [new]", name)
+			}
+
+			var cfg *ProjectConfig
+			if configProvider != nil {
+				cfg = configProvider()
+			}
+			if cfg == nil {
+				cfg = &ProjectConfig{}
+			}
+			if len(cfg.Root) == 0 {
+				cfg.Root = []string{"proto"}
+			}
+
+			oldFiles, err := loadSource(ctx, inv.Args[0], cfg)
+			if err != nil {
+				return err
+			}
+
+			var newFiles []protoreflect.FileDescriptor
+			if len(inv.Args) == 2 {
+				newFiles, err = loadSource(ctx, inv.Args[1], cfg)
+			} else {
+				newFiles, err = loadWorkTree(ctx, cfg)
+			}
+			if err != nil {
+				return err
+			}
+
+			return writeChanges(os.Stdout, format, Diff(oldFiles, newFiles))
+		},
+	}
+}
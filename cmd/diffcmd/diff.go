@@ -0,0 +1,266 @@
+package diffcmd
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// ChangeKind describes how an element changed.
+type ChangeKind string
+
+const (
+	Added   ChangeKind = "added"
+	Removed ChangeKind = "removed"
+	Changed ChangeKind = "changed"
+)
+
+// Change is a single semantic difference between two proto trees.
+type Change struct {
+	Kind ChangeKind `json:"kind"`
+	// Element is one of package, message, field, enum, enum_value, service,
+	// rpc or extension.
+	Element string `json:"element"`
+	// Name is the fully qualified name. Enum values are scoped by their enum.
+	Name    string   `json:"name"`
+	Details []string `json:"details,omitempty"`
+}
+
+// attr is a compared property of an element, e.g. the number of a field.
+type attr struct {
+	key, value string
+}
+
+type element struct {
+	kind  string
+	attrs []attr
+}
+
+// Diff compares the descriptors of two proto trees. Elements are matched by
+// fully qualified name, so moving declarations between files, reformatting
+// or editing comments produces no changes. Children of added or removed
+// elements are not reported separately.
+func Diff(oldFiles, newFiles []protoreflect.FileDescriptor) []Change {
+	oldIndex, newIndex := index(oldFiles), index(newFiles)
+
+	var changes []Change
+	for name, oldElem := range oldIndex {
+		newElem, ok := newIndex[name]
+		if !ok {
+			changes = append(changes, Change{Kind: Removed, Element: oldElem.kind, Name: name})
+			continue
+		}
+		if details := compare(oldElem, newElem); len(details) > 0 {
+			changes = append(changes, Change{Kind: Changed, Element: newElem.kind, Name: name, Details: details})
+		}
+	}
+	for name, newElem := range newIndex {
+		if _, ok := oldIndex[name]; !ok {
+			changes = append(changes, Change{Kind: Added, Element: newElem.kind, Name: name})
+		}
+	}
+
+	// Drop children of added or removed elements, their parent says it all.
+	kinds := make(map[string]ChangeKind, len(changes))
+	for _, c := range changes {
+		kinds[c.Name] = c.Kind
+	}
+	changes = filterChanges(changes, func(c Change) bool {
+		if c.Kind == Changed {
+			return true
+		}
+		for parent := parentName(c.Name); parent != ""; parent = parentName(parent) {
+			if kinds[parent] == c.Kind {
+				return false
+			}
+		}
+		return true
+	})
+
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Name < changes[j].Name
+	})
+	return changes
+}
+
+func filterChanges(changes []Change, keep func(Change) bool) []Change {
+	kept := changes[:0]
+	for _, c := range changes {
+		if keep(c) {
+			kept = append(kept, c)
+		}
+	}
+	return kept
+}
+
+func parentName(name string) string {
+	if i := strings.LastIndexByte(name, '.'); i >= 0 {
+		return name[:i]
+	}
+	return ""
+}
+
+func compare(oldElem, newElem element) []string {
+	if oldElem.kind != newElem.kind {
+		return []string{fmt.Sprintf("kind: %s -> %s", oldElem.kind, newElem.kind)}
+	}
+
+	oldAttrs := make(map[string]string, len(oldElem.attrs))
+	for _, a := range oldElem.attrs {
+		oldAttrs[a.key] = a.value
+	}
+	newAttrs := make(map[string]string, len(newElem.attrs))
+	for _, a := range newElem.attrs {
+		newAttrs[a.key] = a.value
+	}
+
+	var details []string
+	for _, a := range newElem.attrs {
+		if oldValue := oldAttrs[a.key]; oldValue != a.value {
+			details = append(details, fmt.Sprintf("%s: %s -> %s", a.key, displayValue(oldValue), displayValue(a.value)))
+		}
+	}
+	for _, a := range oldElem.attrs {
+		if _, ok := newAttrs[a.key]; !ok && a.value != "" {
+			details = append(details, fmt.Sprintf("%s: %s -> %s", a.key, displayValue(a.value), displayValue("")))
+		}
+	}
+	return details
+}
+
+func displayValue(v string) string {
+	if v == "" {
+		return "(none)"
+	}
+	return v
+}
+
+// index flattens the files into elements keyed by fully qualified name.
+func index(files []protoreflect.FileDescriptor) map[string]element {
+	elems := make(map[string]element)
+	for _, fd := range files {
+		if pkg := string(fd.Package()); pkg != "" {
+			elems[pkg] = element{kind: "package"}
+		}
+		indexMessages(elems, fd.Messages())
+		indexEnums(elems, fd.Enums())
+		indexExtensions(elems, fd.Extensions())
+		for i := 0; i < fd.Services().Len(); i++ {
+			sd := fd.Services().Get(i)
+			elems[string(sd.FullName())] = element{kind: "service", attrs: deprecated(sd)}
+			for j := 0; j < sd.Methods().Len(); j++ {
+				md := sd.Methods().Get(j)
+				elems[string(md.FullName())] = element{kind: "rpc", attrs: append([]attr{
+					{"input", streamType(md.IsStreamingClient(), md.Input())},
+					{"output", streamType(md.IsStreamingServer(), md.Output())},
+				}, deprecated(md)...)}
+			}
+		}
+	}
+	return elems
+}
+
+func indexMessages(elems map[string]element, messages protoreflect.MessageDescriptors) {
+	for i := 0; i < messages.Len(); i++ {
+		md := messages.Get(i)
+		if md.IsMapEntry() {
+			continue
+		}
+		elems[string(md.FullName())] = element{kind: "message", attrs: deprecated(md)}
+		for j := 0; j < md.Fields().Len(); j++ {
+			elems[string(md.Fields().Get(j).FullName())] = element{kind: "field", attrs: fieldAttrs(md.Fields().Get(j))}
+		}
+		indexMessages(elems, md.Messages())
+		indexEnums(elems, md.Enums())
+		indexExtensions(elems, md.Extensions())
+	}
+}
+
+func indexEnums(elems map[string]element, enums protoreflect.EnumDescriptors) {
+	for i := 0; i < enums.Len(); i++ {
+		ed := enums.Get(i)
+		elems[string(ed.FullName())] = element{kind: "enum", attrs: deprecated(ed)}
+		for j := 0; j < ed.Values().Len(); j++ {
+			vd := ed.Values().Get(j)
+			// Enum values are siblings of their enum in protobuf scoping,
+			// nest them under the enum so they read naturally.
+			name := string(ed.FullName()) + "." + string(vd.Name())
+			elems[name] = element{kind: "enum_value", attrs: append([]attr{
+				{"number", fmt.Sprint(vd.Number())},
+			}, deprecated(vd)...)}
+		}
+	}
+}
+
+func indexExtensions(elems map[string]element, extensions protoreflect.ExtensionDescriptors) {
+	for i := 0; i < extensions.Len(); i++ {
+		xd := extensions.Get(i)
+		elems[string(xd.FullName())] = element{kind: "extension", attrs: append([]attr{
+			{"extendee", string(xd.ContainingMessage().FullName())},
+		}, fieldAttrs(xd)...)}
+	}
+}
+
+func fieldAttrs(fd protoreflect.FieldDescriptor) []attr {
+	attrs := []attr{
+		{"number", fmt.Sprint(fd.Number())},
+		{"type", fieldType(fd)},
+		{"label", fieldLabel(fd)},
+	}
+	if od := fd.ContainingOneof(); od != nil && !od.IsSynthetic() {
+		attrs = append(attrs, attr{"oneof", string(od.Name())})
+	}
+	if fd.HasDefault() {
+		attrs = append(attrs, attr{"default", fmt.Sprint(fd.Default().Interface())})
+	}
+	return append(attrs, deprecated(fd)...)
+}
+
+func fieldType(fd protoreflect.FieldDescriptor) string {
+	if fd.IsMap() {
+		return fmt.Sprintf("map<%s, %s>", fieldType(fd.MapKey()), fieldType(fd.MapValue()))
+	}
+	switch fd.Kind() {
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		return string(fd.Message().FullName())
+	case protoreflect.EnumKind:
+		return string(fd.Enum().FullName())
+	default:
+		return fd.Kind().String()
+	}
+}
+
+func fieldLabel(fd protoreflect.FieldDescriptor) string {
+	switch {
+	case fd.IsMap():
+		return ""
+	case fd.Cardinality() == protoreflect.Repeated:
+		return "repeated"
+	case fd.Cardinality() == protoreflect.Required:
+		return "required"
+	case fd.HasOptionalKeyword():
+		return "optional"
+	}
+	return ""
+}
+
+func streamType(stream bool, md protoreflect.MessageDescriptor) string {
+	if stream {
+		return "stream " + string(md.FullName())
+	}
+	return string(md.FullName())
+}
+
+// deprecated returns the deprecated attribute of d, the only option compared
+// because it is part of the API contract.
+func deprecated(d protoreflect.Descriptor) []attr {
+	type deprecatable interface {
+		GetDeprecated() bool
+	}
+	if opts, ok := d.Options().(deprecatable); ok && opts.GetDeprecated() {
+		return []attr{{"deprecated", "true"}}
+	}
+	return nil
+}
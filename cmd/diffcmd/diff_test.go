@@ -0,0 +1,150 @@
+package diffcmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const oldProto = `syntax = "proto3";
+
+package acme.v1;
+
+message Book {
+  string name = 1;
+  string title = 2;
+  int32 pages = 3;
+}
+
+message Author {
+  string name = 1;
+}
+
+enum Color {
+  COLOR_UNSPECIFIED = 0;
+  RED = 1;
+}
+
+service Library {
+  rpc GetBook(Book) returns (Book);
+}
+`
+
+// newProto reformats and re-comments oldProto, which must not be reported.
+const newProto = `syntax = "proto3";
+package acme.v1;
+
+// Book is a book.
+message Book {
+	string name = 1; // the name
+	bytes title = 2;
+	int32 pages = 4 [deprecated = true];
+	repeated string tags = 5;
+}
+
+enum Color {
+  COLOR_UNSPECIFIED = 0;
+  RED = 2;
+  BLUE = 3;
+}
+
+service Library {
+	rpc GetBook(Book) returns (stream Book);
+	rpc ListBooks(Book) returns (Book);
+}
+
+message Shelf {
+	string name = 1;
+}
+`
+
+func writeProto(t *testing.T, dir, content string) {
+	t.Helper()
+	path := filepath.Join(dir, "acme", "v1", "library.proto")
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDiff(t *testing.T) {
+	tmpDir := t.TempDir()
+	oldDir, newDir := filepath.Join(tmpDir, "old"), filepath.Join(tmpDir, "new")
+	writeProto(t, oldDir, oldProto)
+	writeProto(t, newDir, newProto)
+
+	cfg := &ProjectConfig{}
+	oldFiles, err := loadSource(context.Background(), oldDir, cfg)
+	if err != nil {
+		t.Fatalf("loadSource() error = %v", err)
+	}
+	newFiles, err := loadSource(context.Background(), newDir, cfg)
+	if err != nil {
+		t.Fatalf("loadSource() error = %v", err)
+	}
+
+	var got []string
+	for _, c := range Diff(oldFiles, newFiles) {
+		got = append(got, string(c.Kind)+" "+c.Element+" "+c.Name+" "+strings.Join(c.Details, "; "))
+	}
+	expected := []string{
+		"removed message acme.v1.Author ",
+		"changed field acme.v1.Book.pages number: 3 -> 4; deprecated: (none) -> true",
+		"added field acme.v1.Book.tags ",
+		"changed field acme.v1.Book.title type: string -> bytes",
+		"added enum_value acme.v1.Color.BLUE ",
+		"changed enum_value acme.v1.Color.RED number: 1 -> 2",
+		"changed rpc acme.v1.Library.GetBook output: acme.v1.Book -> stream acme.v1.Book",
+		"added rpc acme.v1.Library.ListBooks ",
+		"added message acme.v1.Shelf ",
+	}
+	if strings.Join(got, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("unexpected changes:\n%s", strings.Join(got, "\n"))
+	}
+
+	if changes := Diff(oldFiles, oldFiles); len(changes) != 0 {
+		t.Errorf("expected no changes, got %v", changes)
+	}
+}
+
+func TestWriteChanges(t *testing.T) {
+	changes := []Change{
+		{Kind: Added, Element: "message", Name: "acme.v1.Shelf"},
+		{Kind: Changed, Element: "field", Name: "acme.v1.Book.title", Details: []string{"type: string -> bytes"}},
+	}
+
+	var text bytes.Buffer
+	if err := writeChanges(&text, "text", changes); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(text.String(), "  ~ field acme.v1.Book.title (type: string -> bytes)") {
+		t.Errorf("unexpected text output:\n%s", text.String())
+	}
+
+	var markdown bytes.Buffer
+	if err := writeChanges(&markdown, "markdown", changes); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(markdown.String(), "| ➕ added | message | `acme.v1.Shelf` |  |") {
+		t.Errorf("unexpected markdown output:\n%s", markdown.String())
+	}
+
+	var out bytes.Buffer
+	if err := writeChanges(&out, "json", nil); err != nil {
+		t.Fatal(err)
+	}
+	var decoded []Change
+	if err := json.Unmarshal(out.Bytes(), &decoded); err != nil || decoded == nil {
+		t.Errorf("unexpected json output: %s", out.String())
+	}
+
+	if err := writeChanges(&out, "yaml", changes); err == nil {
+		t.Error("expected error for unsupported format")
+	}
+}
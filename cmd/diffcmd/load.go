@@ -0,0 +1,171 @@
+package diffcmd
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/bufbuild/protocompile"
+	"github.com/pubgo/funk/v2/errors"
+	"github.com/samber/lo"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// loadSource loads the descriptors of one side of the diff. The spec is a
+// directory of proto files, a descriptor set file (binary, or JSON with a
+// .json extension) or a git ref whose project roots are compiled.
+func loadSource(ctx context.Context, spec string, cfg *ProjectConfig) ([]protoreflect.FileDescriptor, error) {
+	if info, err := os.Stat(spec); err == nil {
+		if info.IsDir() {
+			return compileRoots(ctx, []string{spec}, cfg)
+		}
+		return loadDescriptorSet(spec)
+	}
+
+	if !isGitRef(spec) {
+		return nil, fmt.Errorf("%s is not a directory, descriptor set or git ref", spec)
+	}
+
+	tmpDir, err := os.MkdirTemp("", "protobuild-diff-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	roots, err := checkoutRoots(spec, cfg.Root, tmpDir)
+	if err != nil {
+		return nil, err
+	}
+	return compileRoots(ctx, roots, cfg)
+}
+
+// loadWorkTree loads the project roots of the working tree.
+func loadWorkTree(ctx context.Context, cfg *ProjectConfig) ([]protoreflect.FileDescriptor, error) {
+	return compileRoots(ctx, cfg.Root, cfg)
+}
+
+// compileRoots compiles all proto files under roots. Imports are resolved
+// from the roots first, then the project includes and vendor directory.
+func compileRoots(ctx context.Context, roots []string, cfg *ProjectConfig) ([]protoreflect.FileDescriptor, error) {
+	var names []string
+	for _, root := range roots {
+		err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() || !strings.HasSuffix(path, ".proto") {
+				return nil
+			}
+			rel, err := filepath.Rel(root, path)
+			if err != nil {
+				return err
+			}
+			names = append(names, filepath.ToSlash(rel))
+			return nil
+		})
+		if err != nil {
+			return nil, errors.Wrapf(err, "walk %s failed", root)
+		}
+	}
+	names = lo.Uniq(names)
+
+	importPaths := append(append(append([]string{}, roots...), cfg.Includes...), cfg.Vendor)
+	importPaths = lo.Uniq(lo.Compact(importPaths))
+
+	compiler := protocompile.Compiler{
+		Resolver: protocompile.WithStandardImports(&protocompile.SourceResolver{ImportPaths: importPaths}),
+	}
+	files, err := compiler.Compile(ctx, names...)
+	if err != nil {
+		return nil, errors.Wrapf(err, "compile %s failed", strings.Join(roots, ", "))
+	}
+
+	result := make([]protoreflect.FileDescriptor, 0, len(files))
+	for _, file := range files {
+		result = append(result, file)
+	}
+	return result, nil
+}
+
+// loadDescriptorSet reads a FileDescriptorSet. Files whose dependencies are
+// missing from the set are still loaded with placeholder types.
+func loadDescriptorSet(path string) ([]protoreflect.FileDescriptor, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var set descriptorpb.FileDescriptorSet
+	if filepath.Ext(path) == ".json" {
+		err = protojson.Unmarshal(data, &set)
+	} else {
+		err = proto.Unmarshal(data, &set)
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "decode descriptor set %s failed", path)
+	}
+
+	registry, err := protodesc.FileOptions{AllowUnresolvable: true}.NewFiles(&set)
+	if err != nil {
+		return nil, errors.Wrapf(err, "load descriptor set %s failed", path)
+	}
+
+	result := make([]protoreflect.FileDescriptor, 0, len(set.File))
+	for _, file := range set.File {
+		fd, err := registry.FindFileByPath(file.GetName())
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, fd)
+	}
+	return result, nil
+}
+
+func isGitRef(ref string) bool {
+	return exec.Command("git", "rev-parse", "--verify", "--quiet", ref+"^{commit}").Run() == nil
+}
+
+// checkoutRoots writes the proto files of roots at ref into dir and returns
+// the corresponding directories.
+func checkoutRoots(ref string, roots []string, dir string) ([]string, error) {
+	args := append([]string{"ls-tree", "-r", "--name-only", ref, "--"}, roots...)
+	out, err := exec.Command("git", args...).Output()
+	if err != nil {
+		return nil, errors.Wrapf(err, "list files at %s failed", ref)
+	}
+
+	for _, path := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		if !strings.HasSuffix(path, ".proto") {
+			continue
+		}
+		content, err := exec.Command("git", "show", ref+":./"+path).Output()
+		if err != nil {
+			return nil, errors.Wrapf(err, "read %s at %s failed", path, ref)
+		}
+		target := filepath.Join(dir, filepath.FromSlash(path))
+		if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+			return nil, err
+		}
+		if err := os.WriteFile(target, content, 0o644); err != nil {
+			return nil, err
+		}
+	}
+
+	result := make([]string, 0, len(roots))
+	for _, root := range roots {
+		target := filepath.Join(dir, root)
+		if err := os.MkdirAll(target, 0o755); err != nil {
+			return nil, err
+		}
+		result = append(result, target)
+	}
+	return result, nil
+}
@@ -0,0 +1,83 @@
+package diffcmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+var changeSymbols = map[ChangeKind]string{
+	Added:   "+",
+	Removed: "-",
+	Changed: "~",
+}
+
+var changeEmojis = map[ChangeKind]string{
+	Added:   "➕",
+	Removed: "➖",
+	Changed: "✏️",
+}
+
+// writeChanges writes the changes in the given format: text, json or markdown.
+func writeChanges(w io.Writer, format string, changes []Change) error {
+	switch format {
+	case "", "text":
+		return writeText(w, changes)
+	case "json":
+		if changes == nil {
+			changes = []Change{}
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(changes)
+	case "markdown", "md":
+		return writeMarkdown(w, changes)
+	default:
+		return fmt.Errorf("unsupported format %q, expected text, json or markdown", format)
+	}
+}
+
+func writeText(w io.Writer, changes []Change) error {
+	if len(changes) == 0 {
+		_, err := fmt.Fprintln(w, "✅ No API changes")
+		return err
+	}
+
+	fmt.Fprintf(w, "📝 %d API changes:\n", len(changes))
+	for _, c := range changes {
+		line := fmt.Sprintf("  %s %s %s", changeSymbols[c.Kind], c.Element, c.Name)
+		if len(c.Details) > 0 {
+			line += " (" + strings.Join(c.Details, "; ") + ")"
+		}
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeMarkdown(w io.Writer, changes []Change) error {
+	var b strings.Builder
+	b.WriteString("## API changes\n\n")
+	if len(changes) == 0 {
+		b.WriteString("No API changes.\n")
+		_, err := io.WriteString(w, b.String())
+		return err
+	}
+
+	counts := make(map[ChangeKind]int)
+	for _, c := range changes {
+		counts[c.Kind]++
+	}
+	fmt.Fprintf(&b, "%d added, %d removed, %d changed.\n\n", counts[Added], counts[Removed], counts[Changed])
+	b.WriteString("| Change | Element | Name | Details |\n")
+	b.WriteString("| --- | --- | --- | --- |\n")
+	for _, c := range changes {
+		details := strings.Join(c.Details, "<br>")
+		details = strings.ReplaceAll(details, "|", "\\|")
+		fmt.Fprintf(&b, "| %s %s | %s | `%s` | %s |\n", changeEmojis[c.Kind], c.Kind, c.Element, c.Name, details)
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
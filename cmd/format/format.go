@@ -12,9 +12,30 @@ import (
 // Format formats and writes the target module files into a read bucket.
 func Format(path string) {
 	data := assert.Must1(os.ReadFile(path))
-	fileNode := assert.Must1(parser.Parse(path, bytes.NewBuffer(data), reporter.NewHandler(nil)))
+	formatted := assert.Must1(Source(path, data))
+	assert.Must(os.WriteFile(path, formatted, 0o644))
+}
+
+// Source formats the given proto source with the default options and returns
+// the formatted content. The path is only used for error reporting.
+func Source(path string, data []byte) ([]byte, error) {
+	return SourceWithOptions(path, data, DefaultOptions())
+}
+
+// SourceWithOptions formats the given proto source with opts.
+func SourceWithOptions(path string, data []byte, opts Options) ([]byte, error) {
+	fileNode, err := parser.Parse(path, bytes.NewReader(data), reporter.NewHandler(nil))
+	if err != nil {
+		return nil, err
+	}
 
 	var buf bytes.Buffer
-	assert.Must(newFormatter(&buf, fileNode).Run())
-	assert.Must(os.WriteFile(path, buf.Bytes(), 0o644))
+	f := newFormatter(&buf, fileNode, opts)
+	if opts.RemoveUnusedImports {
+		f.unusedImports = unusedImports(path, data, opts)
+	}
+	if err := f.Run(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
 }
@@ -0,0 +1,221 @@
+package format
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const optionsTestProto = `syntax = "proto3";
+
+package acme.v1;
+
+import "google/protobuf/timestamp.proto";
+import "acme/type/money.proto";
+
+message Book {
+  string name = 1 [deprecated = true, json_name = "bookName"];
+  string title = 2 [deprecated = true];
+}
+`
+
+func TestSourceWithOptions(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     Options
+		contains []string
+	}{
+		{
+			name: "defaults",
+			opts: DefaultOptions(),
+			contains: []string{
+				"import \"acme/type/money.proto\";\nimport \"google/protobuf/timestamp.proto\";",
+				"  string name = 1 [\n    deprecated = true,\n    json_name = \"bookName\"\n  ];",
+				"  string title = 2 [deprecated = true];",
+			},
+		},
+		{
+			name: "indent width",
+			opts: Options{IndentWidth: 4, CompactOptionsThreshold: 1},
+			contains: []string{
+				"    string name = 1 [\n        deprecated = true,",
+			},
+		},
+		{
+			name: "compact options threshold",
+			opts: Options{IndentWidth: 2, CompactOptionsThreshold: 2},
+			contains: []string{
+				"  string name = 1 [deprecated = true, json_name = \"bookName\"];",
+			},
+		},
+		{
+			name: "max line length wraps options",
+			opts: Options{IndentWidth: 2, CompactOptionsThreshold: 2, MaxLineLength: 40},
+			contains: []string{
+				"  string name = 1 [\n    deprecated = true,",
+				"  string title = 2 [deprecated = true];",
+			},
+		},
+		{
+			name: "max line length wraps single option",
+			opts: Options{IndentWidth: 2, MaxLineLength: 30},
+			contains: []string{
+				"  string title = 2 [\n    deprecated = true\n  ];",
+			},
+		},
+		{
+			name: "keep import order",
+			opts: Options{SortImports: false},
+			contains: []string{
+				"import \"google/protobuf/timestamp.proto\";\nimport \"acme/type/money.proto\";",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := SourceWithOptions("book.proto", []byte(optionsTestProto), tt.opts)
+			if err != nil {
+				t.Fatalf("SourceWithOptions() error = %v", err)
+			}
+			for _, want := range tt.contains {
+				if !strings.Contains(string(out), want) {
+					t.Errorf("output does not contain %q:\n%s", want, out)
+				}
+			}
+		})
+	}
+}
+
+const importsTestProto = `syntax = "proto3";
+
+package acme.library.v1;
+
+import "acme/library/v1/shelf.proto";
+import "google/protobuf/timestamp.proto";
+import "acme/type/money.proto";
+import "acme/type/color.proto";
+import "google/protobuf/duration.proto";
+
+message Book {
+  acme.type.Money price = 1;
+  Shelf shelf = 2;
+  google.protobuf.Duration read_time = 3;
+}
+`
+
+func TestSourceWithOptions_Imports(t *testing.T) {
+	tmpDir := t.TempDir()
+	protoDir := filepath.Join(tmpDir, "proto")
+	vendorDir := filepath.Join(tmpDir, ".proto")
+	files := map[string]string{
+		filepath.Join(protoDir, "acme/library/v1/shelf.proto"): "syntax = \"proto3\";\npackage acme.library.v1;\nmessage Shelf {}\n",
+		filepath.Join(vendorDir, "acme/type/money.proto"):      "syntax = \"proto3\";\npackage acme.type;\nmessage Money {}\n",
+		filepath.Join(vendorDir, "acme/type/color.proto"):      "syntax = \"proto3\";\npackage acme.type;\nmessage Color {}\n",
+	}
+	for path, content := range files {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	bookPath := filepath.Join(protoDir, "acme/library/v1/book.proto")
+
+	tests := []struct {
+		name string
+		opts Options
+		want string
+	}{
+		{
+			name: "group",
+			opts: Options{SortImports: true, GroupImports: true},
+			want: `import "google/protobuf/duration.proto";
+import "google/protobuf/timestamp.proto";
+
+import "acme/type/color.proto";
+import "acme/type/money.proto";
+
+import "acme/library/v1/shelf.proto";
+`,
+		},
+		{
+			name: "group and remove unused",
+			opts: Options{SortImports: true, GroupImports: true, RemoveUnusedImports: true},
+			want: `import "google/protobuf/duration.proto";
+
+import "acme/type/money.proto";
+
+import "acme/library/v1/shelf.proto";
+`,
+		},
+		{
+			name: "remove unused keeps order",
+			opts: Options{RemoveUnusedImports: true},
+			want: `import "acme/library/v1/shelf.proto";
+import "acme/type/money.proto";
+import "google/protobuf/duration.proto";
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := tt.opts
+			opts.Vendor = vendorDir
+			opts.ImportPaths = []string{protoDir, vendorDir}
+			out, err := SourceWithOptions(bookPath, []byte(importsTestProto), opts)
+			if err != nil {
+				t.Fatalf("SourceWithOptions() error = %v", err)
+			}
+			if !strings.Contains(string(out), "package acme.library.v1;\n\n"+tt.want+"\nmessage Book") {
+				t.Errorf("unexpected imports:\n%s", out)
+			}
+		})
+	}
+
+	t.Run("unresolved imports are kept", func(t *testing.T) {
+		out, err := SourceWithOptions("book.proto", []byte(importsTestProto), Options{RemoveUnusedImports: true})
+		if err != nil {
+			t.Fatalf("SourceWithOptions() error = %v", err)
+		}
+		if !strings.Contains(string(out), `import "acme/type/color.proto";`) {
+			t.Errorf("expected imports to be kept when the file does not compile:\n%s", out)
+		}
+	})
+}
+
+const rangeTestProto = `syntax = "proto3";
+
+package acme.v1;
+
+message A {
+	string a = 1;
+}
+
+// B is formatted.
+message B {
+	string b = 1;   // trailing
+}
+
+message C {
+	string c = 1;
+}
+`
+
+func TestSourceRange(t *testing.T) {
+	out, err := SourceRange("range.proto", []byte(rangeTestProto), DefaultOptions(), 11, 11)
+	if err != nil {
+		t.Fatalf("SourceRange() error = %v", err)
+	}
+	want := strings.Replace(rangeTestProto, "\tstring b = 1;   // trailing", "  string b = 1; // trailing", 1)
+	if string(out) != want {
+		t.Errorf("unexpected output:\n%s", out)
+	}
+
+	if _, err := SourceRange("range.proto", []byte(rangeTestProto), DefaultOptions(), 5, 2); err == nil {
+		t.Error("expected error for invalid range")
+	}
+}
@@ -17,11 +17,17 @@ import (
 type formatter struct {
 	writer   io.Writer
 	fileNode *ast.FileNode
+	opts     Options
+
+	// Imports removed from the output, see Options.RemoveUnusedImports.
+	unusedImports map[string]bool
 
 	// Current level of indentation.
 	indent int
 	// The last character written to writer.
 	lastWritten rune
+	// The number of runes written on the current line.
+	column int
 
 	// The last node written. This must be updated from all functions
 	// that write comments with a node. This flag informs how the next
@@ -53,10 +59,11 @@ type formatter struct {
 }
 
 // newFormatter returns a new formatter for the given file.
-func newFormatter(writer io.Writer, fileNode *ast.FileNode) *formatter {
+func newFormatter(writer io.Writer, fileNode *ast.FileNode, opts Options) *formatter {
 	return &formatter{
 		writer:   writer,
 		fileNode: fileNode,
+		opts:     opts.withDefaults(),
 	}
 }
 
@@ -126,7 +133,7 @@ func (f *formatter) Indent(nextNode ast.Node) {
 			indent--
 		}
 	}
-	f.WriteString(strings.Repeat("  ", indent))
+	f.WriteString(strings.Repeat(" ", indent*f.opts.IndentWidth))
 }
 
 // WriteString writes the given element to the generated output.
@@ -160,12 +167,18 @@ func (f *formatter) WriteString(elem string) {
 				f.err = multierr.Append(f.err, err)
 				return
 			}
+			f.column++
 		}
 	}
 	if len(elem) == 0 {
 		return
 	}
 	f.lastWritten, _ = utf8.DecodeLastRuneInString(elem)
+	if i := strings.LastIndexByte(elem, '\n'); i >= 0 {
+		f.column = utf8.RuneCountInString(elem[i+1:])
+	} else {
+		f.column += utf8.RuneCountInString(elem)
+	}
 	if _, err := f.writer.Write([]byte(elem)); err != nil {
 		f.err = multierr.Append(f.err, err)
 	}
@@ -218,6 +231,9 @@ func (f *formatter) writeFileHeader() {
 		case *ast.PackageNode:
 			packageNode = node
 		case *ast.ImportNode:
+			if f.unusedImports[node.Name.AsString()] {
+				continue
+			}
 			importNodes = append(importNodes, node)
 		case *ast.OptionNode:
 			optionNodes = append(optionNodes, node)
@@ -235,13 +251,28 @@ func (f *formatter) writeFileHeader() {
 	if packageNode != nil {
 		f.writePackage(packageNode)
 	}
-	sort.Slice(importNodes, func(i, j int) bool {
-		return importNodes[i].Name.AsString() < importNodes[j].Name.AsString()
-	})
+	if f.opts.SortImports {
+		sort.SliceStable(importNodes, func(i, j int) bool {
+			return importNodes[i].Name.AsString() < importNodes[j].Name.AsString()
+		})
+	}
+	groups := make(map[string]importGroup, len(importNodes))
+	if f.opts.GroupImports {
+		for _, importNode := range importNodes {
+			groups[importNode.Name.AsString()] = f.opts.importGroup(importNode.Name.AsString())
+		}
+		sort.SliceStable(importNodes, func(i, j int) bool {
+			return groups[importNodes[i].Name.AsString()] < groups[importNodes[j].Name.AsString()]
+		})
+	}
 	for i, importNode := range importNodes {
 		if i == 0 && f.previousNode != nil && !f.leadingCommentsContainBlankLine(importNode) {
 			f.P("")
 		}
+		if i > 0 && groups[importNode.Name.AsString()] != groups[importNodes[i-1].Name.AsString()] {
+			// Groups are separated by a single blank line.
+			f.P("")
+		}
 		f.writeImport(importNode, i > 0)
 	}
 	sort.Slice(optionNodes, func(i, j int) bool {
@@ -1077,8 +1108,30 @@ func (f *formatter) writeCompactOptions(compactOptionsNode *ast.CompactOptionsNo
 	defer func() {
 		f.inCompactOptions = false
 	}()
+	if f.fitsCompactOptionsInline(compactOptionsNode) && len(compactOptionsNode.Options) > 1 {
+		// Up to the configured threshold, simple options without comments
+		// are written on a single line. For example:
+		//
+		//  [deprecated = true, json_name = "foo"]
+		//
+		f.writeInline(compactOptionsNode.OpenBracket)
+		for i, opt := range compactOptionsNode.Options {
+			if i > 0 {
+				f.writeInline(compactOptionsNode.Commas[i-1])
+				f.Space()
+			}
+			f.writeInline(opt.Name)
+			f.Space()
+			f.writeInline(opt.Equals)
+			f.Space()
+			f.writeInline(opt.Val)
+		}
+		f.writeInline(compactOptionsNode.CloseBracket)
+		return
+	}
 	if len(compactOptionsNode.Options) == 1 &&
-		!f.hasInteriorComments(compactOptionsNode.OpenBracket, compactOptionsNode.Options[0].Name) {
+		!f.hasInteriorComments(compactOptionsNode.OpenBracket, compactOptionsNode.Options[0].Name) &&
+		f.fitsLine(compactOptionsNode) {
 		// If there's only a single compact scalar option without comments, we can write it
 		// in-line. For example:
 		//
@@ -1131,6 +1184,48 @@ func (f *formatter) writeCompactOptions(compactOptionsNode *ast.CompactOptionsNo
 	)
 }
 
+// fitsCompactOptionsInline reports whether the compact options are few and
+// simple enough to be written on one line: within the configured threshold,
+// without comments, with scalar values only, and within the line length.
+func (f *formatter) fitsCompactOptionsInline(compactOptionsNode *ast.CompactOptionsNode) bool {
+	if len(compactOptionsNode.Options) > f.opts.CompactOptionsThreshold {
+		return false
+	}
+	if f.hasInteriorComments(compactOptionsNode.Children()...) {
+		return false
+	}
+	for _, opt := range compactOptionsNode.Options {
+		switch val := opt.Val.(type) {
+		case *ast.MessageLiteralNode, *ast.ArrayLiteralNode:
+			return false
+		case *ast.CompoundStringLiteralNode:
+			if len(val.Children()) > 1 {
+				return false
+			}
+		}
+	}
+	return f.fitsLine(compactOptionsNode)
+}
+
+// fitsLine reports whether the compact options, written on one line and
+// followed by a ';', fit within the maximum line length.
+func (f *formatter) fitsLine(compactOptionsNode *ast.CompactOptionsNode) bool {
+	if f.opts.MaxLineLength <= 0 {
+		return true
+	}
+	// "[" + options joined by ", " + "]" + ";", after a separating space.
+	width := f.column + 4 + 2*(len(compactOptionsNode.Options)-1)
+	for _, opt := range compactOptionsNode.Options {
+		name := strings.TrimSpace(f.fileNode.NodeInfo(opt.Name).RawText())
+		val := f.fileNode.NodeInfo(opt.Val).RawText()
+		if strings.ContainsRune(val, '\n') {
+			return true
+		}
+		width += utf8.RuneCountInString(name) + 3 + utf8.RuneCountInString(val)
+	}
+	return width <= f.opts.MaxLineLength
+}
+
 func (f *formatter) hasInteriorComments(nodes ...ast.Node) bool {
 	for i, n := range nodes {
 		// interior comments mean we ignore leading comments on first
@@ -0,0 +1,102 @@
+package format
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/bufbuild/protocompile"
+	"github.com/bufbuild/protocompile/linker"
+	"github.com/bufbuild/protocompile/reporter"
+)
+
+// importGroup is the group an import is written in when GroupImports is set.
+type importGroup int
+
+const (
+	importGroupWellKnown importGroup = iota
+	importGroupThirdParty
+	importGroupLocal
+)
+
+// importGroup classifies an import path. Imports found in the vendor
+// directory, or that cannot be resolved at all, are treated as third-party.
+func (o Options) importGroup(name string) importGroup {
+	if strings.HasPrefix(name, "google/protobuf/") {
+		return importGroupWellKnown
+	}
+	if o.Vendor != "" && fileExists(filepath.Join(o.Vendor, name)) {
+		return importGroupThirdParty
+	}
+	for _, dir := range o.ImportPaths {
+		if fileExists(filepath.Join(dir, name)) {
+			return importGroupLocal
+		}
+	}
+	return importGroupThirdParty
+}
+
+// unusedImports compiles the file and returns the imports the linker reports
+// as unused. Nothing is reported if the file does not compile, so a broken
+// include path never removes imports.
+func unusedImports(path string, data []byte, opts Options) map[string]bool {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil
+	}
+
+	var importPaths []string
+	for _, dir := range append(append([]string{}, opts.ImportPaths...), opts.Vendor) {
+		if dir == "" {
+			continue
+		}
+		if dir, err := filepath.Abs(dir); err == nil {
+			importPaths = append(importPaths, dir)
+		}
+	}
+
+	name := ""
+	for _, dir := range importPaths {
+		if rel, err := filepath.Rel(dir, abs); err == nil && !strings.HasPrefix(rel, "..") {
+			name = filepath.ToSlash(rel)
+			break
+		}
+	}
+	if name == "" {
+		// The file is outside the include paths, resolve it from its own directory.
+		name = filepath.Base(abs)
+		importPaths = append(importPaths, filepath.Dir(abs))
+	}
+
+	unused := make(map[string]bool)
+	compiler := protocompile.Compiler{
+		Resolver: protocompile.WithStandardImports(&protocompile.SourceResolver{
+			ImportPaths: importPaths,
+			Accessor: func(p string) (io.ReadCloser, error) {
+				if filepath.Clean(p) == abs {
+					return io.NopCloser(bytes.NewReader(data)), nil
+				}
+				return os.Open(p)
+			},
+		}),
+		Reporter: reporter.NewReporter(nil, func(err reporter.ErrorWithPos) {
+			var unusedErr linker.ErrorUnusedImport
+			if errors.As(err, &unusedErr) {
+				unused[unusedErr.UnusedImport()] = true
+			}
+		}),
+	}
+	if _, err := compiler.Compile(context.Background(), name); err != nil {
+		return nil
+	}
+	return unused
+}
+
+func fileExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && !info.IsDir()
+}
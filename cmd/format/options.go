@@ -0,0 +1,45 @@
+package format
+
+// Options configures the builtin formatter.
+type Options struct {
+	// IndentWidth is the number of spaces per indentation level.
+	IndentWidth int
+	// MaxLineLength wraps compact options across multiple lines when they
+	// would not fit. Zero disables wrapping by length.
+	MaxLineLength int
+	// CompactOptionsThreshold is the maximum number of compact options
+	// (e.g. [deprecated = true]) written on a single line.
+	CompactOptionsThreshold int
+	// SortImports sorts imports by path.
+	SortImports bool
+	// GroupImports separates imports into google/protobuf, vendored
+	// third-party and local project groups.
+	GroupImports bool
+	// RemoveUnusedImports drops imports that the compiled file does not use.
+	RemoveUnusedImports bool
+	// Vendor is the vendor directory, used to tell third-party imports apart
+	// from local ones.
+	Vendor string
+	// ImportPaths are the include paths used to resolve imports.
+	ImportPaths []string
+}
+
+// DefaultOptions returns the default formatter options, which match buf format.
+func DefaultOptions() Options {
+	return Options{
+		IndentWidth:             2,
+		CompactOptionsThreshold: 1,
+		SortImports:             true,
+	}
+}
+
+// withDefaults fills unset numeric options with their defaults.
+func (o Options) withDefaults() Options {
+	if o.IndentWidth <= 0 {
+		o.IndentWidth = 2
+	}
+	if o.CompactOptionsThreshold <= 0 {
+		o.CompactOptionsThreshold = 1
+	}
+	return o
+}
@@ -0,0 +1,126 @@
+package format
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/bufbuild/protocompile/ast"
+	"github.com/bufbuild/protocompile/parser"
+	"github.com/bufbuild/protocompile/reporter"
+)
+
+// SourceRange formats the top-level declarations of the given proto source
+// that overlap the 1-based, inclusive line span and leaves the rest of the
+// file untouched. The file header (syntax, package, imports and file options)
+// is formatted as a single declaration because the formatter reorders it.
+func SourceRange(path string, data []byte, opts Options, startLine, endLine int) ([]byte, error) {
+	if startLine < 1 || endLine < startLine {
+		return nil, fmt.Errorf("invalid line range %d:%d", startLine, endLine)
+	}
+
+	fileNode, err := parser.Parse(path, bytes.NewReader(data), reporter.NewHandler(nil))
+	if err != nil {
+		return nil, err
+	}
+	formatted, err := SourceWithOptions(path, data, opts)
+	if err != nil {
+		return nil, err
+	}
+	formattedNode, err := parser.Parse(path, bytes.NewReader(formatted), reporter.NewHandler(nil))
+	if err != nil {
+		return nil, err
+	}
+
+	original, err := declSpans(fileNode)
+	if err != nil {
+		return nil, err
+	}
+	replacement, err := declSpans(formattedNode)
+	if err != nil {
+		return nil, err
+	}
+	if len(original) != len(replacement) {
+		return nil, fmt.Errorf("%s: formatted declarations do not match the source", path)
+	}
+
+	var buf bytes.Buffer
+	last := 0
+	for i, span := range original {
+		if span.endLine < startLine || span.startLine > endLine {
+			continue
+		}
+		buf.Write(data[last:span.start])
+		buf.Write(formatted[replacement[i].start:replacement[i].end])
+		last = span.end
+	}
+	buf.Write(data[last:])
+	return buf.Bytes(), nil
+}
+
+// declSpan is the byte and line extent of a top-level declaration,
+// including its leading and trailing comments.
+type declSpan struct {
+	start, end         int
+	startLine, endLine int
+}
+
+// declSpans returns the spans of the file header followed by the other
+// top-level declarations in source order.
+func declSpans(fileNode *ast.FileNode) ([]declSpan, error) {
+	var (
+		header *declSpan
+		spans  []declSpan
+	)
+	addHeader := func(node ast.Node) error {
+		span := nodeSpan(fileNode, node)
+		if header == nil {
+			header = &span
+			return nil
+		}
+		if len(spans) > 0 {
+			return fmt.Errorf("%s:%d: range formatting requires imports and options before other declarations", fileNode.Name(), span.startLine)
+		}
+		header.end, header.endLine = span.end, span.endLine
+		return nil
+	}
+
+	if fileNode.Syntax != nil {
+		_ = addHeader(fileNode.Syntax)
+	}
+	if fileNode.Edition != nil {
+		_ = addHeader(fileNode.Edition)
+	}
+	for _, decl := range fileNode.Decls {
+		switch node := decl.(type) {
+		case *ast.PackageNode, *ast.ImportNode, *ast.OptionNode:
+			if err := addHeader(node); err != nil {
+				return nil, err
+			}
+		case *ast.EmptyDeclNode:
+			continue
+		default:
+			spans = append(spans, nodeSpan(fileNode, node))
+		}
+	}
+
+	if header != nil {
+		spans = append([]declSpan{*header}, spans...)
+	}
+	return spans, nil
+}
+
+func nodeSpan(fileNode *ast.FileNode, node ast.Node) declSpan {
+	info := fileNode.NodeInfo(node)
+	start, end := info.Start(), info.End()
+	span := declSpan{start: start.Offset, end: end.Offset, startLine: start.Line, endLine: end.Line}
+	if comments := info.LeadingComments(); comments.Len() > 0 {
+		first := comments.Index(0).Start()
+		span.start, span.startLine = first.Offset, first.Line
+	}
+	if comments := info.TrailingComments(); comments.Len() > 0 {
+		last := comments.Index(comments.Len() - 1)
+		span.end = last.Start().Offset + len(last.RawText())
+		span.endLine = last.End().Line
+	}
+	return span
+}
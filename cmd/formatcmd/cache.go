@@ -0,0 +1,121 @@
+package formatcmd
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/pubgo/protobuild/cmd/format"
+)
+
+const (
+	// formatCacheMaxAge is how long an entry is kept after its last use.
+	formatCacheMaxAge = 30 * 24 * time.Hour
+	// formatCachePruneInterval is how often old entries are removed.
+	formatCachePruneInterval = 24 * time.Hour
+)
+
+// formatCache remembers the content hashes of files that are already
+// formatted, keyed by formatter engine, version and style, so unchanged
+// files are not reparsed on the next run. A nil cache never hits.
+type formatCache struct {
+	dir  string
+	seed string
+}
+
+// newFormatCache creates a cache under ~/.cache/protobuild/format. The seed
+// must change whenever the formatter output could change.
+func newFormatCache(seed string) *formatCache {
+	home, _ := os.UserHomeDir()
+	if home == "" {
+		home = ".local"
+	}
+	return &formatCache{dir: filepath.Join(home, ".cache", "protobuild", "format"), seed: seed}
+}
+
+func (c *formatCache) path(content []byte) string {
+	h := sha256.New()
+	h.Write([]byte(c.seed))
+	h.Write([]byte{0})
+	h.Write(content)
+	key := hex.EncodeToString(h.Sum(nil))
+	return filepath.Join(c.dir, key[:2], key)
+}
+
+// builtinCacheSeed returns the cache seed of the builtin formatter with
+// opts. The options are encoded as JSON, so every field is part of the key
+// by name and value.
+func builtinCacheSeed(version, commit string, opts format.Options) (string, error) {
+	data, err := json.Marshal(opts)
+	if err != nil {
+		return "", err
+	}
+	return "builtin\x00" + version + "\x00" + commit + "\x00" + string(data), nil
+}
+
+// isFormatted reports whether content is known to be formatted. Hits
+// refresh the entry, so entries in use are not pruned.
+func (c *formatCache) isFormatted(content []byte) bool {
+	if c == nil {
+		return false
+	}
+	path := c.path(content)
+	info, err := os.Stat(path)
+	if err != nil {
+		return false
+	}
+	if time.Since(info.ModTime()) > formatCachePruneInterval {
+		now := time.Now()
+		_ = os.Chtimes(path, now, now)
+	}
+	return true
+}
+
+// markFormatted records content as formatted. Failures are ignored, the
+// cache is only an optimization.
+func (c *formatCache) markFormatted(content []byte) {
+	if c == nil {
+		return
+	}
+	path := c.path(content)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return
+	}
+	_ = os.WriteFile(path, nil, 0o644)
+}
+
+// prune removes the entries not used within formatCacheMaxAge. It runs at
+// most once per formatCachePruneInterval, tracked by a stamp file.
+func (c *formatCache) prune() {
+	if c == nil {
+		return
+	}
+	stamp := filepath.Join(c.dir, "pruned")
+	if info, err := os.Stat(stamp); err == nil && time.Since(info.ModTime()) < formatCachePruneInterval {
+		return
+	}
+	if err := os.MkdirAll(c.dir, 0o755); err != nil {
+		return
+	}
+	_ = os.WriteFile(stamp, nil, 0o644)
+
+	cutoff := time.Now().Add(-formatCacheMaxAge)
+	dirs, _ := os.ReadDir(c.dir)
+	for _, dir := range dirs {
+		if !dir.IsDir() {
+			continue
+		}
+		dirPath := filepath.Join(c.dir, dir.Name())
+		entries, _ := os.ReadDir(dirPath)
+		for _, entry := range entries {
+			if info, err := entry.Info(); err == nil && info.ModTime().Before(cutoff) {
+				_ = os.Remove(filepath.Join(dirPath, entry.Name()))
+			}
+		}
+		// Removing fails unless the directory is now empty.
+		_ = os.Remove(dirPath)
+	}
+}
@@ -0,0 +1,131 @@
+package formatcmd
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pubgo/protobuild/cmd/format"
+)
+
+func TestFormatFiles_Cache(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Setenv("HOME", filepath.Join(tmpDir, "home"))
+
+	var files []string
+	for i := range 8 {
+		path := filepath.Join(tmpDir, fmt.Sprintf("f%d.proto", i))
+		content := fmt.Sprintf("formatted %d\n", i)
+		if i%2 == 0 {
+			content = "un" + content
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, path)
+	}
+
+	var calls atomic.Int32
+	formatFn := func(_ string, original []byte) ([]byte, error) {
+		calls.Add(1)
+		return bytes.TrimPrefix(original, []byte("un")), nil
+	}
+	cache := newFormatCache("test")
+	cfg := FormatConfig{Jobs: 4}
+
+	if err := formatFiles(cfg, "test", cache, files, formatFn); err != nil {
+		t.Fatalf("formatFiles() error = %v", err)
+	}
+	if got := calls.Load(); got != 8 {
+		t.Errorf("first run formatted %d files, expected 8", got)
+	}
+
+	// Already formatted files are cached, unformatted files are not.
+	calls.Store(0)
+	cfg.ExitCode = true
+	if err := formatFiles(cfg, "test", cache, files, formatFn); err == nil {
+		t.Error("expected error for unformatted files")
+	}
+	if got := calls.Load(); got != 4 {
+		t.Errorf("second run formatted %d files, expected 4", got)
+	}
+
+	// Written files are cached.
+	calls.Store(0)
+	cfg = FormatConfig{Write: true}
+	if err := formatFiles(cfg, "test", cache, files, formatFn); err != nil {
+		t.Fatalf("formatFiles() error = %v", err)
+	}
+	calls.Store(0)
+	if err := formatFiles(FormatConfig{ExitCode: true}, "test", cache, files, formatFn); err != nil {
+		t.Fatalf("formatFiles() error = %v", err)
+	}
+	if got := calls.Load(); got != 0 {
+		t.Errorf("formatted %d files after write, expected 0", got)
+	}
+
+	// A different seed does not share entries.
+	calls.Store(0)
+	if err := formatFiles(FormatConfig{}, "test", newFormatCache("other"), files, formatFn); err != nil {
+		t.Fatalf("formatFiles() error = %v", err)
+	}
+	if got := calls.Load(); got != 8 {
+		t.Errorf("formatted %d files with another seed, expected 8", got)
+	}
+}
+
+func TestFormatCache_Prune(t *testing.T) {
+	cache := &formatCache{dir: t.TempDir(), seed: "test"}
+	cache.markFormatted([]byte("old"))
+	cache.markFormatted([]byte("used"))
+	cache.markFormatted([]byte("new"))
+
+	old := time.Now().Add(-2 * formatCacheMaxAge)
+	for _, content := range []string{"old", "used"} {
+		if err := os.Chtimes(cache.path([]byte(content)), old, old); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// A hit refreshes the entry.
+	if !cache.isFormatted([]byte("used")) {
+		t.Fatal("expected cache hit")
+	}
+
+	cache.prune()
+	for content, want := range map[string]bool{"old": false, "used": true, "new": true} {
+		if _, err := os.Stat(cache.path([]byte(content))); (err == nil) != want {
+			t.Errorf("entry %q kept = %v, want %v", content, err == nil, want)
+		}
+	}
+
+	// Pruning again within the interval is skipped.
+	cache.markFormatted([]byte("old"))
+	if err := os.Chtimes(cache.path([]byte("old")), old, old); err != nil {
+		t.Fatal(err)
+	}
+	cache.prune()
+	if _, err := os.Stat(cache.path([]byte("old"))); err != nil {
+		t.Error("expected no prune within the interval")
+	}
+}
+
+func TestBuiltinCacheSeed(t *testing.T) {
+	opts := format.DefaultOptions()
+	seed, err := builtinCacheSeed("v1", "abc", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	opts.ImportPaths = []string{"proto"}
+	other, err := builtinCacheSeed("v1", "abc", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if seed == other {
+		t.Error("expected the seed to change with the options")
+	}
+}
@@ -5,15 +5,20 @@ import (
 	"bytes"
 	"context"
 	"fmt"
+	"io"
 	"io/fs"
 	"log/slog"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"runtime"
 	"sort"
+	"strconv"
 	"strings"
+	"sync"
 
 	"github.com/pubgo/funk/v2/errors"
+	"github.com/pubgo/funk/v2/running"
 	"github.com/pubgo/redant"
 
 	"github.com/pubgo/protobuild/cmd/format"
@@ -31,12 +36,27 @@ type FormatConfig struct {
 	Diff bool
 	// Exit with non-zero code if files need formatting
 	ExitCode bool
-	// Use builtin formatter instead of buf
+	// Use builtin formatter (default, kept for compatibility)
 	Builtin bool
-	// Use clang-format instead of buf
+	// Use buf format instead of the builtin formatter
+	Buf bool
+	// Use clang-format instead of the builtin formatter
 	ClangFormat bool
 	// clang-format style (e.g. file, google, llvm)
 	ClangStyle string
+	// Read a proto file from stdin and write the result to stdout
+	Stdin bool
+	// File name used for stdin content (error messages and import resolution)
+	StdinFilename string
+	// Only format declarations overlapping this line span (e.g. 10:20)
+	Lines string
+	// Number of files formatted concurrently, 0 means the number of CPUs
+	Jobs int64
+	// Disable the cache of already formatted files
+	NoCache bool
+
+	// jobs is the --jobs flag, parsed into Jobs
+	jobs string
 }
 
 // ProjectConfig holds project configuration from protobuf.yaml.
@@ -47,6 +67,8 @@ type ProjectConfig struct {
 	Vendor string
 	// Include paths
 	Includes []string
+	// Builtin formatter options from the format section
+	Format format.Options
 }
 
 // New creates a new format command.
@@ -56,11 +78,12 @@ func New(name string, configProvider func() *ProjectConfig) *redant.Command {
 
 	return &redant.Command{
 		Use:   name,
-		Short: "Format Protobuf files (buf, builtin, or clang-format)",
-		Long: `Format Protobuf files using buf format command.
+		Short: "Format Protobuf files (builtin, buf, or clang-format)",
+		Long: `Format Protobuf files using the builtin formatter.
 
 By default, this command formats files in the 'root' directories defined in protobuf.yaml.
-You can also specify paths explicitly.
+You can also specify paths explicitly. The builtin formatter is configured by the
+'format' section of protobuf.yaml and needs no external tools.
 
 Examples:
   # Format files in configured root directories
@@ -78,11 +101,20 @@ Examples:
   # Format and exit with error if changes needed (useful for CI)
   protobuild format --exit-code
 
+	# Use buf format engine
+	protobuild format --buf -w
+
 	# Use clang-format engine
 	protobuild format --clang-format -w
 
 	# Use clang-format with specific style
 	protobuild format --clang-format --clang-style google -w
+
+  # Format stdin to stdout (editors, git filters)
+  protobuild format --stdin --stdin-filename proto/user.proto < proto/user.proto
+
+  # Only format declarations on lines 10-20
+  protobuild format --lines 10:20 -w proto/user.proto
 `,
 		Options: typex.Options{
 			redant.Option{
@@ -104,12 +136,17 @@ Examples:
 			},
 			redant.Option{
 				Flag:        "builtin",
-				Description: "Use builtin formatter instead of buf",
+				Description: "Use builtin formatter (default)",
 				Value:       redant.BoolOf(&cfg.Builtin),
 			},
+			redant.Option{
+				Flag:        "buf",
+				Description: "Use buf format instead of the builtin formatter",
+				Value:       redant.BoolOf(&cfg.Buf),
+			},
 			redant.Option{
 				Flag:        "clang-format",
-				Description: "Use clang-format instead of buf",
+				Description: "Use clang-format instead of the builtin formatter",
 				Value:       redant.BoolOf(&cfg.ClangFormat),
 			},
 			redant.Option{
@@ -118,17 +155,49 @@ Examples:
 				Default:     "google",
 				Value:       redant.StringOf(&cfg.ClangStyle),
 			},
+			redant.Option{
+				Flag:        "stdin",
+				Description: "Read a proto file from stdin and write the formatted result to stdout",
+				Value:       redant.BoolOf(&cfg.Stdin),
+			},
+			redant.Option{
+				Flag:        "stdin-filename",
+				Description: "File name of the stdin content, used for errors and import resolution",
+				Default:     "stdin.proto",
+				Value:       redant.StringOf(&cfg.StdinFilename),
+			},
+			redant.Option{
+				Flag:        "jobs",
+				Shorthand:   "j",
+				Description: "Number of files formatted concurrently (default: number of CPUs)",
+				Value:       redant.StringOf(&cfg.jobs),
+			},
+			redant.Option{
+				Flag:        "no-cache",
+				Description: "Do not use the cache of already formatted files",
+				Value:       redant.BoolOf(&cfg.NoCache),
+			},
+			redant.Option{
+				Flag:        "lines",
+				Description: "Only format declarations overlapping the line span start:end (builtin only)",
+				Value:       redant.StringOf(&cfg.Lines),
+			},
 		},
 		Handler: func(ctx context.Context, inv *redant.Invocation) error {
 			// Get paths from args or use configured root directories
 			cfg.Paths = inv.Args
 
-			if len(cfg.Paths) == 0 && configProvider != nil {
-				projectCfg := configProvider()
-				if projectCfg != nil && len(projectCfg.Root) > 0 {
-					cfg.Paths = projectCfg.Root
-					slog.Info("using root directories from config", "paths", cfg.Paths)
-				}
+			var projectCfg *ProjectConfig
+			if configProvider != nil {
+				projectCfg = configProvider()
+			}
+			if projectCfg == nil {
+				projectCfg = &ProjectConfig{Format: format.DefaultOptions()}
+			}
+
+			if len(cfg.Paths) == 0 && len(projectCfg.Root) > 0 && !cfg.Stdin {
+				cfg.Paths = projectCfg.Root
+				slog.Info("using root directories from config", "paths", cfg.Paths)
 			}
 
 			// Fallback to default if still empty
@@ -136,18 +205,39 @@ Examples:
 				cfg.Paths = []string{"proto"}
 			}
 
-			if cfg.Builtin && cfg.ClangFormat {
-				return fmt.Errorf("--builtin and --clang-format cannot be used together")
+			if cfg.jobs != "" {
+				jobs, err := strconv.ParseInt(cfg.jobs, 10, 64)
+				if err != nil {
+					return fmt.Errorf("invalid --jobs %q: %w", cfg.jobs, err)
+				}
+				cfg.Jobs = jobs
+			}
+
+			engines := 0
+			for _, set := range []bool{cfg.Builtin, cfg.Buf, cfg.ClangFormat} {
+				if set {
+					engines++
+				}
+			}
+			if engines > 1 {
+				return fmt.Errorf("--builtin, --buf and --clang-format cannot be used together")
+			}
+			if (cfg.Stdin || cfg.Lines != "") && (cfg.Buf || cfg.ClangFormat) {
+				return fmt.Errorf("--stdin and --lines are only supported by the builtin formatter")
+			}
+
+			if cfg.Stdin {
+				return runStdinFormat(cfg, projectCfg.Format, os.Stdin, os.Stdout)
 			}
 
 			if cfg.ClangFormat {
 				return runClangFormat(cfg)
 			}
 
-			if cfg.Builtin {
-				return runBuiltinFormat(cfg)
+			if cfg.Buf {
+				return runBufFormat(cfg)
 			}
-			return runBufFormat(cfg)
+			return runBuiltinFormat(cfg, projectCfg.Format)
 		},
 	}
 }
@@ -214,38 +304,105 @@ func runBufFormat(cfg FormatConfig) error {
 }
 
 // runBuiltinFormat runs the builtin formatter.
-func runBuiltinFormat(cfg FormatConfig) error {
-	slog.Info("using builtin formatter")
+func runBuiltinFormat(cfg FormatConfig, opts format.Options) error {
+	files, err := collectProtoFiles(cfg.Paths)
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		slog.Warn("no proto files found to format", "paths", cfg.Paths)
+		return nil
+	}
 
-	for _, path := range cfg.Paths {
-		info, err := os.Stat(path)
-		if os.IsNotExist(err) {
-			slog.Warn("path not found, skipping", "path", path)
-			continue
+	slog.Debug("using builtin formatter", "indent_width", opts.IndentWidth, "max_line_length", opts.MaxLineLength)
+
+	formatFn, err := builtinFormatFunc(cfg, opts)
+	if err != nil {
+		return err
+	}
+
+	// Range formatting leaves the rest of the file untouched, and import
+	// grouping or removal depend on other files, so neither can be cached.
+	var cache *formatCache
+	if !cfg.NoCache && cfg.Lines == "" && !opts.GroupImports && !opts.RemoveUnusedImports {
+		if seed, err := builtinCacheSeed(running.Version(), running.CommitID(), opts); err == nil {
+			cache = newFormatCache(seed)
 		}
+	}
+	return formatFiles(cfg, "builtin", cache, files, formatFn)
+}
 
-		if info.IsDir() {
-			err = filepath.Walk(path, func(filePath string, info os.FileInfo, err error) error {
-				if err != nil {
-					return err
-				}
-				if !info.IsDir() && strings.HasSuffix(filePath, ".proto") {
-					slog.Debug("formatting", "file", filePath)
-					// Note: builtin format always writes to file
-					format.Format(filePath)
-				}
-				return nil
-			})
+// runStdinFormat formats the proto read from r and writes the result to w.
+func runStdinFormat(cfg FormatConfig, opts format.Options, r io.Reader, w io.Writer) error {
+	formatFn, err := builtinFormatFunc(cfg, opts)
+	if err != nil {
+		return err
+	}
+
+	original, err := io.ReadAll(r)
+	if err != nil {
+		return errors.Wrap(err, "read stdin failed")
+	}
+
+	formatted, err := formatFn(cfg.StdinFilename, original)
+	if err != nil {
+		return err
+	}
+
+	if cfg.ExitCode && !bytes.Equal(original, formatted) {
+		if _, err := w.Write(formatted); err != nil {
+			return err
+		}
+		return fmt.Errorf("%s needs formatting", cfg.StdinFilename)
+	}
+	_, err = w.Write(formatted)
+	return err
+}
+
+// builtinFormatFunc returns the builtin format function, restricted to
+// cfg.Lines when it is set.
+func builtinFormatFunc(cfg FormatConfig, opts format.Options) (func(file string, original []byte) ([]byte, error), error) {
+	if cfg.Lines == "" {
+		return func(file string, original []byte) ([]byte, error) {
+			formatted, err := format.SourceWithOptions(file, original, opts)
 			if err != nil {
-				return err
+				return nil, errors.Wrapf(err, "format %s failed", file)
 			}
-		} else if strings.HasSuffix(path, ".proto") {
-			format.Format(path)
-		}
+			return formatted, nil
+		}, nil
 	}
 
-	fmt.Println("✅ Files formatted successfully")
-	return nil
+	start, end, err := parseLineRange(cfg.Lines)
+	if err != nil {
+		return nil, err
+	}
+	return func(file string, original []byte) ([]byte, error) {
+		formatted, err := format.SourceRange(file, original, opts, start, end)
+		if err != nil {
+			return nil, errors.Wrapf(err, "format %s failed", file)
+		}
+		return formatted, nil
+	}, nil
+}
+
+// parseLineRange parses a 1-based, inclusive line span such as "10:20" or "10".
+func parseLineRange(s string) (int, int, error) {
+	startStr, endStr, found := strings.Cut(s, ":")
+	if !found {
+		endStr = startStr
+	}
+	start, err := strconv.Atoi(strings.TrimSpace(startStr))
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid --lines %q, expected start:end", s)
+	}
+	end, err := strconv.Atoi(strings.TrimSpace(endStr))
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid --lines %q, expected start:end", s)
+	}
+	if start < 1 || end < start {
+		return 0, 0, fmt.Errorf("invalid --lines %q, expected 1 <= start <= end", s)
+	}
+	return start, end, nil
 }
 
 // runClangFormat runs the clang-format command.
@@ -271,15 +428,59 @@ func runClangFormat(cfg FormatConfig) error {
 
 	slog.Debug("using clang-format", "path", clangPath, "style", cfg.ClangStyle)
 
-	var changedFiles []string
-
-	for _, file := range files {
-		original, err := os.ReadFile(file)
-		if err != nil {
-			return errors.Wrap(err, "read file failed")
+	// A style file may change without the style name changing, so only
+	// named styles are cached.
+	var cache *formatCache
+	if !cfg.NoCache && cfg.ClangStyle != "file" {
+		clangVersion, err := exec.Command(clangPath, "--version").Output()
+		if err == nil {
+			cache = newFormatCache(fmt.Sprintf("clang-format\x00%s\x00%s", bytes.TrimSpace(clangVersion), cfg.ClangStyle))
 		}
+	}
+
+	return formatFiles(cfg, "clang-format", cache, files, func(file string, _ []byte) ([]byte, error) {
+		return getClangFormattedContent(clangPath, file, cfg.ClangStyle)
+	})
+}
 
-		formatted, err := getClangFormattedContent(clangPath, file, cfg.ClangStyle)
+// formatResult is the outcome of formatting a single file.
+type formatResult struct {
+	original  []byte
+	formatted []byte
+	err       error
+}
+
+// formatFiles formats the files concurrently with formatFn, then writes, diffs
+// or reports the files whose content changed according to cfg. Files that the
+// cache knows to be formatted are skipped.
+func formatFiles(cfg FormatConfig, engine string, cache *formatCache, files []string, formatFn func(file string, original []byte) ([]byte, error)) error {
+	jobs := int(cfg.Jobs)
+	if jobs <= 0 {
+		jobs = runtime.NumCPU()
+	}
+
+	results := make([]formatResult, len(files))
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for range min(jobs, len(files)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				results[i] = formatFile(cache, files[i], formatFn)
+			}
+		}()
+	}
+	for i := range files {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+	cache.prune()
+
+	var changedFiles []string
+	for i, file := range files {
+		original, formatted, err := results[i].original, results[i].formatted, results[i].err
 		if err != nil {
 			return err
 		}
@@ -294,11 +495,12 @@ func runClangFormat(cfg FormatConfig) error {
 			if err := os.WriteFile(file, formatted, 0o644); err != nil {
 				return errors.Wrap(err, "write formatted file failed")
 			}
+			cache.markFormatted(formatted)
 			continue
 		}
 
 		if cfg.Diff {
-			diffOut, err := unifiedDiff(file, original, formatted)
+			diffOut, err := unifiedDiff(file, engine, original, formatted)
 			if err != nil {
 				return err
 			}
@@ -319,6 +521,9 @@ func runClangFormat(cfg FormatConfig) error {
 	}
 
 	if !cfg.Diff {
+		for _, file := range changedFiles {
+			fmt.Printf("   %s\n", file)
+		}
 		fmt.Printf("⚠️  %d files need formatting\n", len(changedFiles))
 	}
 
@@ -329,6 +534,27 @@ func runClangFormat(cfg FormatConfig) error {
 	return nil
 }
 
+// formatFile reads and formats a single file, consulting the cache first.
+func formatFile(cache *formatCache, file string, formatFn func(file string, original []byte) ([]byte, error)) formatResult {
+	original, err := os.ReadFile(file)
+	if err != nil {
+		return formatResult{err: errors.Wrap(err, "read file failed")}
+	}
+
+	if cache.isFormatted(original) {
+		return formatResult{original: original, formatted: original}
+	}
+
+	formatted, err := formatFn(file, original)
+	if err != nil {
+		return formatResult{err: err}
+	}
+	if bytes.Equal(original, formatted) {
+		cache.markFormatted(original)
+	}
+	return formatResult{original: original, formatted: formatted}
+}
+
 func getClangFormattedContent(clangPath, filePath, style string) ([]byte, error) {
 	args := make([]string, 0, 2)
 	if strings.TrimSpace(style) != "" {
@@ -398,8 +624,8 @@ func collectProtoFiles(paths []string) ([]string, error) {
 	return files, nil
 }
 
-func unifiedDiff(filePath string, original, formatted []byte) (string, error) {
-	tmp, err := os.CreateTemp("", "protobuild-clang-format-*.proto")
+func unifiedDiff(filePath, engine string, original, formatted []byte) (string, error) {
+	tmp, err := os.CreateTemp("", "protobuild-format-*.proto")
 	if err != nil {
 		return "", err
 	}
@@ -414,7 +640,7 @@ func unifiedDiff(filePath string, original, formatted []byte) (string, error) {
 		return "", err
 	}
 
-	cmd := exec.Command("diff", "-u", "-L", filePath, "-L", filePath+" (clang-format)", filePath, tmpPath)
+	cmd := exec.Command("diff", "-u", "-L", filePath, "-L", filePath+" ("+engine+")", filePath, tmpPath)
 	out, err := cmd.CombinedOutput()
 	if err == nil {
 		return string(out), nil
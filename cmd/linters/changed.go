@@ -0,0 +1,183 @@
+package linters
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/googleapis/api-linter/v2/lint"
+)
+
+// lineRange is an inclusive, one-based range of changed lines.
+type lineRange struct {
+	start, end int
+}
+
+// changedFiles maps cleaned file paths, relative to the working directory,
+// to the line ranges that changed in them.
+type changedFiles map[string][]lineRange
+
+// gitChangedFiles returns the .proto files changed in the working tree since
+// ref, including untracked files, which count as changed in full.
+func gitChangedFiles(ref string) (changedFiles, error) {
+	diff, err := git("diff", "--relative", "--unified=0", "--no-color", "--no-ext-diff", "--diff-filter=AMR", ref, "--", "*.proto")
+	if err != nil {
+		return nil, err
+	}
+
+	changes, err := parseUnifiedDiff(bytes.NewReader(diff))
+	if err != nil {
+		return nil, err
+	}
+
+	untracked, err := git("ls-files", "--others", "--exclude-standard", "--", "*.proto")
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range strings.Split(strings.TrimSpace(string(untracked)), "\n") {
+		if path != "" {
+			changes[filepath.Clean(path)] = []lineRange{{start: 1, end: math.MaxInt}}
+		}
+	}
+
+	return changes, nil
+}
+
+func git(args ...string) ([]byte, error) {
+	var stderr bytes.Buffer
+	cmd := exec.Command("git", args...)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git %s: %w: %s", strings.Join(args, " "), err, strings.TrimSpace(stderr.String()))
+	}
+	return out, nil
+}
+
+// parseUnifiedDiff collects the added or modified line ranges of the new
+// files from a unified diff produced with --unified=0.
+func parseUnifiedDiff(r io.Reader) (changedFiles, error) {
+	changes := make(changedFiles)
+
+	var current string
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "+++ "):
+			current = ""
+			if name := strings.TrimPrefix(line, "+++ "); name != "/dev/null" {
+				current = filepath.Clean(strings.TrimPrefix(name, "b/"))
+				changes[current] = nil
+			}
+
+		case strings.HasPrefix(line, "@@ ") && current != "":
+			rng, ok, err := parseHunkHeader(line)
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				changes[current] = append(changes[current], rng)
+			}
+		}
+	}
+	return changes, scanner.Err()
+}
+
+// parseHunkHeader parses the new-file range of "@@ -a,b +c,d @@". Hunks that
+// only delete lines report ok=false.
+func parseHunkHeader(line string) (rng lineRange, ok bool, err error) {
+	fields := strings.Fields(line)
+	if len(fields) < 3 || !strings.HasPrefix(fields[2], "+") {
+		return rng, false, fmt.Errorf("invalid hunk header: %q", line)
+	}
+
+	startStr, countStr, hasCount := strings.Cut(strings.TrimPrefix(fields[2], "+"), ",")
+	start, err := strconv.Atoi(startStr)
+	if err != nil {
+		return rng, false, fmt.Errorf("invalid hunk header: %q", line)
+	}
+
+	count := 1
+	if hasCount {
+		if count, err = strconv.Atoi(countStr); err != nil {
+			return rng, false, fmt.Errorf("invalid hunk header: %q", line)
+		}
+	}
+	if count == 0 {
+		return rng, false, nil
+	}
+
+	return lineRange{start: start, end: start + count - 1}, true, nil
+}
+
+// normalizePath returns the changedFiles key for path.
+func normalizePath(path string) string {
+	if filepath.IsAbs(path) {
+		if wd, err := os.Getwd(); err == nil {
+			if rel, err := filepath.Rel(wd, path); err == nil {
+				return rel
+			}
+		}
+	}
+	return filepath.Clean(path)
+}
+
+// filterFiles returns the files that changed.
+func (c changedFiles) filterFiles(files []string) []string {
+	var changed []string
+	for _, f := range files {
+		if _, ok := c[normalizePath(f)]; ok {
+			changed = append(changed, f)
+		}
+	}
+	return changed
+}
+
+// filterProblems drops problems outside the changed line ranges. Problems
+// without a location apply to the whole file and are kept.
+func (c changedFiles) filterProblems(responses []lint.Response) []lint.Response {
+	filtered := make([]lint.Response, 0, len(responses))
+	for _, resp := range responses {
+		ranges := c[normalizePath(resp.FilePath)]
+
+		var problems []lint.Problem
+		for _, problem := range resp.Problems {
+			span := problem.Location.GetSpan()
+			if len(span) < 3 {
+				problems = append(problems, problem)
+				continue
+			}
+
+			// Spans are zero-based; 3-element spans end on the start line.
+			start, end := int(span[0])+1, int(span[0])+1
+			if len(span) == 4 {
+				end = int(span[2]) + 1
+			}
+			if overlaps(ranges, start, end) {
+				problems = append(problems, problem)
+			}
+		}
+
+		resp.Problems = problems
+		filtered = append(filtered, resp)
+	}
+	return filtered
+}
+
+func overlaps(ranges []lineRange, start, end int) bool {
+	for _, r := range ranges {
+		if start <= r.end && end >= r.start {
+			return true
+		}
+	}
+	return false
+}
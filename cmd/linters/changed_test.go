@@ -0,0 +1,86 @@
+package linters
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/googleapis/api-linter/v2/lint"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+const testDiff = `diff --git a/api/v1/book.proto b/api/v1/book.proto
+index 1111111..2222222 100644
+--- a/api/v1/book.proto
++++ b/api/v1/book.proto
+@@ -3,0 +4,2 @@ package api.v1;
++import "google/api/field_behavior.proto";
++
+@@ -20 +22 @@ message Book {
+-  string name = 1;
++  string book_name = 1;
+@@ -30,2 +31,0 @@ message Book {
+-  // removed
+-  int32 pages = 3;
+diff --git a/api/v1/shelf.proto b/api/v1/shelf.proto
+new file mode 100644
+--- /dev/null
++++ b/api/v1/shelf.proto
+@@ -0,0 +1,3 @@
++syntax = "proto3";
++
++package api.v1;
+diff --git a/api/v1/old.proto b/api/v1/old.proto
+deleted file mode 100644
+--- a/api/v1/old.proto
++++ /dev/null
+@@ -1 +0,0 @@
+-syntax = "proto3";
+`
+
+func TestParseUnifiedDiff(t *testing.T) {
+	changes, err := parseUnifiedDiff(strings.NewReader(testDiff))
+	if err != nil {
+		t.Fatalf("parseUnifiedDiff() error: %v", err)
+	}
+
+	expected := changedFiles{
+		"api/v1/book.proto":  {{start: 4, end: 5}, {start: 22, end: 22}},
+		"api/v1/shelf.proto": {{start: 1, end: 3}},
+	}
+	if !reflect.DeepEqual(changes, expected) {
+		t.Errorf("parseUnifiedDiff() = %v, expected %v", changes, expected)
+	}
+}
+
+func TestChangedFiles_Filter(t *testing.T) {
+	changes := changedFiles{"api/v1/book.proto": {{start: 22, end: 22}}}
+
+	files := changes.filterFiles([]string{"./api/v1/book.proto", "api/v1/shelf.proto"})
+	if len(files) != 1 || files[0] != "./api/v1/book.proto" {
+		t.Errorf("filterFiles() = %v", files)
+	}
+
+	location := func(span ...int32) *descriptorpb.SourceCodeInfo_Location {
+		return &descriptorpb.SourceCodeInfo_Location{Span: span}
+	}
+	responses := []lint.Response{{
+		FilePath: "api/v1/book.proto",
+		Problems: []lint.Problem{
+			{RuleID: "changed-line", Location: location(21, 2, 20)},
+			{RuleID: "unchanged-line", Location: location(10, 0, 5)},
+			{RuleID: "spans-change", Location: location(18, 0, 25, 1)},
+			{RuleID: "no-location"},
+		},
+	}}
+
+	var got []lint.RuleName
+	for _, p := range changes.filterProblems(responses)[0].Problems {
+		got = append(got, p.RuleID)
+	}
+
+	expected := []lint.RuleName{"changed-line", "spans-change", "no-location"}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("filterProblems() = %v, expected %v", got, expected)
+	}
+}
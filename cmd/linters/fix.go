@@ -0,0 +1,337 @@
+package linters
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/bufbuild/protocompile/ast"
+	"github.com/bufbuild/protocompile/parser"
+	"github.com/bufbuild/protocompile/reporter"
+	"github.com/googleapis/api-linter/v2/lint"
+)
+
+// fixableRules lists the rules whose suggestions are applied by the fixer.
+// Only suggestions that rename the declaration itself are safe; others (e.g.
+// request/response message names) would rewrite references to types that do
+// not exist yet. Rules without a suggestion, such as missing comments, need
+// content a fixer cannot invent and are left to the author.
+var fixableRules = map[lint.RuleName]bool{
+	"core::0126::unspecified":        true,
+	"core::0126::upper-snake-values": true,
+	"core::0140::lower-snake":        true,
+	"core::0140::underscores":        true,
+	"core::0231::plural-method-name": true,
+	"core::0233::plural-method-name": true,
+	"core::0234::plural-method-name": true,
+	"core::0235::plural-method-name": true,
+}
+
+// Fix describes a single change applied by the auto-fixer.
+type Fix struct {
+	FilePath string        `json:"file_path" yaml:"file_path"`
+	RuleID   lint.RuleName `json:"rule_id" yaml:"rule_id"`
+	Line     int           `json:"line" yaml:"line"`
+	Column   int           `json:"column" yaml:"column"`
+	Old      string        `json:"old" yaml:"old"`
+	New      string        `json:"new" yaml:"new"`
+}
+
+// String returns a one-line, human-readable description of the fix.
+func (f Fix) String() string {
+	return fmt.Sprintf("%s:%d:%d [%s] %q -> %q", f.FilePath, f.Line, f.Column, f.RuleID, f.Old, f.New)
+}
+
+// ApplyFixes applies the mechanical fixes available for the given lint results
+// and writes back only the renamed tokens, leaving the rest of each file as
+// it is. References to a renamed name are not updated, so a fix is skipped
+// when the old name is used anywhere else in the fixed files or in refFiles,
+// e.g. in a google.api.http path or body, or as an option value. It returns
+// the fixes that were applied and those that were skipped.
+func ApplyFixes(responses []lint.Response, refFiles []string) (applied, skipped []Fix, err error) {
+	var files []*fixFile
+	for _, resp := range responses {
+		if len(resp.Problems) == 0 {
+			continue
+		}
+
+		data, err := os.ReadFile(resp.FilePath)
+		if err != nil {
+			return nil, nil, fmt.Errorf("read %s: %w", resp.FilePath, err)
+		}
+		edits, err := planEdits(resp.FilePath, data, resp.Problems)
+		if err != nil {
+			return nil, nil, fmt.Errorf("fix %s: %w", resp.FilePath, err)
+		}
+		if len(edits) > 0 {
+			files = append(files, &fixFile{path: resp.FilePath, data: data, edits: edits})
+		}
+	}
+	if len(files) == 0 {
+		return nil, nil, nil
+	}
+
+	refs := newReferenceIndex(files, refFiles)
+	for _, f := range files {
+		var kept []textEdit
+		for _, e := range f.edits {
+			if refs.referenced(e.fix.Old) {
+				skipped = append(skipped, e.fix)
+				continue
+			}
+			kept = append(kept, e)
+		}
+
+		out, fixes := applyEdits(f.data, kept)
+		if len(fixes) == 0 {
+			continue
+		}
+		if err := os.WriteFile(f.path, out, 0o644); err != nil {
+			return applied, skipped, fmt.Errorf("write %s: %w", f.path, err)
+		}
+		applied = append(applied, fixes...)
+	}
+	return applied, skipped, nil
+}
+
+// fixFile is a file with the edits planned for it.
+type fixFile struct {
+	path  string
+	data  []byte
+	edits []textEdit
+}
+
+// textEdit replaces data[start:end] with text.
+type textEdit struct {
+	start, end int
+	text       string
+	fix        Fix
+}
+
+// planEdits returns the edits for the fixable problems of a file.
+func planEdits(path string, data []byte, problems []lint.Problem) ([]textEdit, error) {
+	fileNode, err := parser.Parse(path, bytes.NewReader(data), reporter.NewHandler(nil))
+	if err != nil {
+		return nil, err
+	}
+
+	idx := newTokenIndex(fileNode)
+
+	var edits []textEdit
+	for _, problem := range problems {
+		if edit, ok := idx.editFor(path, data, problem); ok {
+			edits = append(edits, edit)
+		}
+	}
+	return edits, nil
+}
+
+// applyEdits applies edits to data and returns the result with the fixes
+// that were applied, in source order.
+func applyEdits(data []byte, edits []textEdit) ([]byte, []Fix) {
+	// Apply edits from the end of the file so earlier offsets stay valid,
+	// skipping any edit that overlaps one already applied.
+	edits = slices.Clone(edits)
+	sort.SliceStable(edits, func(i, j int) bool { return edits[i].start > edits[j].start })
+
+	out := data
+	limit := len(data)
+	applied := make([]Fix, 0, len(edits))
+	for _, e := range edits {
+		if e.end > limit {
+			continue
+		}
+
+		var buf bytes.Buffer
+		buf.Grow(len(out) + len(e.text))
+		buf.Write(out[:e.start])
+		buf.WriteString(e.text)
+		buf.Write(out[e.end:])
+		out = buf.Bytes()
+
+		limit = e.start
+		applied = append(applied, e.fix)
+	}
+
+	// Report fixes in source order.
+	sort.SliceStable(applied, func(i, j int) bool {
+		if applied[i].Line != applied[j].Line {
+			return applied[i].Line < applied[j].Line
+		}
+		return applied[i].Column < applied[j].Column
+	})
+	return out, applied
+}
+
+// referenceIndex counts the uses of the names to be renamed: identifiers, and
+// words in string literals such as google.api.http paths and bodies.
+// Comments are not uses.
+type referenceIndex struct {
+	uses map[string]int
+}
+
+// newReferenceIndex indexes files and refFiles. The declarations being
+// renamed are not uses; a declaration with the same name that is not
+// renamed is. Reference files that do not parse are skipped, as they fail
+// to compile anyway.
+func newReferenceIndex(files []*fixFile, refFiles []string) *referenceIndex {
+	idx := &referenceIndex{uses: make(map[string]int)}
+	renamed := make(map[string]map[int]bool)
+	sources := make(map[string][]byte)
+	var paths []string
+	for _, f := range files {
+		abs := absPath(f.path)
+		renamed[abs] = make(map[int]bool)
+		for _, e := range f.edits {
+			idx.uses[e.fix.Old] = 0
+			renamed[abs][e.start] = true
+		}
+		sources[abs] = f.data
+		paths = append(paths, abs)
+	}
+	for _, path := range refFiles {
+		abs := absPath(path)
+		if _, ok := sources[abs]; ok {
+			continue
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		sources[abs] = data
+		paths = append(paths, abs)
+	}
+
+	for _, path := range paths {
+		fileNode, err := parser.Parse(path, bytes.NewReader(sources[path]), reporter.NewHandler(nil))
+		if err != nil {
+			continue
+		}
+
+		tokens := fileNode.Tokens()
+		for tok, ok := tokens.First(); ok; tok, ok = tokens.Next(tok) {
+			info := fileNode.TokenInfo(tok)
+			text := info.RawText()
+			if strings.HasPrefix(text, `"`) || strings.HasPrefix(text, "'") {
+				for _, word := range strings.FieldsFunc(text, isNotIdentRune) {
+					idx.use(word)
+				}
+				continue
+			}
+			if !renamed[path][info.Start().Offset] {
+				idx.use(text)
+			}
+		}
+	}
+	return idx
+}
+
+func (idx *referenceIndex) use(name string) {
+	if _, ok := idx.uses[name]; ok {
+		idx.uses[name]++
+	}
+}
+
+// referenced reports whether name is used outside the declarations being
+// renamed.
+func (idx *referenceIndex) referenced(name string) bool {
+	return idx.uses[name] > 0
+}
+
+func isNotIdentRune(r rune) bool {
+	return r != '_' && !unicode.IsLetter(r) && !unicode.IsDigit(r)
+}
+
+func absPath(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return path
+}
+
+// tokenIndex maps source positions reported by the linter to byte offsets.
+//
+// Spans in SourceCodeInfo are computed with tab stops, so positions are
+// matched against token boundaries rather than recomputed from columns.
+type tokenIndex struct {
+	starts map[position]int
+	ends   map[position]int
+}
+
+// position is a one-based line and column pair.
+type position struct {
+	line, col int
+}
+
+func newTokenIndex(fileNode *ast.FileNode) *tokenIndex {
+	idx := &tokenIndex{
+		starts: make(map[position]int),
+		ends:   make(map[position]int),
+	}
+
+	tokens := fileNode.Tokens()
+	for tok, ok := tokens.First(); ok; tok, ok = tokens.Next(tok) {
+		info := fileNode.TokenInfo(tok)
+		start, end := info.Start(), info.End()
+		idx.starts[position{start.Line, start.Col}] = start.Offset
+		idx.ends[position{end.Line, end.Col}] = start.Offset + len(info.RawText())
+	}
+	return idx
+}
+
+// span resolves a zero-based SourceCodeInfo span to byte offsets.
+func (idx *tokenIndex) span(span []int32) (start, end int, ok bool) {
+	var startLine, startCol, endLine, endCol int32
+	switch len(span) {
+	case 3:
+		startLine, startCol, endLine, endCol = span[0], span[1], span[0], span[2]
+	case 4:
+		startLine, startCol, endLine, endCol = span[0], span[1], span[2], span[3]
+	default:
+		return 0, 0, false
+	}
+
+	start, ok = idx.starts[position{int(startLine) + 1, int(startCol) + 1}]
+	if !ok {
+		return 0, 0, false
+	}
+	end, ok = idx.ends[position{int(endLine) + 1, int(endCol) + 1}]
+	if !ok || end < start {
+		return 0, 0, false
+	}
+	return start, end, true
+}
+
+// editFor returns the edit that fixes problem, if it is mechanically fixable.
+func (idx *tokenIndex) editFor(path string, data []byte, problem lint.Problem) (textEdit, bool) {
+	if problem.Location == nil || !fixableRules[problem.RuleID] {
+		return textEdit{}, false
+	}
+
+	start, end, ok := idx.span(problem.Location.GetSpan())
+	if !ok {
+		return textEdit{}, false
+	}
+
+	fix := Fix{
+		FilePath: path,
+		RuleID:   problem.RuleID,
+		Line:     int(problem.Location.GetSpan()[0]) + 1,
+		Column:   int(problem.Location.GetSpan()[1]) + 1,
+	}
+
+	if problem.Suggestion == "" {
+		return textEdit{}, false
+	}
+	fix.Old = string(data[start:end])
+	fix.New = problem.Suggestion
+	if fix.Old == fix.New {
+		return textEdit{}, false
+	}
+	return textEdit{start: start, end: end, text: problem.Suggestion, fix: fix}, true
+}
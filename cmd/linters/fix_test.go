@@ -0,0 +1,186 @@
+package linters
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/googleapis/api-linter/v2/lint"
+	dpb "google.golang.org/protobuf/types/descriptorpb"
+)
+
+const fixTestProto = `syntax = "proto3";
+
+package acme.v1;
+
+// Book is a book.
+message Book {
+	// The book name.
+	string BookName = 1; // trailing
+}
+
+// State is a state.
+enum State {
+  UNKNOWN_STATE = 0;
+}
+`
+
+var (
+	bookNameProblem = lint.Problem{
+		RuleID:     "core::0140::lower-snake",
+		Suggestion: "book_name",
+		Location:   &dpb.SourceCodeInfo_Location{Span: []int32{7, 15, 23}},
+	}
+	unknownStateProblem = lint.Problem{
+		RuleID:     "core::0126::unspecified",
+		Suggestion: "STATE_UNSPECIFIED",
+		Location:   &dpb.SourceCodeInfo_Location{Span: []int32{12, 2, 15}},
+	}
+)
+
+// applyTestFixes writes src as book.proto and refs next to it, applies the
+// fixes for problems and returns the resulting book.proto.
+func applyTestFixes(t *testing.T, src string, problems []lint.Problem, refs map[string]string) (string, []Fix, []Fix) {
+	t.Helper()
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "book.proto")
+	if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	var refFiles []string
+	for name, content := range refs {
+		refFile := filepath.Join(dir, name)
+		if err := os.WriteFile(refFile, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		refFiles = append(refFiles, refFile)
+	}
+
+	applied, skipped, err := ApplyFixes([]lint.Response{{FilePath: path, Problems: problems}}, refFiles)
+	if err != nil {
+		t.Fatalf("ApplyFixes() error = %v", err)
+	}
+	out, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(out), applied, skipped
+}
+
+func TestApplyFixes_AppliesSuggestions(t *testing.T) {
+	problems := []lint.Problem{
+		bookNameProblem,
+		unknownStateProblem,
+		{
+			RuleID:     "core::0131::http-method",
+			Suggestion: "GET",
+			Location:   &dpb.SourceCodeInfo_Location{Span: []int32{5, 0, 6}},
+		},
+	}
+
+	out, fixes, skipped := applyTestFixes(t, fixTestProto, problems, nil)
+	if len(fixes) != 2 || len(skipped) != 0 {
+		t.Fatalf("expected 2 fixes, got %d: %v, skipped %v", len(fixes), fixes, skipped)
+	}
+	if fixes[0].Old != "BookName" || fixes[0].New != "book_name" {
+		t.Errorf("unexpected first fix: %v", fixes[0])
+	}
+	if fixes[1].Old != "UNKNOWN_STATE" || fixes[1].New != "STATE_UNSPECIFIED" {
+		t.Errorf("unexpected second fix: %v", fixes[1])
+	}
+
+	// Only the renamed tokens change; the file is not reformatted.
+	want := strings.NewReplacer("BookName", "book_name", "UNKNOWN_STATE", "STATE_UNSPECIFIED").Replace(fixTestProto)
+	if out != want {
+		t.Errorf("output =\n%s\nwant\n%s", out, want)
+	}
+}
+
+func TestApplyFixes_SkipsUnresolvedLocation(t *testing.T) {
+	problems := []lint.Problem{{
+		RuleID:     "core::0140::lower-snake",
+		Suggestion: "book_name",
+		Location:   &dpb.SourceCodeInfo_Location{Span: []int32{7, 16, 23}},
+	}}
+
+	out, fixes, _ := applyTestFixes(t, fixTestProto, problems, nil)
+	if len(fixes) != 0 || out != fixTestProto {
+		t.Fatalf("expected no fixes for a span that does not match a token, got %v", fixes)
+	}
+}
+
+func TestApplyFixes_LeavesMissingComments(t *testing.T) {
+	problems := []lint.Problem{{
+		RuleID:   "core::0192::has-comments",
+		Message:  "Missing comment over \"UNKNOWN_STATE\".",
+		Location: &dpb.SourceCodeInfo_Location{Span: []int32{12, 2, 15}},
+	}}
+
+	out, fixes, _ := applyTestFixes(t, fixTestProto, problems, nil)
+	if len(fixes) != 0 || out != fixTestProto {
+		t.Fatalf("expected no comment to be written, got %v:\n%s", fixes, out)
+	}
+}
+
+func TestApplyFixes_SkipsReferencedNames(t *testing.T) {
+	// BookName is bound in an HTTP body, UNKNOWN_STATE is an option value
+	// in another file; neither reference would be renamed.
+	src := strings.Replace(fixTestProto, "// State is a state.", `service Books {
+  rpc CreateBook(Book) returns (Book) {
+    option (google.api.http) = {post: "/v1/books" body: "BookName"};
+  }
+}
+
+// State is a state.`, 1)
+	problems := []lint.Problem{
+		bookNameProblem,
+		{
+			RuleID:     unknownStateProblem.RuleID,
+			Suggestion: unknownStateProblem.Suggestion,
+			Location:   &dpb.SourceCodeInfo_Location{Span: []int32{18, 2, 15}},
+		},
+	}
+	refs := map[string]string{"shelf.proto": `syntax = "proto3";
+package acme.v1;
+message Shelf {
+  option (acme.v1.state) = UNKNOWN_STATE;
+}
+`}
+
+	out, fixes, skipped := applyTestFixes(t, src, problems, refs)
+	if len(fixes) != 0 || len(skipped) != 2 || out != src {
+		t.Fatalf("expected both fixes skipped, got %v, skipped %v:\n%s", fixes, skipped, out)
+	}
+
+	// Without the other file, only the HTTP body blocks its rename.
+	out, fixes, skipped = applyTestFixes(t, src, problems, nil)
+	if len(fixes) != 1 || fixes[0].Old != "UNKNOWN_STATE" || len(skipped) != 1 || skipped[0].Old != "BookName" {
+		t.Fatalf("fixes = %v, skipped = %v", fixes, skipped)
+	}
+	if !strings.Contains(out, "STATE_UNSPECIFIED = 0;") {
+		t.Errorf("output missing the enum rename:\n%s", out)
+	}
+}
+
+func TestApplyFixes_RenamesEveryDeclaration(t *testing.T) {
+	// The same field name in two messages is not a reference to either.
+	src := fixTestProto + `
+// Shelf is a shelf.
+message Shelf {
+  // The book name.
+  string BookName = 1;
+}
+`
+	problems := []lint.Problem{bookNameProblem, {
+		RuleID:     bookNameProblem.RuleID,
+		Suggestion: "book_name",
+		Location:   &dpb.SourceCodeInfo_Location{Span: []int32{18, 9, 17}},
+	}}
+
+	out, fixes, skipped := applyTestFixes(t, src, problems, nil)
+	if len(fixes) != 2 || len(skipped) != 0 || strings.Contains(out, "BookName") {
+		t.Fatalf("fixes = %v, skipped = %v:\n%s", fixes, skipped, out)
+	}
+}
@@ -7,13 +7,13 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"slices"
 	"strings"
 
 	"github.com/bufbuild/protocompile"
 	"github.com/bufbuild/protocompile/reporter"
 	"github.com/googleapis/api-linter/v2/lint"
 	"github.com/pubgo/redant"
-	"github.com/samber/lo"
 	"google.golang.org/protobuf/reflect/protoreflect"
 	"google.golang.org/protobuf/reflect/protoregistry"
 	"gopkg.in/yaml.v3"
@@ -29,7 +29,17 @@ type CliArgs struct {
 	DisabledRules []string
 	ListRulesFlag bool
 	DebugFlag     bool
+	FixFlag       bool
+	ChangedSince  string
 	// IgnoreCommentDisablesFlag bool
+
+	// enableRules and disableRules are the comma-separated rule flags,
+	// added to EnabledRules and DisabledRules.
+	enableRules  string
+	disableRules string
+
+	// changes caches the git diff for ChangedSince across directories.
+	changes changedFiles
 }
 
 // NewCli creates a new CLI arguments instance and options.
@@ -43,12 +53,36 @@ func NewCli() (*CliArgs, typex.Options) {
 		//	Value:       redant.BoolOf(&cliArgs.IgnoreCommentDisablesFlag),
 		//},
 
+		redant.Option{
+			Flag:        "enable-rule",
+			Description: "Enable the rules with the given names, separated by commas.",
+			Value:       redant.StringOf(&cliArgs.enableRules),
+		},
+
+		redant.Option{
+			Flag:        "disable-rule",
+			Description: "Disable the rules with the given names, separated by commas.",
+			Value:       redant.StringOf(&cliArgs.disableRules),
+		},
+
 		redant.Option{
 			Flag:        "debug",
 			Description: "Run in debug mode. Panics will print stack.",
 			Value:       redant.BoolOf(&cliArgs.DebugFlag),
 		},
 
+		redant.Option{
+			Flag:        "fix",
+			Description: "Apply suggested fixes for mechanical problems and rewrite the files in place.",
+			Value:       redant.BoolOf(&cliArgs.FixFlag),
+		},
+
+		redant.Option{
+			Flag:        "changed-since",
+			Description: "Lint only .proto files changed since the given git ref and\nreport only problems on changed lines.",
+			Value:       redant.StringOf(&cliArgs.ChangedSince),
+		},
+
 		redant.Option{
 			Flag:        "list-rules",
 			Description: "Print the rules and exit.  Honors the output-format flag.",
@@ -59,27 +93,147 @@ func NewCli() (*CliArgs, typex.Options) {
 
 // LinterConfig holds configuration for the linter.
 type LinterConfig struct {
-	Rules                     lint.Config `yaml:"rules,omitempty" hash:"-"`
-	FormatType                string      `yaml:"format_type"`
-	IgnoreCommentDisablesFlag bool        `yaml:"ignore_comment_disables_flag"`
+	Rules                     lint.Configs `yaml:"rules,omitempty" hash:"-"`
+	FormatType                string       `yaml:"format_type"`
+	IgnoreCommentDisablesFlag bool         `yaml:"ignore_comment_disables_flag"`
+
+	// Severity maps rule names or prefixes to a severity.
+	// Only error-level problems fail the lint.
+	Severity map[string]Severity `yaml:"severity,omitempty"`
+
+	// ReferenceFiles are the other project files --fix searches for uses of
+	// a name before renaming it.
+	ReferenceFiles []string `yaml:"-"`
+}
+
+// ProblemsError is returned by Linter when it finds error-level problems,
+// after they have been printed.
+type ProblemsError struct {
+	Errors int
 }
 
-// Linter runs the linter on the given proto files.
+func (e *ProblemsError) Error() string {
+	return fmt.Sprintf("%d lint errors", e.Errors)
+}
+
+// Linter runs the linter on the given proto files. Error-level problems are
+// reported as a *ProblemsError, so callers can lint more files before
+// failing.
 func Linter(c *CliArgs, config LinterConfig, protoImportPaths, protoFiles []string) error {
 	if c.ListRulesFlag {
 		return outputRules(config.FormatType)
 	}
 
+	for rule, sev := range config.Severity {
+		if _, err := ParseSeverity(string(sev)); err != nil {
+			return fmt.Errorf("linter severity for %s: %w", rule, err)
+		}
+	}
+
 	// Pre-check if there are files to lint.
 	if len(protoFiles) == 0 {
 		return fmt.Errorf("no file to lint")
 	}
 
-	rules := lint.Configs{config.Rules}
+	// Unchanged and unlinted files may still use names renamed by --fix.
+	refFiles := append(slices.Clone(protoFiles), config.ReferenceFiles...)
+
+	if c.ChangedSince != "" {
+		if c.changes == nil {
+			changes, err := gitChangedFiles(c.ChangedSince)
+			if err != nil {
+				return err
+			}
+			c.changes = changes
+		}
+
+		// Unchanged files are still compiled as imports, but not linted.
+		protoFiles = c.changes.filterFiles(protoFiles)
+		if len(protoFiles) == 0 {
+			return nil
+		}
+	}
+
+	results, err := lintChanged(c, config, protoImportPaths, protoFiles)
+	if err != nil {
+		return err
+	}
+
+	if c.FixFlag {
+		fixes, skipped, err := ApplyFixes(results, refFiles)
+		if err != nil {
+			return err
+		}
+
+		for _, fix := range skipped {
+			fmt.Fprintf(os.Stderr, "⏭️  %s skipped, %q is used elsewhere\n", fix, fix.Old)
+		}
+
+		if len(fixes) > 0 {
+			for _, fix := range fixes {
+				fmt.Fprintf(os.Stderr, "🔧 %s\n", fix)
+			}
+			fmt.Fprintf(os.Stderr, "✅ Applied %d fixes\n", len(fixes))
+
+			// Lint again so only the remaining problems are reported.
+			results, err = lintChanged(c, config, protoImportPaths, protoFiles)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	// Determine the format for printing the results.
+	// YAML format is the default.
+	marshal := getOutputFormatFunc(config.FormatType)
+
+	if strings.EqualFold(config.FormatType, "github") {
+		marshal = func(any) ([]byte, error) { return formatGitHubActionOutput(results, config.SeverityOf), nil }
+	}
+
+	// Print the results, with the severity of each problem.
+	output, err := withSeverity(results, config.SeverityOf)
+	if err != nil {
+		return err
+	}
+	b, err := marshal(output)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(string(b))
+
+	counts := severityCounts(config, results)
+	if len(counts) > 0 {
+		fmt.Fprintf(os.Stderr, "❌ %d errors, ⚠️ %d warnings, ℹ️ %d info\n",
+			counts[SeverityError], counts[SeverityWarning], counts[SeverityInfo])
+	}
+
+	// Only errors fail the lint; warnings and info are reported only.
+	if counts[SeverityError] > 0 {
+		return &ProblemsError{Errors: counts[SeverityError]}
+	}
+
+	return nil
+}
+
+// lintChanged lints the files and, with --changed-since, keeps only the
+// problems on changed lines.
+func lintChanged(c *CliArgs, config LinterConfig, protoImportPaths, protoFiles []string) ([]lint.Response, error) {
+	results, err := lintFiles(c, config, protoImportPaths, protoFiles)
+	if err != nil || c.changes == nil {
+		return results, err
+	}
+	return c.changes.filterProblems(results), nil
+}
+
+// lintFiles compiles the proto files and runs the configured rules against them.
+func lintFiles(c *CliArgs, config LinterConfig, protoImportPaths, protoFiles []string) ([]lint.Response, error) {
+	rules := append(lint.Configs{}, config.Rules...)
 
 	// Add configs for the enabled rules.
-	rules = append(rules, lint.Config{EnabledRules: c.EnabledRules})
-	rules = append(rules, lint.Config{DisabledRules: c.DisabledRules})
+	rules = append(rules, lint.Config{EnabledRules: append(slices.Clone(c.EnabledRules), splitRules(c.enableRules)...)})
+	rules = append(rules, lint.Config{DisabledRules: append(slices.Clone(c.DisabledRules), splitRules(c.disableRules)...)})
 
 	// Create resolver for source files with import paths.
 	importPaths := append(protoImportPaths, ".")
@@ -119,11 +273,11 @@ func Linter(c *CliArgs, config LinterConfig, protoImportPaths, protoFiles []stri
 		for i, e := range collectedErrors {
 			errStrings[i] = e.Error()
 		}
-		return errors.New(strings.Join(errStrings, "\n"))
+		return nil, errors.New(strings.Join(errStrings, "\n"))
 	}
 
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	// Convert to protoreflect.FileDescriptor slice.
@@ -134,29 +288,13 @@ func Linter(c *CliArgs, config LinterConfig, protoImportPaths, protoFiles []stri
 
 	// Create a Linter to lint the file descriptors.
 	l := lint.New(globalRules, rules, lint.Debug(c.DebugFlag), lint.IgnoreCommentDisables(config.IgnoreCommentDisablesFlag))
-	results, err := l.LintProtos(fileDescriptors...)
-	if err != nil {
-		return err
-	}
-
-	// Determine the format for printing the results.
-	// YAML format is the default.
-	marshal := getOutputFormatFunc(config.FormatType)
-
-	// Print the results.
-	b, err := marshal(results)
-	if err != nil {
-		return err
-	}
-
-	fmt.Println(string(b))
-
-	filterResults := lo.Filter(results, func(item lint.Response, _ int) bool { return len(item.Problems) > 0 })
-	if len(filterResults) > 0 {
-		os.Exit(1)
-	}
+	return l.LintProtos(fileDescriptors...)
+}
 
-	return nil
+// LintDescriptors runs the configured rules against already compiled files.
+func LintDescriptors(config LinterConfig, files ...protoreflect.FileDescriptor) ([]lint.Response, error) {
+	l := lint.New(globalRules, config.Rules, lint.IgnoreCommentDisables(config.IgnoreCommentDisablesFlag))
+	return l.LintProtos(files...)
 }
 
 var outputFormatFuncs = map[string]formatFunc{
@@ -166,7 +304,7 @@ var outputFormatFuncs = map[string]formatFunc{
 	"github": func(i any) ([]byte, error) {
 		switch v := i.(type) {
 		case []lint.Response:
-			return formatGitHubActionOutput(v), nil
+			return formatGitHubActionOutput(v, nil), nil
 		default:
 			return json.Marshal(v)
 		}
@@ -181,3 +319,14 @@ func getOutputFormatFunc(formatType string) formatFunc {
 	}
 	return yaml.Marshal
 }
+
+// splitRules splits a comma-separated list of rule names.
+func splitRules(s string) []string {
+	var rules []string
+	for _, rule := range strings.Split(s, ",") {
+		if rule = strings.TrimSpace(rule); rule != "" {
+			rules = append(rules, rule)
+		}
+	}
+	return rules
+}
@@ -0,0 +1,43 @@
+package linters
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestLinter_ReturnsProblems(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "book.proto"), []byte(fixTestProto), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Chdir(dir)
+
+	args := &CliArgs{EnabledRules: []string{"core::0140::lower-snake"}}
+	config := LinterConfig{FormatType: "json"}
+
+	// Errors are returned rather than exiting, so later directories still run.
+	err := Linter(args, config, []string{dir}, []string{"book.proto"})
+	var problems *ProblemsError
+	if !errors.As(err, &problems) || problems.Errors == 0 {
+		t.Fatalf("Linter() = %v, want a ProblemsError", err)
+	}
+
+	config.Severity = map[string]Severity{"core": SeverityWarning}
+	if err := Linter(args, config, []string{dir}, []string{"book.proto"}); err != nil {
+		t.Errorf("Linter() = %v, want warnings only", err)
+	}
+}
+
+func TestSplitRules(t *testing.T) {
+	got := splitRules(" core::0192, ,core::0140::lower-snake,")
+	want := []string{"core::0192", "core::0140::lower-snake"}
+	if !slices.Equal(got, want) {
+		t.Errorf("splitRules() = %q, want %q", got, want)
+	}
+	if got := splitRules(""); got != nil {
+		t.Errorf("splitRules(\"\") = %q, want nil", got)
+	}
+}
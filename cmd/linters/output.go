@@ -2,16 +2,23 @@ package linters
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
-	"path/filepath"
 	"strings"
 
 	"github.com/googleapis/api-linter/v2/lint"
-	"github.com/samber/lo"
 )
 
+// githubLevels maps severities to GitHub workflow command names.
+var githubLevels = map[Severity]string{
+	SeverityError:   "error",
+	SeverityWarning: "warning",
+	SeverityInfo:    "notice",
+}
+
 // formatGitHubActionOutput returns lint errors in GitHub actions format.
-func formatGitHubActionOutput(responses []lint.Response) []byte {
+// severityOf maps rules to annotation levels; nil reports every problem as an error.
+func formatGitHubActionOutput(responses []lint.Response, severityOf func(lint.RuleName) Severity) []byte {
 	var buf bytes.Buffer
 	for _, response := range responses {
 		for _, problem := range response.Problems {
@@ -19,8 +26,11 @@ func formatGitHubActionOutput(responses []lint.Response) []byte {
 			// ::error file={name},line={line},endLine={endLine},title={title}::{message}
 			// https://docs.github.com/en/actions/using-workflows/workflow-commands-for-github-actions#setting-an-error-message
 
-			fmt.Println(lo.Must(filepath.Abs(response.FilePath)))
-			fmt.Fprintf(&buf, "::error file=%s", response.FilePath)
+			level := "error"
+			if severityOf != nil {
+				level = githubLevels[severityOf(problem.RuleID)]
+			}
+			fmt.Fprintf(&buf, "::%s file=%s", level, response.FilePath)
 			if problem.Location != nil {
 				// Some findings are *line level* and only have start positions but no
 				// starting column. Construct a switch fallthrough to emit as many of
@@ -56,3 +66,35 @@ func formatGitHubActionOutput(responses []lint.Response) []byte {
 
 	return buf.Bytes()
 }
+
+// responseOutput is a lint response as printed by the yaml and json formats.
+type responseOutput struct {
+	FilePath string          `json:"file_path" yaml:"file_path"`
+	Problems []problemOutput `json:"problems" yaml:"problems"`
+}
+
+// problemOutput is a problem in the api-linter encoding with the severity
+// of its rule added.
+type problemOutput map[string]any
+
+// withSeverity adds the severity of each problem to the responses.
+func withSeverity(responses []lint.Response, severityOf func(lint.RuleName) Severity) ([]responseOutput, error) {
+	out := make([]responseOutput, 0, len(responses))
+	for _, response := range responses {
+		r := responseOutput{FilePath: response.FilePath, Problems: make([]problemOutput, 0, len(response.Problems))}
+		for _, problem := range response.Problems {
+			data, err := problem.MarshalJSON()
+			if err != nil {
+				return nil, err
+			}
+			var p problemOutput
+			if err := json.Unmarshal(data, &p); err != nil {
+				return nil, err
+			}
+			p["severity"] = severityOf(problem.RuleID)
+			r.Problems = append(r.Problems, p)
+		}
+		out = append(out, r)
+	}
+	return out, nil
+}
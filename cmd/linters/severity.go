@@ -0,0 +1,58 @@
+package linters
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/googleapis/api-linter/v2/lint"
+)
+
+// Severity is the level reported for a lint rule.
+type Severity string
+
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+	SeverityInfo    Severity = "info"
+)
+
+// ParseSeverity parses a severity name, case-insensitively.
+func ParseSeverity(s string) (Severity, error) {
+	switch sev := Severity(strings.ToLower(strings.TrimSpace(s))); sev {
+	case SeverityError, SeverityWarning, SeverityInfo:
+		return sev, nil
+	default:
+		return "", fmt.Errorf("invalid severity %q, expected one of: error, warning, info", s)
+	}
+}
+
+// SeverityOf returns the severity configured for the rule. Keys may name a
+// rule or a rule prefix (e.g. "core::0192"); the most specific key wins and
+// rules without a configured severity are errors.
+func (c LinterConfig) SeverityOf(rule lint.RuleName) Severity {
+	sev, matched := SeverityError, ""
+	for key, s := range c.Severity {
+		if len(key) <= len(matched) || !matchRulePrefix(rule, key) {
+			continue
+		}
+		sev, matched = s, key
+	}
+	return sev
+}
+
+// matchRulePrefix reports whether rule equals prefix or is nested under it.
+func matchRulePrefix(rule lint.RuleName, prefix string) bool {
+	name := string(rule)
+	return name == prefix || strings.HasPrefix(name, prefix+"::")
+}
+
+// severityCounts counts problems by severity.
+func severityCounts(config LinterConfig, responses []lint.Response) map[Severity]int {
+	counts := make(map[Severity]int)
+	for _, resp := range responses {
+		for _, problem := range resp.Problems {
+			counts[config.SeverityOf(problem.RuleID)]++
+		}
+	}
+	return counts
+}
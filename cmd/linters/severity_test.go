@@ -0,0 +1,102 @@
+package linters
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/googleapis/api-linter/v2/lint"
+)
+
+func TestLinterConfig_SeverityOf(t *testing.T) {
+	config := LinterConfig{
+		Severity: map[string]Severity{
+			"core":                     SeverityInfo,
+			"core::0192":               SeverityWarning,
+			"core::0192::has-comments": SeverityError,
+		},
+	}
+
+	tests := []struct {
+		rule lint.RuleName
+		want Severity
+	}{
+		{"core::0192::has-comments", SeverityError},
+		{"core::0192::only-leading-comments", SeverityWarning},
+		{"core::0131::http-method", SeverityInfo},
+		{"client-libraries::4232::repeated-fields", SeverityError},
+		{"core::01921::made-up", SeverityInfo},
+	}
+
+	for _, tt := range tests {
+		if got := config.SeverityOf(tt.rule); got != tt.want {
+			t.Errorf("SeverityOf(%s) = %s, expected %s", tt.rule, got, tt.want)
+		}
+	}
+}
+
+func TestParseSeverity(t *testing.T) {
+	for _, s := range []string{"error", "Warning", " info "} {
+		if _, err := ParseSeverity(s); err != nil {
+			t.Errorf("ParseSeverity(%q) returned error: %v", s, err)
+		}
+	}
+
+	if _, err := ParseSeverity("fatal"); err == nil {
+		t.Error("expected error for unknown severity")
+	}
+}
+
+func TestFormatGitHubActionOutput_Severity(t *testing.T) {
+	config := LinterConfig{Severity: map[string]Severity{"core::0192": SeverityWarning}}
+	responses := []lint.Response{{
+		FilePath: "a.proto",
+		Problems: []lint.Problem{
+			{RuleID: "core::0192::has-comments", Message: "missing comment"},
+			{RuleID: "core::0131::http-method", Message: "bad method"},
+		},
+	}}
+
+	out := string(formatGitHubActionOutput(responses, config.SeverityOf))
+	if !strings.Contains(out, "::warning file=a.proto") {
+		t.Errorf("expected warning annotation, got:\n%s", out)
+	}
+	if !strings.Contains(out, "::error file=a.proto") {
+		t.Errorf("expected error annotation, got:\n%s", out)
+	}
+
+	counts := severityCounts(config, responses)
+	if counts[SeverityError] != 1 || counts[SeverityWarning] != 1 {
+		t.Errorf("unexpected counts: %v", counts)
+	}
+}
+
+func TestWithSeverity(t *testing.T) {
+	config := LinterConfig{Severity: map[string]Severity{"core::0192": SeverityWarning}}
+	responses := []lint.Response{{
+		FilePath: "a.proto",
+		Problems: []lint.Problem{
+			{RuleID: "core::0192::has-comments", Message: "missing comment"},
+			{RuleID: "core::0131::http-method", Message: "bad method"},
+		},
+	}}
+
+	output, err := withSeverity(responses, config.SeverityOf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for format, want := range map[string][]string{
+		"yaml": {"severity: warning", "severity: error", "message: missing comment"},
+		"json": {`"severity":"warning"`, `"severity":"error"`, `"file_path":"a.proto"`},
+	} {
+		b, err := getOutputFormatFunc(format)(output)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, w := range want {
+			if !strings.Contains(string(b), w) {
+				t.Errorf("%s output missing %q:\n%s", format, w, b)
+			}
+		}
+	}
+}
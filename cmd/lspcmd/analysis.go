@@ -0,0 +1,294 @@
+package lspcmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/bufbuild/protocompile"
+	"github.com/bufbuild/protocompile/ast"
+	"github.com/bufbuild/protocompile/linker"
+	"github.com/bufbuild/protocompile/reporter"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// workspace resolves files the way protobuild does: through the configured
+// includes and vendor directory, preferring the content of open documents.
+type workspace struct {
+	// importPaths are absolute, in resolution order.
+	importPaths []string
+	docs        map[string]*document
+}
+
+func newWorkspace(cfg *ProjectConfig) *workspace {
+	var paths []string
+	seen := make(map[string]bool)
+	for _, p := range append(append(append([]string{}, cfg.Includes...), cfg.Vendor), ".") {
+		if p == "" {
+			continue
+		}
+		abs, err := filepath.Abs(p)
+		if err != nil || seen[abs] {
+			continue
+		}
+		seen[abs] = true
+		paths = append(paths, abs)
+	}
+	return &workspace{importPaths: paths, docs: make(map[string]*document)}
+}
+
+// importName returns the name a file is imported by, relative to the first
+// import path that contains it.
+func (w *workspace) importName(path string) string {
+	for _, root := range w.importPaths {
+		rel, err := filepath.Rel(root, path)
+		if err == nil && !strings.HasPrefix(rel, "..") {
+			return filepath.ToSlash(rel)
+		}
+	}
+	return filepath.ToSlash(filepath.Base(path))
+}
+
+// diskPath returns the file an import name resolves to, or "" if it is not
+// on disk (e.g. the standard google/protobuf imports).
+func (w *workspace) diskPath(name string) string {
+	for _, root := range w.importPaths {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if _, ok := w.docs[path]; ok {
+			return path
+		}
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return ""
+}
+
+// load returns the open document for path, or reads it from disk.
+func (w *workspace) load(path string) (*document, error) {
+	if doc, ok := w.docs[path]; ok {
+		return doc, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return newDocument(pathToURI(path), path, string(data)), nil
+}
+
+// compile compiles doc with its imports. On failure it returns the errors
+// reported by the compiler.
+func (w *workspace) compile(doc *document) (linker.Result, linker.Files, []reporter.ErrorWithPos) {
+	var errs []reporter.ErrorWithPos
+	rep := reporter.NewReporter(func(err reporter.ErrorWithPos) error {
+		errs = append(errs, err)
+		return nil
+	}, nil)
+
+	compiler := protocompile.Compiler{
+		Resolver: protocompile.WithStandardImports(&protocompile.SourceResolver{
+			ImportPaths: w.importPaths,
+			Accessor: func(path string) (io.ReadCloser, error) {
+				if doc, ok := w.docs[path]; ok {
+					return io.NopCloser(strings.NewReader(doc.text)), nil
+				}
+				return os.Open(path)
+			},
+		}),
+		SourceInfoMode: protocompile.SourceInfoStandard,
+		Reporter:       rep,
+		// Definitions and hover need the AST of the document.
+		RetainASTs: true,
+	}
+
+	name := w.importName(doc.path)
+	files, err := compiler.Compile(context.Background(), name)
+	if len(errs) == 0 && err != nil {
+		var ewp reporter.ErrorWithPos
+		if !errors.As(err, &ewp) {
+			ewp = reporter.Error(ast.UnknownSpan(name), err)
+		}
+		errs = append(errs, ewp)
+	}
+	if len(errs) > 0 || len(files) == 0 {
+		return nil, nil, errs
+	}
+
+	res, ok := files[0].(linker.Result)
+	if !ok {
+		return nil, nil, []reporter.ErrorWithPos{reporter.Error(ast.UnknownSpan(name), fmt.Errorf("no source available for %s", name))}
+	}
+	return res, transitiveFiles(res), nil
+}
+
+// transitiveFiles returns f and all of its transitive imports.
+func transitiveFiles(f linker.File) linker.Files {
+	var files linker.Files
+	seen := make(map[string]bool)
+
+	var visit func(fd protoreflect.FileDescriptor)
+	visit = func(fd protoreflect.FileDescriptor) {
+		if fd == nil || seen[fd.Path()] {
+			return
+		}
+		seen[fd.Path()] = true
+
+		lf, ok := fd.(linker.File)
+		if !ok {
+			var err error
+			if lf, err = linker.NewFileRecursive(fd); err != nil {
+				return
+			}
+		}
+		files = append(files, lf)
+
+		imports := fd.Imports()
+		for i := 0; i < imports.Len(); i++ {
+			visit(imports.Get(i).FileDescriptor)
+		}
+	}
+	visit(f)
+	return files
+}
+
+// scopeAt returns the fully-qualified scope at offset: the package followed
+// by the enclosing messages.
+func scopeAt(res linker.Result, offset int) []string {
+	var scope []string
+	if pkg := string(res.Package()); pkg != "" {
+		scope = strings.Split(pkg, ".")
+	}
+
+	fileNode := res.AST()
+	_ = ast.Walk(fileNode, &ast.SimpleVisitor{
+		DoVisitMessageNode: func(n *ast.MessageNode) error {
+			info := fileNode.NodeInfo(n)
+			// Walk visits parents before children, so nested messages append in order.
+			if info.Start().Offset <= offset && offset < info.End().Offset {
+				scope = append(scope, n.Name.Val)
+			}
+			return nil
+		},
+	})
+	return scope
+}
+
+// resolveName resolves a type or element name used in scope, following the
+// protobuf rule of searching from the innermost scope outwards.
+func resolveName(files linker.Files, scope []string, name string) protoreflect.Descriptor {
+	resolver := files.AsResolver()
+	find := func(fqn string) protoreflect.Descriptor {
+		d, err := resolver.FindDescriptorByName(protoreflect.FullName(fqn))
+		if err != nil {
+			return nil
+		}
+		return d
+	}
+
+	if strings.HasPrefix(name, ".") {
+		return find(name[1:])
+	}
+
+	for i := len(scope); i >= 0; i-- {
+		fqn := name
+		if i > 0 {
+			fqn = strings.Join(scope[:i], ".") + "." + name
+		}
+		if d := find(fqn); d != nil {
+			return d
+		}
+	}
+	return nil
+}
+
+// importAt returns the import path of the import statement at offset.
+func importAt(fileNode *ast.FileNode, offset int) (string, bool) {
+	for _, decl := range fileNode.Decls {
+		imp, ok := decl.(*ast.ImportNode)
+		if !ok {
+			continue
+		}
+		info := fileNode.NodeInfo(imp.Name)
+		if info.Start().Offset <= offset && offset < info.End().Offset {
+			return imp.Name.AsString(), true
+		}
+	}
+	return "", false
+}
+
+// declarationOf returns the document declaring d and the range of its name.
+func (w *workspace) declarationOf(d protoreflect.Descriptor) (*document, Range, bool) {
+	path := w.diskPath(d.ParentFile().Path())
+	if path == "" {
+		return nil, Range{}, false
+	}
+
+	doc, err := w.load(path)
+	if err != nil {
+		return nil, Range{}, false
+	}
+
+	locs := d.ParentFile().SourceLocations()
+	loc := locs.ByDescriptor(d)
+	if loc.Path == nil {
+		return doc, Range{}, true
+	}
+
+	// Field 1 is the name in every declaration that can be looked up by name.
+	if nameLoc := locs.ByPath(append(append(protoreflect.SourcePath{}, loc.Path...), 1)); nameLoc.Path != nil {
+		loc = nameLoc
+	}
+
+	start := doc.visualOffset(loc.StartLine, loc.StartColumn)
+	end := doc.visualOffset(loc.EndLine, loc.EndColumn)
+	return doc, doc.rangeOf(start, end), true
+}
+
+// describe returns a short proto-like signature for d.
+func describe(d protoreflect.Descriptor) string {
+	switch d := d.(type) {
+	case protoreflect.MessageDescriptor:
+		return "message " + string(d.FullName())
+	case protoreflect.EnumDescriptor:
+		return "enum " + string(d.FullName())
+	case protoreflect.EnumValueDescriptor:
+		return fmt.Sprintf("%s = %d", d.FullName(), d.Number())
+	case protoreflect.ServiceDescriptor:
+		return "service " + string(d.FullName())
+	case protoreflect.MethodDescriptor:
+		in, out := string(d.Input().FullName()), string(d.Output().FullName())
+		if d.IsStreamingClient() {
+			in = "stream " + in
+		}
+		if d.IsStreamingServer() {
+			out = "stream " + out
+		}
+		return fmt.Sprintf("rpc %s(%s) returns (%s)", d.Name(), in, out)
+	case protoreflect.FieldDescriptor:
+		return fmt.Sprintf("%s %s = %d", fieldType(d), d.FullName(), d.Number())
+	default:
+		return string(d.FullName())
+	}
+}
+
+func fieldType(fd protoreflect.FieldDescriptor) string {
+	if fd.IsMap() {
+		return fmt.Sprintf("map<%s, %s>", fieldType(fd.MapKey()), fieldType(fd.MapValue()))
+	}
+
+	typ := fd.Kind().String()
+	switch {
+	case fd.Message() != nil:
+		typ = string(fd.Message().FullName())
+	case fd.Enum() != nil:
+		typ = string(fd.Enum().FullName())
+	}
+	if fd.IsList() {
+		typ = "repeated " + typ
+	}
+	return typ
+}
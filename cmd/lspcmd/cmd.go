@@ -0,0 +1,50 @@
+// Package lspcmd provides a language server for protobuf files.
+package lspcmd
+
+import (
+	"context"
+	"os"
+
+	"github.com/pubgo/redant"
+
+	"github.com/pubgo/protobuild/cmd/format"
+	"github.com/pubgo/protobuild/cmd/linters"
+)
+
+// ProjectConfig holds project configuration from protobuf.yaml.
+type ProjectConfig struct {
+	// Vendor directory
+	Vendor string
+	// Include paths
+	Includes []string
+	// Linter configuration used for diagnostics
+	Linter linters.LinterConfig
+	// Builtin formatter options
+	Format format.Options
+}
+
+// New creates a new lsp command.
+// The configProvider function is called to get project configuration.
+func New(name string, configProvider func() *ProjectConfig) *redant.Command {
+	return &redant.Command{
+		Use:   name,
+		Short: "Run the protobuf language server over stdio",
+		Long: `Run a Language Server Protocol server over stdin/stdout.
+
+Imports are resolved with the includes and vendor directory from protobuf.yaml,
+so editors can navigate vendored dependencies.
+
+Supported features:
+  - diagnostics from the compiler and AIP lint rules
+  - formatting with the builtin formatter
+  - go to definition and hover across imports
+  - document symbols
+
+Examples:
+  # VS Code / Neovim: configure the language server command as
+  protobuild lsp`,
+		Handler: func(ctx context.Context, inv *redant.Invocation) error {
+			return NewServer(configProvider()).Serve(ctx, os.Stdin, os.Stdout)
+		},
+	}
+}
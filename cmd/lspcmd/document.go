@@ -0,0 +1,165 @@
+package lspcmd
+
+import (
+	"net/url"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// document is the text of a file with a line index for position conversion.
+type document struct {
+	uri  string
+	path string
+	text string
+
+	// lines holds the byte offset at which each line starts.
+	lines []int
+}
+
+func newDocument(uri, path, text string) *document {
+	lines := []int{0}
+	for i := 0; i < len(text); i++ {
+		if text[i] == '\n' {
+			lines = append(lines, i+1)
+		}
+	}
+	return &document{uri: uri, path: path, text: text, lines: lines}
+}
+
+// lineText returns the text of the zero-based line without its line ending.
+func (d *document) lineText(line int) string {
+	if line < 0 || line >= len(d.lines) {
+		return ""
+	}
+	end := len(d.text)
+	if line+1 < len(d.lines) {
+		end = d.lines[line+1]
+	}
+	return strings.TrimRight(d.text[d.lines[line]:end], "\r\n")
+}
+
+// offset converts an LSP position to a byte offset, clamping to the document.
+func (d *document) offset(pos Position) int {
+	if pos.Line < 0 {
+		return 0
+	}
+	if pos.Line >= len(d.lines) {
+		return len(d.text)
+	}
+
+	start := d.lines[pos.Line]
+	line := d.lineText(pos.Line)
+	units := 0
+	for i, r := range line {
+		if units >= pos.Character {
+			return start + i
+		}
+		units += utf16Len(r)
+	}
+	return start + len(line)
+}
+
+// position converts a byte offset to an LSP position.
+func (d *document) position(offset int) Position {
+	offset = max(0, min(offset, len(d.text)))
+	line := sort.Search(len(d.lines), func(i int) bool { return d.lines[i] > offset }) - 1
+
+	units := 0
+	for _, r := range d.text[d.lines[line]:offset] {
+		units += utf16Len(r)
+	}
+	return Position{Line: line, Character: units}
+}
+
+// visualOffset converts a zero-based line and column, counted the way
+// protocompile does (8-wide tab stops, one column per rune), to a byte offset.
+func (d *document) visualOffset(line, col int) int {
+	if line >= len(d.lines) {
+		return len(d.text)
+	}
+
+	start := d.lines[line]
+	text := d.lineText(line)
+	visual := 0
+	for i, r := range text {
+		if visual >= col {
+			return start + i
+		}
+		if r == '\t' {
+			visual += 8 - visual%8
+		} else {
+			visual++
+		}
+	}
+	return start + len(text)
+}
+
+// span converts a SourceCodeInfo span to a range.
+func (d *document) span(span []int32) Range {
+	var startLine, startCol, endLine, endCol int
+	switch len(span) {
+	case 3:
+		startLine, startCol, endLine, endCol = int(span[0]), int(span[1]), int(span[0]), int(span[2])
+	case 4:
+		startLine, startCol, endLine, endCol = int(span[0]), int(span[1]), int(span[2]), int(span[3])
+	default:
+		return Range{}
+	}
+	return Range{
+		Start: d.position(d.visualOffset(startLine, startCol)),
+		End:   d.position(d.visualOffset(endLine, endCol)),
+	}
+}
+
+// rangeOf returns the range between two byte offsets.
+func (d *document) rangeOf(start, end int) Range {
+	return Range{Start: d.position(start), End: d.position(end)}
+}
+
+// fullRange covers the whole document.
+func (d *document) fullRange() Range {
+	return d.rangeOf(0, len(d.text))
+}
+
+// wordAt returns the (possibly dotted) identifier around offset and its bounds.
+func (d *document) wordAt(offset int) (word string, start, end int) {
+	isIdent := func(b byte) bool {
+		return b == '_' || b == '.' || b >= '0' && b <= '9' || b >= 'a' && b <= 'z' || b >= 'A' && b <= 'Z'
+	}
+
+	start, end = offset, offset
+	for start > 0 && isIdent(d.text[start-1]) {
+		start--
+	}
+	for end < len(d.text) && isIdent(d.text[end]) {
+		end++
+	}
+	return d.text[start:end], start, end
+}
+
+func utf16Len(r rune) int {
+	if r >= 0x10000 && r <= utf8.MaxRune {
+		return 2
+	}
+	return 1
+}
+
+// uriToPath converts a file:// URI to a local path.
+func uriToPath(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "file" {
+		return uri
+	}
+	return filepath.FromSlash(u.Path)
+}
+
+// pathToURI converts a local path to a file:// URI.
+func pathToURI(path string) string {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		abs = path
+	}
+	return (&url.URL{Scheme: "file", Path: filepath.ToSlash(abs)}).String()
+}
@@ -0,0 +1,475 @@
+package lspcmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"strings"
+
+	"github.com/bufbuild/protocompile/ast"
+	"github.com/bufbuild/protocompile/parser"
+	"github.com/bufbuild/protocompile/reporter"
+	"github.com/googleapis/api-linter/v2/lint"
+
+	"github.com/pubgo/protobuild/cmd/format"
+	"github.com/pubgo/protobuild/cmd/linters"
+)
+
+// Server is a language server for protobuf files.
+type Server struct {
+	cfg    *ProjectConfig
+	ws     *workspace
+	conn   *conn
+	logger *slog.Logger
+
+	shutdown bool
+}
+
+// NewServer creates a server for the given project configuration.
+func NewServer(cfg *ProjectConfig) *Server {
+	return &Server{
+		cfg:    cfg,
+		ws:     newWorkspace(cfg),
+		logger: slog.Default(),
+	}
+}
+
+// Serve handles requests from r and writes responses to w until the client
+// sends exit or closes the stream.
+func (s *Server) Serve(ctx context.Context, r io.Reader, w io.Writer) error {
+	s.conn = newConn(r, w)
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		msg, err := s.conn.read()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			var rpcErr *rpcError
+			if errors.As(err, &rpcErr) {
+				_ = s.conn.reply(nil, nil, rpcErr)
+				continue
+			}
+			return err
+		}
+
+		if msg.Method == "exit" {
+			if !s.shutdown {
+				return errors.New("exit without shutdown")
+			}
+			return nil
+		}
+
+		result, err := s.handle(msg)
+		if msg.isNotification() {
+			if err != nil {
+				s.logger.Error("lsp notification failed", "method", msg.Method, "err", err)
+			}
+			continue
+		}
+
+		if err := s.conn.reply(msg.ID, result, err); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *Server) handle(msg *message) (any, error) {
+	switch msg.Method {
+	case "initialize":
+		return InitializeResult{
+			Capabilities: ServerCapabilities{
+				TextDocumentSync: TextDocumentSyncOptions{
+					OpenClose: true,
+					Change:    TextDocumentSyncKindFull,
+					Save:      SaveOptions{IncludeText: true},
+				},
+				DocumentFormattingProvider: true,
+				DefinitionProvider:         true,
+				HoverProvider:              true,
+				DocumentSymbolProvider:     true,
+			},
+			ServerInfo: ServerInfo{Name: "protobuild"},
+		}, nil
+
+	case "initialized", "$/cancelRequest", "$/setTrace", "workspace/didChangeConfiguration":
+		return nil, nil
+
+	case "shutdown":
+		s.shutdown = true
+		return nil, nil
+
+	case "textDocument/didOpen":
+		var params DidOpenTextDocumentParams
+		if err := unmarshalParams(msg, &params); err != nil {
+			return nil, err
+		}
+		return nil, s.update(params.TextDocument.URI, params.TextDocument.Text)
+
+	case "textDocument/didChange":
+		var params DidChangeTextDocumentParams
+		if err := unmarshalParams(msg, &params); err != nil {
+			return nil, err
+		}
+		if len(params.ContentChanges) == 0 {
+			return nil, nil
+		}
+		return nil, s.update(params.TextDocument.URI, params.ContentChanges[len(params.ContentChanges)-1].Text)
+
+	case "textDocument/didSave":
+		var params DidSaveTextDocumentParams
+		if err := unmarshalParams(msg, &params); err != nil {
+			return nil, err
+		}
+		if params.Text != nil {
+			return nil, s.update(params.TextDocument.URI, *params.Text)
+		}
+		if doc, ok := s.ws.docs[s.pathOf(params.TextDocument.URI)]; ok {
+			return nil, s.publishDiagnostics(doc)
+		}
+		return nil, nil
+
+	case "textDocument/didClose":
+		var params DidCloseTextDocumentParams
+		if err := unmarshalParams(msg, &params); err != nil {
+			return nil, err
+		}
+		delete(s.ws.docs, s.pathOf(params.TextDocument.URI))
+		return nil, s.conn.notify("textDocument/publishDiagnostics", PublishDiagnosticsParams{
+			URI:         params.TextDocument.URI,
+			Diagnostics: []Diagnostic{},
+		})
+
+	case "textDocument/formatting":
+		var params DocumentFormattingParams
+		if err := unmarshalParams(msg, &params); err != nil {
+			return nil, err
+		}
+		return s.formatting(params.TextDocument.URI)
+
+	case "textDocument/definition":
+		var params TextDocumentPositionParams
+		if err := unmarshalParams(msg, &params); err != nil {
+			return nil, err
+		}
+		return s.definition(params)
+
+	case "textDocument/hover":
+		var params TextDocumentPositionParams
+		if err := unmarshalParams(msg, &params); err != nil {
+			return nil, err
+		}
+		return s.hover(params)
+
+	case "textDocument/documentSymbol":
+		var params DocumentSymbolParams
+		if err := unmarshalParams(msg, &params); err != nil {
+			return nil, err
+		}
+		return s.documentSymbols(params.TextDocument.URI)
+
+	default:
+		return nil, &rpcError{Code: codeMethodNotFound, Message: "method not supported: " + msg.Method}
+	}
+}
+
+func unmarshalParams(msg *message, v any) error {
+	if err := json.Unmarshal(msg.Params, v); err != nil {
+		return &rpcError{Code: codeInvalidParams, Message: err.Error()}
+	}
+	return nil
+}
+
+func (s *Server) pathOf(uri string) string {
+	path := uriToPath(uri)
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	return filepath.Clean(path)
+}
+
+// document returns the open document for uri, falling back to disk.
+func (s *Server) document(uri string) (*document, error) {
+	doc, err := s.ws.load(s.pathOf(uri))
+	if err != nil {
+		return nil, &rpcError{Code: codeInternalError, Message: err.Error()}
+	}
+	return doc, nil
+}
+
+// update stores the new document text and republishes diagnostics.
+func (s *Server) update(uri, text string) error {
+	path := s.pathOf(uri)
+	doc := newDocument(uri, path, text)
+	s.ws.docs[path] = doc
+	return s.publishDiagnostics(doc)
+}
+
+// publishDiagnostics reports compiler errors, or lint problems if the
+// document compiles.
+func (s *Server) publishDiagnostics(doc *document) error {
+	diagnostics := []Diagnostic{}
+
+	res, _, errs := s.ws.compile(doc)
+	name := s.ws.importName(doc.path)
+	for _, err := range errs {
+		diagnostics = append(diagnostics, compileDiagnostic(doc, name, err))
+	}
+
+	if res != nil {
+		responses, err := linters.LintDescriptors(s.cfg.Linter, res)
+		if err != nil {
+			s.logger.Error("lint failed", "file", doc.path, "err", err)
+		}
+		for _, resp := range responses {
+			for _, problem := range resp.Problems {
+				diagnostics = append(diagnostics, s.lintDiagnostic(doc, problem))
+			}
+		}
+	}
+
+	return s.conn.notify("textDocument/publishDiagnostics", PublishDiagnosticsParams{
+		URI:         doc.uri,
+		Diagnostics: diagnostics,
+	})
+}
+
+func compileDiagnostic(doc *document, name string, err reporter.ErrorWithPos) Diagnostic {
+	diag := Diagnostic{
+		Severity: SeverityError,
+		Source:   "protobuild",
+		Message:  err.Unwrap().Error(),
+	}
+
+	start, end := err.Start(), err.End()
+	if start.Filename != name {
+		// Errors in imported files are reported at the top of the document.
+		diag.Message = fmt.Sprintf("%s: %s", err.GetPosition(), diag.Message)
+		return diag
+	}
+
+	if start.Line > 0 {
+		startOff := doc.visualOffset(start.Line-1, start.Col-1)
+		endOff := startOff
+		if end.Line > 0 {
+			endOff = doc.visualOffset(end.Line-1, end.Col-1)
+		}
+		diag.Range = doc.rangeOf(startOff, max(startOff, endOff))
+	}
+	return diag
+}
+
+func (s *Server) lintDiagnostic(doc *document, problem lint.Problem) Diagnostic {
+	diag := Diagnostic{
+		Range:   doc.span(problem.Location.GetSpan()),
+		Code:    string(problem.RuleID),
+		Source:  "api-linter",
+		Message: problem.Message,
+	}
+
+	switch s.cfg.Linter.SeverityOf(problem.RuleID) {
+	case linters.SeverityWarning:
+		diag.Severity = SeverityWarning
+	case linters.SeverityInfo:
+		diag.Severity = SeverityInformation
+	default:
+		diag.Severity = SeverityError
+	}
+
+	if uri := problem.GetRuleURI(); uri != "" {
+		diag.CodeDescription = &CodeDescription{Href: uri}
+	}
+	return diag
+}
+
+func (s *Server) formatting(uri string) ([]TextEdit, error) {
+	doc, err := s.document(uri)
+	if err != nil {
+		return nil, err
+	}
+
+	formatted, err := format.SourceWithOptions(doc.path, []byte(doc.text), s.cfg.Format)
+	if err != nil {
+		return nil, err
+	}
+	if string(formatted) == doc.text {
+		return []TextEdit{}, nil
+	}
+	return []TextEdit{{Range: doc.fullRange(), NewText: string(formatted)}}, nil
+}
+
+func (s *Server) definition(params TextDocumentPositionParams) (any, error) {
+	doc, err := s.document(params.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+
+	res, files, errs := s.ws.compile(doc)
+	if len(errs) > 0 {
+		return nil, nil
+	}
+
+	offset := doc.offset(params.Position)
+	if imp, ok := importAt(res.AST(), offset); ok {
+		if path := s.ws.diskPath(imp); path != "" {
+			return []Location{{URI: pathToURI(path)}}, nil
+		}
+		return nil, nil
+	}
+
+	word, _, _ := doc.wordAt(offset)
+	if word == "" {
+		return nil, nil
+	}
+
+	d := resolveName(files, scopeAt(res, offset), word)
+	if d == nil {
+		return nil, nil
+	}
+
+	target, rng, ok := s.ws.declarationOf(d)
+	if !ok {
+		return nil, nil
+	}
+	return []Location{{URI: target.uri, Range: rng}}, nil
+}
+
+func (s *Server) hover(params TextDocumentPositionParams) (any, error) {
+	doc, err := s.document(params.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+
+	res, files, errs := s.ws.compile(doc)
+	if len(errs) > 0 {
+		return nil, nil
+	}
+
+	offset := doc.offset(params.Position)
+	word, start, end := doc.wordAt(offset)
+	if word == "" {
+		return nil, nil
+	}
+
+	d := resolveName(files, scopeAt(res, offset), word)
+	if d == nil {
+		return nil, nil
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "```proto\n%s\n```", describe(d))
+	if comments := strings.TrimSpace(d.ParentFile().SourceLocations().ByDescriptor(d).LeadingComments); comments != "" {
+		fmt.Fprintf(&b, "\n\n%s", comments)
+	}
+	if file := d.ParentFile().Path(); file != res.Path() {
+		fmt.Fprintf(&b, "\n\n*%s*", file)
+	}
+
+	rng := doc.rangeOf(start, end)
+	return Hover{
+		Contents: MarkupContent{Kind: "markdown", Value: b.String()},
+		Range:    &rng,
+	}, nil
+}
+
+func (s *Server) documentSymbols(uri string) ([]DocumentSymbol, error) {
+	doc, err := s.document(uri)
+	if err != nil {
+		return nil, err
+	}
+
+	// Symbols only need the AST, so they work while imports are unresolved.
+	fileNode, err := parser.Parse(doc.path, strings.NewReader(doc.text), reporter.NewHandler(nil))
+	if err != nil && fileNode == nil {
+		return nil, nil
+	}
+
+	b := symbolBuilder{doc: doc, file: fileNode}
+	symbols := []DocumentSymbol{}
+	for _, decl := range fileNode.Decls {
+		if sym, ok := b.symbol(decl); ok {
+			symbols = append(symbols, sym)
+		}
+	}
+	return symbols, nil
+}
+
+// symbolBuilder converts AST declarations to document symbols.
+type symbolBuilder struct {
+	doc  *document
+	file *ast.FileNode
+}
+
+func (b symbolBuilder) rangeOf(n ast.Node) Range {
+	info := b.file.NodeInfo(n)
+	return b.doc.rangeOf(info.Start().Offset, info.End().Offset)
+}
+
+func (b symbolBuilder) newSymbol(n ast.Node, name *ast.IdentNode, kind int, detail string) DocumentSymbol {
+	return DocumentSymbol{
+		Name:           name.Val,
+		Detail:         detail,
+		Kind:           kind,
+		Range:          b.rangeOf(n),
+		SelectionRange: b.rangeOf(name),
+	}
+}
+
+func (b symbolBuilder) symbol(n ast.Node) (DocumentSymbol, bool) {
+	switch n := n.(type) {
+	case *ast.MessageNode:
+		sym := b.newSymbol(n, n.Name, SymbolKindStruct, "message")
+		for _, decl := range n.Decls {
+			if child, ok := b.symbol(decl); ok {
+				sym.Children = append(sym.Children, child)
+			}
+		}
+		return sym, true
+
+	case *ast.EnumNode:
+		sym := b.newSymbol(n, n.Name, SymbolKindEnum, "enum")
+		for _, decl := range n.Decls {
+			if v, ok := decl.(*ast.EnumValueNode); ok {
+				sym.Children = append(sym.Children, b.newSymbol(v, v.Name, SymbolKindEnumMember, ""))
+			}
+		}
+		return sym, true
+
+	case *ast.ServiceNode:
+		sym := b.newSymbol(n, n.Name, SymbolKindInterface, "service")
+		for _, decl := range n.Decls {
+			if rpc, ok := decl.(*ast.RPCNode); ok {
+				detail := fmt.Sprintf("(%s) returns (%s)", rpc.Input.MessageType.AsIdentifier(), rpc.Output.MessageType.AsIdentifier())
+				sym.Children = append(sym.Children, b.newSymbol(rpc, rpc.Name, SymbolKindMethod, detail))
+			}
+		}
+		return sym, true
+
+	case *ast.OneofNode:
+		sym := b.newSymbol(n, n.Name, SymbolKindField, "oneof")
+		for _, decl := range n.Decls {
+			if child, ok := b.symbol(decl); ok {
+				sym.Children = append(sym.Children, child)
+			}
+		}
+		return sym, true
+
+	case *ast.FieldNode:
+		return b.newSymbol(n, n.Name, SymbolKindField, string(n.FldType.AsIdentifier())), true
+
+	case *ast.MapFieldNode:
+		detail := fmt.Sprintf("map<%s, %s>", n.MapType.KeyType.Val, n.MapType.ValueType.AsIdentifier())
+		return b.newSymbol(n, n.Name, SymbolKindField, detail), true
+
+	default:
+		return DocumentSymbol{}, false
+	}
+}
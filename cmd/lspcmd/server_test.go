@@ -0,0 +1,254 @@
+package lspcmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testVendorProto = `syntax = "proto3";
+
+package acme.type;
+
+// Money is an amount of money.
+message Money {
+  int64 units = 1;
+}
+`
+
+const testBookProto = `syntax = "proto3";
+
+package acme.library.v1;
+
+import "acme/type/money.proto";
+
+// Book is a book.
+message Book {
+  // The resource name.
+  string name = 1;
+  acme.type.Money price = 2;
+  Shelf shelf = 3;
+}
+
+message Shelf {
+	string name = 1;
+}
+
+service Library {
+  rpc GetBook(Book) returns (Book);
+}
+`
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("failed to create dir: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+}
+
+// session runs the server over the given requests and returns the responses
+// by request id and the notifications in order.
+func session(t *testing.T, cfg *ProjectConfig, requests ...*message) (map[string]*message, []*message) {
+	t.Helper()
+
+	var in bytes.Buffer
+	client := newConn(nil, &in)
+	for _, req := range requests {
+		if err := client.write(req); err != nil {
+			t.Fatalf("failed to write request: %v", err)
+		}
+	}
+
+	var out bytes.Buffer
+	if err := NewServer(cfg).Serve(context.Background(), &in, &out); err != nil {
+		t.Fatalf("Serve() error: %v", err)
+	}
+
+	responses := make(map[string]*message)
+	var notifications []*message
+	reader := newConn(&out, nil)
+	for {
+		msg, err := reader.read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatalf("failed to read response: %v", err)
+		}
+		if msg.isNotification() {
+			notifications = append(notifications, msg)
+			continue
+		}
+		responses[string(*msg.ID)] = msg
+	}
+	return responses, notifications
+}
+
+func request(id int, method string, params any) *message {
+	raw := mustJSON(id)
+	return &message{ID: &raw, Method: method, Params: mustJSON(params)}
+}
+
+func notification(method string, params any) *message {
+	return &message{Method: method, Params: mustJSON(params)}
+}
+
+func mustJSON(v any) json.RawMessage {
+	b, err := json.Marshal(v)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+func decode[T any](t *testing.T, msg *message) T {
+	t.Helper()
+	var v T
+	if msg == nil {
+		t.Fatal("missing response")
+	}
+	if msg.Error != nil {
+		t.Fatalf("unexpected error: %v", msg.Error)
+	}
+	b, _ := json.Marshal(msg.Result)
+	if err := json.Unmarshal(b, &v); err != nil {
+		t.Fatalf("failed to decode result: %v", err)
+	}
+	return v
+}
+
+func TestServer(t *testing.T) {
+	tmpDir := t.TempDir()
+	protoDir := filepath.Join(tmpDir, "proto")
+	vendorDir := filepath.Join(tmpDir, ".proto")
+	bookPath := filepath.Join(protoDir, "acme", "library", "v1", "book.proto")
+	moneyPath := filepath.Join(vendorDir, "acme", "type", "money.proto")
+	writeFile(t, moneyPath, testVendorProto)
+
+	cfg := &ProjectConfig{Includes: []string{protoDir}, Vendor: vendorDir}
+	uri := pathToURI(bookPath)
+	doc := newDocument(uri, bookPath, testBookProto)
+	at := func(substr string, delta int) Position {
+		return doc.position(strings.Index(testBookProto, substr) + delta)
+	}
+
+	responses, notifications := session(t, cfg,
+		request(1, "initialize", map[string]any{}),
+		notification("initialized", map[string]any{}),
+		notification("textDocument/didOpen", DidOpenTextDocumentParams{
+			TextDocument: TextDocumentItem{URI: uri, LanguageID: "proto", Text: testBookProto},
+		}),
+		request(2, "textDocument/definition", TextDocumentPositionParams{
+			TextDocument: TextDocumentIdentifier{URI: uri},
+			Position:     at("acme.type.Money price", 12),
+		}),
+		request(3, "textDocument/definition", TextDocumentPositionParams{
+			TextDocument: TextDocumentIdentifier{URI: uri},
+			Position:     at("Shelf shelf", 1),
+		}),
+		request(4, "textDocument/hover", TextDocumentPositionParams{
+			TextDocument: TextDocumentIdentifier{URI: uri},
+			Position:     at("acme.type.Money price", 12),
+		}),
+		request(5, "textDocument/documentSymbol", DocumentSymbolParams{TextDocument: TextDocumentIdentifier{URI: uri}}),
+		request(6, "textDocument/formatting", DocumentFormattingParams{TextDocument: TextDocumentIdentifier{URI: uri}}),
+		request(7, "shutdown", nil),
+		notification("exit", nil),
+	)
+
+	if caps := decode[InitializeResult](t, responses["1"]).Capabilities; !caps.DefinitionProvider || !caps.HoverProvider {
+		t.Errorf("unexpected capabilities: %+v", caps)
+	}
+
+	if len(notifications) != 1 || notifications[0].Method != "textDocument/publishDiagnostics" {
+		t.Fatalf("expected diagnostics notification, got %v", notifications)
+	}
+	var diags PublishDiagnosticsParams
+	if err := json.Unmarshal(notifications[0].Params, &diags); err != nil {
+		t.Fatalf("failed to decode diagnostics: %v", err)
+	}
+	for _, d := range diags.Diagnostics {
+		if d.Source != "api-linter" {
+			t.Errorf("unexpected compile diagnostic: %+v", d)
+		}
+	}
+
+	money := decode[[]Location](t, responses["2"])
+	if len(money) != 1 || money[0].URI != pathToURI(moneyPath) || money[0].Range.Start != (Position{Line: 5, Character: 8}) {
+		t.Errorf("unexpected definition for vendored type: %+v", money)
+	}
+
+	shelf := decode[[]Location](t, responses["3"])
+	if len(shelf) != 1 || shelf[0].URI != uri || shelf[0].Range.Start != (Position{Line: 14, Character: 8}) {
+		t.Errorf("unexpected definition for local type: %+v", shelf)
+	}
+
+	hover := decode[Hover](t, responses["4"])
+	if !strings.Contains(hover.Contents.Value, "message acme.type.Money") || !strings.Contains(hover.Contents.Value, "Money is an amount of money.") {
+		t.Errorf("unexpected hover: %s", hover.Contents.Value)
+	}
+
+	symbols := decode[[]DocumentSymbol](t, responses["5"])
+	var names []string
+	for _, sym := range symbols {
+		names = append(names, sym.Name)
+	}
+	if strings.Join(names, ",") != "Book,Shelf,Library" || len(symbols[0].Children) != 3 {
+		t.Errorf("unexpected symbols: %+v", symbols)
+	}
+
+	edits := decode[[]TextEdit](t, responses["6"])
+	if len(edits) != 1 || strings.Contains(edits[0].NewText, "\tstring name") {
+		t.Errorf("expected tab indentation to be formatted, got %+v", edits)
+	}
+}
+
+func TestServer_CompileDiagnostics(t *testing.T) {
+	tmpDir := t.TempDir()
+	path := filepath.Join(tmpDir, "bad.proto")
+	uri := pathToURI(path)
+
+	_, notifications := session(t, &ProjectConfig{Includes: []string{tmpDir}},
+		notification("textDocument/didOpen", DidOpenTextDocumentParams{
+			TextDocument: TextDocumentItem{URI: uri, Text: "syntax = \"proto3\";\n\nmessage A {\n  Missing m = 1;\n}\n"},
+		}),
+		request(1, "shutdown", nil),
+		notification("exit", nil),
+	)
+
+	if len(notifications) != 1 {
+		t.Fatalf("expected one notification, got %d", len(notifications))
+	}
+	var diags PublishDiagnosticsParams
+	if err := json.Unmarshal(notifications[0].Params, &diags); err != nil {
+		t.Fatalf("failed to decode diagnostics: %v", err)
+	}
+	if len(diags.Diagnostics) != 1 || diags.Diagnostics[0].Range.Start.Line != 3 || !strings.Contains(diags.Diagnostics[0].Message, "Missing") {
+		t.Errorf("unexpected diagnostics: %+v", diags.Diagnostics)
+	}
+}
+
+func TestDocument_Positions(t *testing.T) {
+	doc := newDocument("", "", "a\n\tb😀c\n")
+
+	// The emoji is 4 bytes in UTF-8 and 2 units in UTF-16.
+	if got := doc.offset(Position{Line: 1, Character: 4}); got != 8 {
+		t.Errorf("offset() = %d, expected 8", got)
+	}
+	if got := doc.position(8); got != (Position{Line: 1, Character: 4}) {
+		t.Errorf("position() = %+v", got)
+	}
+	// Tabs advance to the next 8-column tab stop.
+	if got := doc.visualOffset(1, 8); got != 3 {
+		t.Errorf("visualOffset() = %d, expected 3", got)
+	}
+}
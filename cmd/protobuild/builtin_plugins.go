@@ -0,0 +1,161 @@
+package protobuild
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/pubgo/funk/v2/errors"
+	"github.com/pubgo/funk/v2/log"
+	"github.com/samber/lo"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/pluginpb"
+
+	"github.com/pubgo/protobuild/internal/docgen"
+	"github.com/pubgo/protobuild/internal/openapiv3"
+	"github.com/pubgo/protobuild/internal/protoutil"
+)
+
+// builtinGenerator generates files from a code generator request, like a
+// protoc plugin.
+type builtinGenerator func(req *pluginpb.CodeGeneratorRequest) (*pluginpb.CodeGeneratorResponse, error)
+
+// builtinPlugins are generators that run in-process, without protoc or an
+// external protoc-gen binary.
+var builtinPlugins = map[string]builtinGenerator{
+	builtinDocPluginName: docgen.Generate,
+	"openapiv3":          openapiv3.Generate,
+}
+
+// isBuiltinPlugin reports whether plg runs in-process: a template plugin, or
+// a builtin generator without a path, shell or docker setting.
+func isBuiltinPlugin(plg *plugin) bool {
+	if plg.Template != "" {
+		return true
+	}
+	_, ok := builtinPlugins[plg.Name]
+	return ok && plg.Path == "" && plg.Shell == "" && plg.Docker == ""
+}
+
+// builtinGeneratorOf returns the generator of a builtin plugin.
+func builtinGeneratorOf(plg *plugin) builtinGenerator {
+	if plg.Template != "" {
+		return protoutil.TemplatePlugin(plg.Template)
+	}
+	return builtinPlugins[plg.Name]
+}
+
+// builtinRun is a builtin plugin invocation over a set of proto files.
+type builtinRun struct {
+	plugin   *plugin
+	out      string
+	params   []string
+	includes []string
+	files    []string
+}
+
+// runBuiltinPlugins runs the builtin plugins of all proto directories.
+// Directories with the same plugin settings are generated in one request, so
+// merged outputs cover the whole project.
+func runBuiltinPlugins(ctx context.Context, walker *ProtoWalker, pluginMap map[string]*Config) error {
+	runs := make(map[string]*builtinRun)
+	var keys []string
+
+	dirs := lo.Keys(pluginMap)
+	sort.Strings(dirs)
+	for _, dir := range dirs {
+		cfg := pluginMap[dir]
+		files := walker.GetProtoFiles(dir)
+		if len(files) == 0 {
+			continue
+		}
+
+		for _, plg := range cfg.Plugins {
+			if plg.SkipRun || !isBuiltinPlugin(plg) {
+				continue
+			}
+
+			c := &ProtocCommand{cfg: cfg, protoPath: dir}
+			out := c.resolveOutputDir(plg)
+			params := plg.GetAllOpts()
+			if plg.Template != "" {
+				// Template plugins are protogen plugins and take the base
+				// paths and module options, like protoc-gen-go.
+				params = c.filterExcludedOpts(c.buildPluginOpts(plg, out), plg.ExcludeOpts)
+			}
+			key := strings.Join([]string{plg.Name, out, strings.Join(params, ",")}, "\x00")
+			run, ok := runs[key]
+			if !ok {
+				run = &builtinRun{plugin: plg, out: out, params: params}
+				runs[key] = run
+				keys = append(keys, key)
+			}
+			run.includes = append(run.includes, cfg.Includes...)
+			run.files = append(run.files, files...)
+		}
+	}
+
+	for _, key := range keys {
+		if err := runs[key].generate(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// generate compiles the files, runs the generator and writes its output.
+func (r *builtinRun) generate(ctx context.Context) error {
+	importPaths := protocIncludePaths(slices.Concat(globalCfg.Includes, r.includes), globalCfg.Vendor, pwd)
+	set, err := buildDescriptorSet(ctx, importPaths, r.files, true)
+	if err != nil {
+		return err
+	}
+
+	req := &pluginpb.CodeGeneratorRequest{ProtoFile: set.File}
+	if len(r.params) > 0 {
+		req.Parameter = proto.String(strings.Join(r.params, ","))
+	}
+	for _, file := range lo.Uniq(r.files) {
+		name, err := importName(importPaths, file)
+		if err != nil {
+			return err
+		}
+		req.FileToGenerate = append(req.FileToGenerate, name)
+	}
+
+	// Round-trip through the wire format like protoc does, so options are
+	// decoded with the registered extension types (e.g. google.api.http).
+	data, err := proto.Marshal(req)
+	if err != nil {
+		return err
+	}
+	req = &pluginpb.CodeGeneratorRequest{}
+	if err := proto.Unmarshal(data, req); err != nil {
+		return err
+	}
+
+	resp, err := builtinGeneratorOf(r.plugin)(req)
+	if err != nil {
+		return errors.Wrapf(err, "plugin %s failed", r.plugin.Name)
+	}
+	if resp.Error != nil {
+		return fmt.Errorf("plugin %s failed: %s", r.plugin.Name, resp.GetError())
+	}
+
+	for _, file := range resp.File {
+		path := filepath.Join(r.out, filepath.FromSlash(file.GetName()))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(path, []byte(file.GetContent()), 0o644); err != nil {
+			return errors.Wrapf(err, "write %s failed", path)
+		}
+	}
+
+	log.GetLogger("protobuild").Info().Str("plugin", r.plugin.Name).Int("files", len(resp.File)).Msg("builtin plugin generated")
+	return nil
+}
@@ -0,0 +1,55 @@
+package protobuild
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBuiltinPlugins_DocRunsProtoc(t *testing.T) {
+	out := t.TempDir()
+	cfg := &Config{Plugins: []*plugin{{Name: "doc", Out: out, Opt: pluginOpts{"markdown,index.md"}}}}
+
+	if isBuiltinPlugin(cfg.Plugins[0]) {
+		t.Fatal("doc must keep running protoc-gen-doc")
+	}
+	cmd := NewProtocBuilder(nil, filepath.Join(out, ".proto"), out).BuildCommand(cfg, "proto")
+	mainCmd, _ := cmd.build()
+	if !strings.Contains(mainCmd, "--doc_out="+filepath.Join(out, "proto")) || !strings.Contains(mainCmd, "--doc_opt=markdown,index.md") {
+		t.Errorf("protoc command = %q, want protoc-gen-doc invoked", mainCmd)
+	}
+
+	cfg.Plugins[0].Name = builtinDocPluginName
+	if !isBuiltinPlugin(cfg.Plugins[0]) {
+		t.Fatal("builtin-doc must run in-process")
+	}
+	if mainCmd, _ := cmd.build(); mainCmd != "" {
+		t.Errorf("protoc command = %q, want none for builtin-doc", mainCmd)
+	}
+}
+
+func TestBuiltinRun_ProjectRoot(t *testing.T) {
+	tmpDir := t.TempDir()
+	file := filepath.Join(tmpDir, "api", "v1", "book.proto")
+	writeTestFiles(t, map[string]string{file: `syntax = "proto3";
+package api.v1;
+message Book {}
+`})
+
+	oldCfg, oldPwd := globalCfg, pwd
+	defer func() { globalCfg, pwd = oldCfg, oldPwd }()
+	globalCfg = Config{Includes: []string{filepath.Join(tmpDir, "proto")}, Vendor: filepath.Join(tmpDir, ".proto")}
+	pwd = tmpDir
+
+	// The file is only reachable through the project root, as with gen.
+	out := filepath.Join(tmpDir, "docs")
+	run := &builtinRun{plugin: &plugin{Name: builtinDocPluginName}, out: out, files: []string{file}}
+	if err := run.generate(context.Background()); err != nil {
+		t.Fatalf("generate() = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(out, "index.md")); err != nil {
+		t.Error(err)
+	}
+}
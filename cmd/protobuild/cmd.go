@@ -11,6 +11,8 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/pubgo/funk/v2/assert"
@@ -18,8 +20,10 @@ import (
 	"github.com/pubgo/funk/v2/log"
 	"github.com/pubgo/funk/v2/recovery"
 	"github.com/pubgo/funk/v2/running"
+	"github.com/pubgo/protobuild/cmd/diffcmd"
 	"github.com/pubgo/protobuild/cmd/formatcmd"
 	"github.com/pubgo/protobuild/cmd/linters"
+	"github.com/pubgo/protobuild/cmd/lspcmd"
 	"github.com/pubgo/protobuild/cmd/webcmd"
 	"github.com/pubgo/protobuild/internal/shutil"
 	"github.com/pubgo/protobuild/internal/typex"
@@ -35,6 +39,7 @@ var (
 	globalCfg      Config
 	protoCfg       = "protobuf.yaml"
 	protoPluginCfg = "protobuf.plugin.yaml"
+	offline        bool
 	pwd            = assert.Exit1(os.Getwd())
 	logger         = log.GetLogger("protobuild")
 
@@ -91,6 +96,9 @@ dependencies:
 
 const (
 	reTagPluginName = "retag"
+	// builtinDocPluginName is the in-process doc generator. It is opt-in
+	// by name, so doc keeps running protoc-gen-doc.
+	builtinDocPluginName = "builtin-doc"
 )
 
 // withParseConfig returns a middleware that parses the config file.
@@ -101,6 +109,14 @@ func withParseConfig() redant.MiddlewareFunc {
 				slog.Error("failed to parse config", "err", err)
 				return err
 			}
+			if err := loadNetworkConfig(); err != nil {
+				return err
+			}
+			if offline {
+				// Make go commands run by protobuild and its plugins fail
+				// fast instead of waiting for network timeouts.
+				_ = os.Setenv("GOPROXY", "off")
+			}
 			return next(ctx, inv)
 		}
 	}
@@ -111,6 +127,9 @@ func Main() *redant.Command {
 	var force, update, dryRun bool
 	cliArgs, options := linters.NewCli()
 
+	// PROTOBUILD_OFFLINE sets the default of --offline; the flag overrides it.
+	offline, _ = strconv.ParseBool(os.Getenv("PROTOBUILD_OFFLINE"))
+
 	app := &redant.Command{
 		Use:   "protobuild",
 		Short: "Protobuf generation, configuration and management tool",
@@ -122,16 +141,24 @@ func Main() *redant.Command {
 				Default:     protoCfg,
 				Value:       redant.StringOf(&protoCfg),
 			},
+			redant.Option{
+				Flag:        "offline",
+				Description: "never access the network, use only cached dependencies (env PROTOBUILD_OFFLINE)",
+				Value:       redant.BoolOf(&offline),
+			},
 		},
 		Handler: handleStdinPlugin,
 		Children: typex.Commands{
 			newInitCommand(),
 			newDoctorCommand(),
 			newGenCommand(),
+			newBuildCommand(),
 			newVendorCommand(&force, &update),
 			newInstallCommand(&force),
 			newLintCommand(cliArgs, options),
 			newFormatCommand(),
+			newLspCommand(),
+			newDiffCommand(),
 			newDepsCommand(),
 			newCleanCommand(&dryRun),
 			newSkillsCommand(),
@@ -286,7 +313,11 @@ func executeWrapperPlugin(plgName string, req *pluginpb.CodeGeneratorRequest) er
 		}
 
 		if p.Docker != "" {
-			cmd := shutil.Shell("docker run -i --rm " + p.Docker)
+			pull := ""
+			if offline {
+				pull = "--pull=never "
+			}
+			cmd := shutil.Shell("docker run -i --rm " + pull + p.Docker)
 			cmd.Stdin = bytes.NewBuffer(reqData)
 			return cmd.Run()
 		}
@@ -311,6 +342,10 @@ func newInstallCommand(force *bool) *redant.Command {
 		Handler: func(ctx context.Context, inv *redant.Invocation) error {
 			defer recovery.Exit()
 
+			if offline && len(globalCfg.Installers) > 0 {
+				return fmt.Errorf("install needs network access, run it without --offline")
+			}
+
 			for _, plg := range globalCfg.Installers {
 				installPlugin(plg, *force)
 			}
@@ -365,6 +400,34 @@ func newFormatCommand() *redant.Command {
 			Root:     globalCfg.Root,
 			Vendor:   globalCfg.Vendor,
 			Includes: globalCfg.Includes,
+			Format:   toFormatOptions(&globalCfg),
+		}
+	})
+	cmd.Middleware = withParseConfig()
+	return cmd
+}
+
+// newLspCommand creates the lsp command with project config integration.
+func newLspCommand() *redant.Command {
+	cmd := lspcmd.New("lsp", func() *lspcmd.ProjectConfig {
+		return &lspcmd.ProjectConfig{
+			Vendor:   globalCfg.Vendor,
+			Includes: globalCfg.Includes,
+			Linter:   toLinterConfig(globalCfg.Linter),
+			Format:   toFormatOptions(&globalCfg),
+		}
+	})
+	cmd.Middleware = withParseConfig()
+	return cmd
+}
+
+// newDiffCommand creates the diff command with project config integration.
+func newDiffCommand() *redant.Command {
+	cmd := diffcmd.New("diff", func() *diffcmd.ProjectConfig {
+		return &diffcmd.ProjectConfig{
+			Root:     globalCfg.Root,
+			Vendor:   globalCfg.Vendor,
+			Includes: globalCfg.Includes,
 		}
 	})
 	cmd.Middleware = withParseConfig()
@@ -380,21 +443,44 @@ func newLintCommand(cliArgs *linters.CliArgs, options typex.Options) *redant.Com
 		Middleware: withParseConfig(),
 		Handler: func(ctx context.Context, inv *redant.Invocation) error {
 			walker := NewProtoWalker(globalCfg.Root, globalCfg.Excludes)
-			protoDirs := lo.Uniq(walker.GetAllProtoDirs())
+			pluginMap := walker.CollectPluginConfigs(&globalCfg, protoPluginCfg)
+
+			protoDirs := lo.Keys(pluginMap)
+			sort.Strings(protoDirs)
+
+			// Names renamed by --fix may be used in any project file.
+			var projectFiles []string
+			for _, dir := range protoDirs {
+				projectFiles = append(projectFiles, walker.GetProtoFiles(dir)...)
+			}
 
+			// Lint every directory before failing on errors.
+			var lintErrors int
 			for _, dir := range protoDirs {
 				protoFiles := walker.GetProtoFiles(dir)
 				if len(protoFiles) == 0 {
 					continue
 				}
 
-				includes := lo.Uniq(append(globalCfg.Includes, globalCfg.Vendor))
-				linterCfg := toLinterConfig(globalCfg.Linter)
-				if err := linters.Linter(cliArgs, linterCfg, includes, protoFiles); err != nil {
+				// Directory configs from protobuf.plugin.yaml may override linter rules.
+				cfg := pluginMap[dir]
+				includes := lo.Uniq(append(cfg.Includes, globalCfg.Vendor))
+				linterCfg := toLinterConfig(cfg.Linter)
+				linterCfg.ReferenceFiles = projectFiles
+				err := linters.Linter(cliArgs, linterCfg, includes, protoFiles)
+				var problems *linters.ProblemsError
+				if errors.As(err, &problems) {
+					lintErrors += problems.Errors
+					continue
+				}
+				if err != nil {
 					return err
 				}
 			}
 
+			if lintErrors > 0 {
+				os.Exit(1)
+			}
 			return nil
 		},
 	}
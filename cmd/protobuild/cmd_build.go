@@ -0,0 +1,170 @@
+package protobuild
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/bufbuild/protocompile"
+	"github.com/pubgo/funk/v2/errors"
+	"github.com/pubgo/redant"
+	"github.com/samber/lo"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+
+	"github.com/pubgo/protobuild/internal/typex"
+)
+
+// newBuildCommand creates the build command, which exports a descriptor set.
+func newBuildCommand() *redant.Command {
+	var output = "image.binpb"
+	var sourceInfo, asJSON bool
+
+	return &redant.Command{
+		Use:   "build",
+		Short: "编译 root 下的 proto 并导出 FileDescriptorSet",
+		Long: `Compile all proto files under the root directories, with imports resolved
+from the includes, vendor directory and project root like gen, and write a
+FileDescriptorSet containing the files and all of their imports.
+
+The output is binary unless --json is set or the output file ends with .json.
+
+Examples:
+  protobuild build -o image.binpb
+  protobuild build -o image.binpb --source-info
+  protobuild build -o image.json`,
+		Options: typex.Options{
+			redant.Option{
+				Flag:        "output",
+				Shorthand:   "o",
+				Description: "output file, - for stdout",
+				Default:     "image.binpb",
+				Value:       redant.StringOf(&output),
+			},
+			redant.Option{
+				Flag:        "source-info",
+				Description: "include source code info (comments and locations)",
+				Value:       redant.BoolOf(&sourceInfo),
+			},
+			redant.Option{
+				Flag:        "json",
+				Description: "write the descriptor set as JSON",
+				Value:       redant.BoolOf(&asJSON),
+			},
+		},
+		Middleware: withParseConfig(),
+		Handler: func(ctx context.Context, inv *redant.Invocation) error {
+			walker := NewProtoWalker(globalCfg.Root, globalCfg.Excludes)
+			var files []string
+			for _, dir := range walker.GetAllProtoDirs() {
+				files = append(files, walker.GetProtoFiles(dir)...)
+			}
+			if len(files) == 0 {
+				return fmt.Errorf("no proto files found in %v", globalCfg.Root)
+			}
+
+			importPaths := protocIncludePaths(globalCfg.Includes, globalCfg.Vendor, pwd)
+			if err := checkImportShadowing(globalCfg.ImportShadowing, importPaths); err != nil {
+				return err
+			}
+			set, err := buildDescriptorSet(ctx, importPaths, files, sourceInfo)
+			if err != nil {
+				return err
+			}
+
+			var data []byte
+			if asJSON || filepath.Ext(output) == ".json" {
+				data, err = protojson.MarshalOptions{Multiline: true, Indent: "  "}.Marshal(set)
+			} else {
+				data, err = proto.MarshalOptions{Deterministic: true}.Marshal(set)
+			}
+			if err != nil {
+				return errors.Wrap(err, "encode descriptor set failed")
+			}
+
+			if output == "-" {
+				_, err = os.Stdout.Write(data)
+				return err
+			}
+			if err := os.WriteFile(output, data, 0o644); err != nil {
+				return errors.Wrap(err, "write descriptor set failed")
+			}
+			fmt.Printf("✅ Wrote %d files to %s\n", len(set.File), output)
+			return nil
+		},
+	}
+}
+
+// buildDescriptorSet compiles the files and returns a descriptor set with
+// the files and their imports in dependency order, like
+// protoc --include_imports. Each file is named relative to the first import
+// path that contains it.
+func buildDescriptorSet(ctx context.Context, importPaths, files []string, sourceInfo bool) (*descriptorpb.FileDescriptorSet, error) {
+	names := make([]string, 0, len(files))
+	for _, file := range files {
+		name, err := importName(importPaths, file)
+		if err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+	names = lo.Uniq(names)
+	sort.Strings(names)
+
+	compiler := protocompile.Compiler{
+		Resolver: protocompile.WithStandardImports(&protocompile.SourceResolver{ImportPaths: importPaths}),
+	}
+	if sourceInfo {
+		compiler.SourceInfoMode = protocompile.SourceInfoStandard
+	}
+	results, err := compiler.Compile(ctx, names...)
+	if err != nil {
+		return nil, err
+	}
+
+	set := &descriptorpb.FileDescriptorSet{}
+	seen := make(map[string]bool)
+	var add func(fd protoreflect.FileDescriptor)
+	add = func(fd protoreflect.FileDescriptor) {
+		if seen[fd.Path()] {
+			return
+		}
+		seen[fd.Path()] = true
+		for i := 0; i < fd.Imports().Len(); i++ {
+			add(fd.Imports().Get(i).FileDescriptor)
+		}
+		fdp := protodesc.ToFileDescriptorProto(fd)
+		if !sourceInfo {
+			fdp.SourceCodeInfo = nil
+		}
+		set.File = append(set.File, fdp)
+	}
+	for _, result := range results {
+		add(result)
+	}
+	return set, nil
+}
+
+// importName returns the name of file relative to the first import path that
+// contains it.
+func importName(importPaths []string, file string) (string, error) {
+	abs, err := filepath.Abs(file)
+	if err != nil {
+		return "", err
+	}
+	for _, dir := range importPaths {
+		dir, err := filepath.Abs(dir)
+		if err != nil {
+			continue
+		}
+		if rel, err := filepath.Rel(dir, abs); err == nil && filepath.IsLocal(rel) {
+			return filepath.ToSlash(rel), nil
+		}
+	}
+	return "", fmt.Errorf("%s is not under any include path %v", file, importPaths)
+}
@@ -0,0 +1,130 @@
+package protobuild
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestBuildDescriptorSet(t *testing.T) {
+	tmpDir := t.TempDir()
+	protoDir := filepath.Join(tmpDir, "proto")
+	vendorDir := filepath.Join(tmpDir, ".proto")
+
+	files := map[string]string{
+		filepath.Join(vendorDir, "acme", "type", "money.proto"): `syntax = "proto3";
+package acme.type;
+message Money { int64 units = 1; }
+`,
+		filepath.Join(protoDir, "acme", "v1", "book.proto"): `syntax = "proto3";
+package acme.v1;
+import "acme/type/money.proto";
+import "google/protobuf/timestamp.proto";
+// Book is a book.
+message Book {
+  acme.type.Money price = 1;
+  google.protobuf.Timestamp create_time = 2;
+}
+`,
+	}
+	for path, content := range files {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("failed to create dir: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("failed to write file: %v", err)
+		}
+	}
+
+	importPaths := []string{protoDir, vendorDir}
+	bookPath := filepath.Join(protoDir, "acme", "v1", "book.proto")
+
+	set, err := buildDescriptorSet(context.Background(), importPaths, []string{bookPath}, false)
+	if err != nil {
+		t.Fatalf("buildDescriptorSet() error = %v", err)
+	}
+
+	var names []string
+	for _, file := range set.File {
+		names = append(names, file.GetName())
+		if file.SourceCodeInfo != nil {
+			t.Errorf("expected no source info for %s", file.GetName())
+		}
+	}
+	expected := []string{"acme/type/money.proto", "google/protobuf/timestamp.proto", "acme/v1/book.proto"}
+	if len(names) != len(expected) {
+		t.Fatalf("expected files %v, got %v", expected, names)
+	}
+	for i := range expected {
+		if names[i] != expected[i] {
+			t.Errorf("expected files %v, got %v", expected, names)
+			break
+		}
+	}
+
+	set, err = buildDescriptorSet(context.Background(), importPaths, []string{bookPath}, true)
+	if err != nil {
+		t.Fatalf("buildDescriptorSet() error = %v", err)
+	}
+	if book := set.File[len(set.File)-1]; len(book.GetSourceCodeInfo().GetLocation()) == 0 {
+		t.Error("expected source info")
+	}
+
+	if _, err := buildDescriptorSet(context.Background(), []string{vendorDir}, []string{bookPath}, false); err == nil {
+		t.Error("expected error for file outside the include paths")
+	}
+}
+
+func TestBuildDescriptorSet_ProjectRoot(t *testing.T) {
+	tmpDir := t.TempDir()
+	vendorDir := filepath.Join(tmpDir, ".proto")
+	writeTestFiles(t, map[string]string{
+		filepath.Join(tmpDir, "api", "v1", "shelf.proto"): `syntax = "proto3";
+package api.v1;
+message Shelf {}
+`,
+		filepath.Join(tmpDir, "api", "v1", "book.proto"): `syntax = "proto3";
+package api.v1;
+import "api/v1/shelf.proto";
+message Book { Shelf shelf = 1; }
+`,
+	})
+
+	// The files are only reachable through the project root, as with gen.
+	importPaths := protocIncludePaths([]string{filepath.Join(tmpDir, "proto")}, vendorDir, tmpDir)
+	if want := []string{filepath.Join(tmpDir, "proto"), vendorDir, tmpDir}; !slices.Equal(importPaths, want) {
+		t.Fatalf("protocIncludePaths() = %v, want %v", importPaths, want)
+	}
+
+	set, err := buildDescriptorSet(context.Background(), importPaths, []string{filepath.Join(tmpDir, "api", "v1", "book.proto")}, false)
+	if err != nil {
+		t.Fatalf("buildDescriptorSet() error = %v", err)
+	}
+	if got := set.File[len(set.File)-1].GetName(); got != "api/v1/book.proto" {
+		t.Errorf("file name = %q, want api/v1/book.proto", got)
+	}
+}
+
+func TestImportName(t *testing.T) {
+	dir := filepath.Join("work", "proto")
+	tests := map[string]string{
+		filepath.Join(dir, "a.proto"):             "a.proto",
+		filepath.Join(dir, "..foo.proto"):         "..foo.proto",
+		filepath.Join(dir, "..v1", "b.proto"):     "..v1/b.proto",
+		filepath.Join("work", "other", "c.proto"): "",
+	}
+	for file, want := range tests {
+		got, err := importName([]string{dir}, file)
+		if want == "" {
+			if err == nil {
+				t.Errorf("importName(%q) = %q, want error", file, got)
+			}
+			continue
+		}
+		if err != nil || got != want {
+			t.Errorf("importName(%q) = %q, %v, want %q", file, got, err, want)
+		}
+	}
+}
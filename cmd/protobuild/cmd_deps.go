@@ -0,0 +1,361 @@
+package protobuild
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+
+	"github.com/pubgo/funk/v2/pathutil"
+	"github.com/pubgo/funk/v2/recovery"
+	"github.com/pubgo/redant"
+
+	"github.com/pubgo/protobuild/internal/depresolver"
+	"github.com/pubgo/protobuild/internal/typex"
+)
+
+// depJSON is a dependency as printed by deps --json.
+type depJSON struct {
+	Name     string   `json:"name"`
+	Source   string   `json:"source"`
+	URL      string   `json:"url"`
+	Version  string   `json:"version,omitempty"`
+	Optional bool     `json:"optional,omitempty"`
+	Status   string   `json:"status"`
+	Path     string   `json:"path,omitempty"`
+	Checksum string   `json:"checksum,omitempty"`
+	Mirror   string   `json:"mirror,omitempty"`
+	Files    []string `json:"files"`
+	Requires []string `json:"requires"`
+	Used     bool     `json:"used"`
+}
+
+// newDepsCommand creates the deps command.
+func newDepsCommand() *redant.Command {
+	var tree, asJSON bool
+
+	return &redant.Command{
+		Use:   "deps",
+		Short: "显示依赖列表及状态",
+		Long: `Show the configured dependencies and their cache status.
+
+Dependencies are only looked up in the caches, nothing is downloaded.
+
+Examples:
+  # Show which dependencies import files of which
+  protobuild deps --tree
+
+  # Machine-readable output
+  protobuild deps --json
+
+  # Show which dependency provides an import and who imports it
+  protobuild deps why google/api/annotations.proto`,
+		Options: typex.Options{
+			redant.Option{
+				Flag:        "tree",
+				Description: "show the dependency tree",
+				Value:       redant.BoolOf(&tree),
+			},
+			redant.Option{
+				Flag:        "json",
+				Description: "print the dependencies as JSON",
+				Value:       redant.BoolOf(&asJSON),
+			},
+		},
+		Middleware: withParseConfig(),
+		Children: typex.Commands{
+			newDepsWhyCommand(),
+		},
+		Handler: func(ctx context.Context, inv *redant.Invocation) error {
+			defer recovery.Exit()
+
+			if len(globalCfg.Depends) == 0 && !asJSON {
+				fmt.Println("📭 No dependencies configured")
+				return nil
+			}
+
+			graph, err := loadDepGraph(ctx, depsResolver(), &globalCfg)
+			if err != nil {
+				return err
+			}
+
+			switch {
+			case asJSON:
+				graph.loadImports(globalCfg.Root, globalCfg.Excludes)
+				return printDepsJSON(os.Stdout, graph)
+			case tree:
+				graph.loadImports(globalCfg.Root, globalCfg.Excludes)
+				printDepsTree(graph)
+			default:
+				printDepsTable(graph)
+			}
+			return nil
+		},
+	}
+}
+
+// newDepsWhyCommand creates the deps why command.
+func newDepsWhyCommand() *redant.Command {
+	return &redant.Command{
+		Use:   "why <import>",
+		Short: "显示提供与引用某个 proto import 的依赖和文件",
+		Long: `Show which dependency provides a .proto import and which files import it.
+
+Examples:
+  protobuild deps why google/api/annotations.proto`,
+		Middleware: withParseConfig(),
+		Handler: func(ctx context.Context, inv *redant.Invocation) error {
+			defer recovery.Exit()
+
+			if len(inv.Args) != 1 {
+				return fmt.Errorf("usage: protobuild deps why <import>")
+			}
+			imp := filepath.ToSlash(inv.Args[0])
+
+			graph, err := loadDepGraph(ctx, depsResolver(), &globalCfg)
+			if err != nil {
+				return err
+			}
+			graph.loadImports(globalCfg.Root, globalCfg.Excludes)
+			return printDepsWhy(graph, imp)
+		},
+	}
+}
+
+// depsResolver returns an offline resolver, so that deps only reports the
+// cache state.
+func depsResolver() *depresolver.Manager {
+	return depresolver.NewManager("", "").WithOffline(true).WithMirrors(dependencyMirrors())
+}
+
+func printDepsTable(graph *depGraph) {
+	fmt.Println()
+	fmt.Println("📦 Dependencies:")
+	fmt.Println()
+	fmt.Printf("  %-35s %-10s %-12s %s\n", "NAME", "SOURCE", "VERSION", "STATUS")
+	fmt.Printf("  %-35s %-10s %-12s %s\n", "----", "------", "-------", "------")
+
+	for _, node := range graph.nodes {
+		fmt.Printf("  %-35s %-10s %-12s %s%s\n",
+			node.dep.Name, node.source.DisplayName(), getDepVersion(node.dep), depStatusLabel(node.status), getOptionalFlag(node.dep))
+		if node.checksum != "" {
+			fmt.Printf("    checksum: %s\n", node.checksum)
+		}
+		if node.mirror != "" {
+			fmt.Printf("    mirror: %s\n", node.mirror)
+		}
+	}
+
+	fmt.Println()
+	fmt.Printf("  Total: %d dependencies\n", len(globalCfg.Depends))
+	if goproxy := os.Getenv("GOPROXY"); goproxy != "" {
+		fmt.Printf("  GOPROXY: %s\n", goproxy)
+	}
+	printDepConflicts(graph.conflicts())
+	fmt.Println()
+}
+
+func printDepsTree(graph *depGraph) {
+	used := graph.used()
+
+	fmt.Println()
+	fmt.Println("📦 " + protoCfg)
+	for i, node := range graph.nodes {
+		label := node.dep.Name
+		if node.status != depCached {
+			label += " (" + depStatusLabel(node.status) + ")"
+		} else if !used[node.dep.Name] {
+			label += " (unused)"
+		}
+		last := i == len(graph.nodes)-1
+		fmt.Println(treeBranch("", last) + label)
+		printDepRequires(graph, node, treeIndent("", last), map[string]bool{node.dep.Name: true})
+	}
+	printDepConflicts(graph.conflicts())
+	fmt.Println()
+}
+
+// printDepRequires prints the dependencies node imports files of. seen holds
+// the dependencies on the current path, to stop at cycles.
+func printDepRequires(graph *depGraph, node *depNode, prefix string, seen map[string]bool) {
+	for i, name := range node.requires {
+		last := i == len(node.requires)-1
+		if seen[name] {
+			fmt.Println(treeBranch(prefix, last) + name + " (cycle)")
+			continue
+		}
+		fmt.Println(treeBranch(prefix, last) + name)
+		if child := graph.node(name); child != nil {
+			seen[name] = true
+			printDepRequires(graph, child, treeIndent(prefix, last), seen)
+			delete(seen, name)
+		}
+	}
+}
+
+func treeBranch(prefix string, last bool) string {
+	if last {
+		return prefix + "└── "
+	}
+	return prefix + "├── "
+}
+
+func treeIndent(prefix string, last bool) string {
+	if last {
+		return prefix + "    "
+	}
+	return prefix + "│   "
+}
+
+func printDepConflicts(conflicts []depConflict) {
+	if len(conflicts) == 0 {
+		return
+	}
+	fmt.Println()
+	fmt.Printf("⚠️  %d import paths are provided by more than one dependency, vendor will fail:\n", len(conflicts))
+	for _, c := range conflicts {
+		fmt.Printf("  - %s: %v\n", c.Import, c.Deps)
+	}
+}
+
+func printDepsJSON(w io.Writer, graph *depGraph) error {
+	used := graph.used()
+	out := struct {
+		Deps      []depJSON     `json:"deps"`
+		Conflicts []depConflict `json:"conflicts"`
+	}{
+		Deps:      make([]depJSON, 0, len(graph.nodes)),
+		Conflicts: graph.conflicts(),
+	}
+	if out.Conflicts == nil {
+		out.Conflicts = []depConflict{}
+	}
+
+	for _, node := range graph.nodes {
+		dep := depJSON{
+			Name:     node.dep.Name,
+			Source:   string(node.source),
+			URL:      node.dep.Url,
+			Status:   node.status,
+			Path:     node.dir,
+			Checksum: node.checksum,
+			Mirror:   node.mirror,
+			Files:    make([]string, 0, len(node.files)),
+			Requires: node.requires,
+			Used:     used[node.dep.Name],
+		}
+		if node.dep.Version != nil {
+			dep.Version = *node.dep.Version
+		}
+		if node.dep.Optional != nil {
+			dep.Optional = *node.dep.Optional
+		}
+		for name := range node.files {
+			dep.Files = append(dep.Files, name)
+		}
+		sort.Strings(dep.Files)
+		if dep.Requires == nil {
+			dep.Requires = []string{}
+		}
+		out.Deps = append(out.Deps, dep)
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(out)
+}
+
+func printDepsWhy(graph *depGraph, imp string) error {
+	fmt.Println()
+	fmt.Printf("🔍 %s\n", imp)
+
+	providers := graph.providers[imp]
+	switch {
+	case len(providers) > 0:
+		fmt.Println()
+		fmt.Println("  Provided by:")
+		for _, node := range providers {
+			fmt.Printf("    - %s [%s] %s\n", node.dep.Name, node.source.DisplayName(), node.files[imp])
+		}
+		if len(providers) > 1 {
+			fmt.Println("  ⚠️  Provided by more than one dependency, vendor will fail")
+		}
+	case projectFile(imp) != "":
+		fmt.Println()
+		fmt.Printf("  Provided by the project: %s\n", projectFile(imp))
+	default:
+		fmt.Println()
+		fmt.Printf("  ❌ No dependency or include path provides %s\n", imp)
+		return fmt.Errorf("import %q not found", imp)
+	}
+
+	files := graph.projectImports[imp]
+	sort.Strings(files)
+	fmt.Println()
+	if len(files) == 0 {
+		fmt.Println("  Not imported by project files")
+	} else {
+		fmt.Println("  Imported by project files:")
+		for _, file := range files {
+			fmt.Printf("    - %s\n", file)
+		}
+	}
+
+	var depFiles []string
+	for _, node := range graph.nodes {
+		for _, file := range node.imports[imp] {
+			depFiles = append(depFiles, node.dep.Name+": "+file)
+		}
+	}
+	sort.Strings(depFiles)
+	if len(depFiles) > 0 {
+		fmt.Println()
+		fmt.Println("  Imported by dependency files:")
+		for _, file := range depFiles {
+			fmt.Printf("    - %s\n", file)
+		}
+	}
+	fmt.Println()
+	return nil
+}
+
+// projectFile returns the file an import path resolves to in the project
+// include paths, or "".
+func projectFile(imp string) string {
+	for _, dir := range slices.Concat(globalCfg.Includes, globalCfg.Root) {
+		path := filepath.Join(dir, filepath.FromSlash(imp))
+		if pathutil.IsExist(path) {
+			return path
+		}
+	}
+	return ""
+}
+
+func depStatusLabel(status string) string {
+	switch status {
+	case depCached:
+		return "🟢 cached"
+	case depChecksumMismatch:
+		return "🔴 checksum mismatch"
+	default:
+		return "⚪ not cached"
+	}
+}
+
+func getDepVersion(dep *depend) string {
+	if dep.Version != nil && *dep.Version != "" {
+		return *dep.Version
+	}
+	return "-"
+}
+
+func getOptionalFlag(dep *depend) string {
+	if dep.Optional != nil && *dep.Optional {
+		return " (optional)"
+	}
+	return ""
+}
@@ -8,6 +8,7 @@ import (
 	"os"
 	"path/filepath"
 
+	"github.com/a8m/envsubst"
 	"github.com/pubgo/funk/v2/pathutil"
 	"github.com/pubgo/funk/v2/recovery"
 	"github.com/pubgo/protobuild/internal/depresolver"
@@ -31,18 +32,27 @@ func newGenCommand() *redant.Command {
 
 			builder := NewProtocBuilder(globalCfg.Includes, globalCfg.Vendor, pwd)
 
+			var cmds []*ProtocCommand
 			for protoPath, cfg := range pluginMap {
 				if !walker.HasProtoFiles(protoPath) {
 					continue
 				}
 
 				cmd := builder.BuildCommand(cfg, protoPath)
+				cmds = append(cmds, cmd)
+
+				if err := checkImportShadowing(globalCfg.ImportShadowing, cmd.includePaths()); err != nil {
+					return err
+				}
+			}
+
+			for _, cmd := range cmds {
 				if err := cmd.Execute(); err != nil {
 					return err
 				}
 			}
 
-			return nil
+			return runBuiltinPlugins(ctx, walker, pluginMap)
 		},
 	}
 }
@@ -50,8 +60,16 @@ func newGenCommand() *redant.Command {
 // newVendorCommand creates the vendor command.
 func newVendorCommand(force, update *bool) *redant.Command {
 	return &redant.Command{
-		Use:   "vendor",
+		Use:   "vendor [name...]",
 		Short: "同步项目 protobuf 依赖到 .proto 目录中",
+		Long: `同步项目 protobuf 依赖到 .proto 目录中。
+
+Examples:
+  # Re-download all dependencies
+  protobuild vendor -u
+
+  # Re-download only the named dependencies
+  protobuild vendor -u googleapis envoy`,
 		Options: typex.Options{
 			redant.Option{
 				Flag:        "force",
@@ -62,7 +80,7 @@ func newVendorCommand(force, update *bool) *redant.Command {
 			redant.Option{
 				Flag:        "update",
 				Shorthand:   "u",
-				Description: "force re-download dependencies (ignore cache)",
+				Description: "force re-download dependencies (ignore cache); with names, only the named ones",
 				Value:       redant.BoolOf(update),
 			},
 		},
@@ -71,13 +89,22 @@ func newVendorCommand(force, update *bool) *redant.Command {
 			defer recovery.Exit()
 
 			svc := NewVendorService(&globalCfg)
-			result, err := svc.ResolveDependencies(ctx, *update)
+			unlock, err := svc.Lock()
+			if err != nil {
+				return err
+			}
+			defer unlock()
+
+			result, err := svc.ResolveDependencies(ctx, *update, inv.Args...)
 			if err != nil {
 				return err
 			}
 
 			if len(result.FailedDeps) > 0 {
 				fmt.Printf("\n❌ Failed to resolve %d dependencies: %v\n", len(result.FailedDeps), result.FailedDeps)
+				if offline {
+					fmt.Println("📴 Offline mode: fetch them once with network access before building offline.")
+				}
 				return fmt.Errorf("dependency resolution failed")
 			}
 
@@ -88,7 +115,7 @@ func newVendorCommand(force, update *bool) *redant.Command {
 
 			if !result.Changed && !globalCfg.Changed && !*force {
 				fmt.Println("\n✨ No changes detected")
-				return nil
+				return svc.EnforceCacheLimits()
 			}
 
 			copiedFiles, err := svc.CopyToVendor(result.ResolvedPaths)
@@ -101,59 +128,17 @@ func newVendorCommand(force, update *bool) *redant.Command {
 				return err
 			}
 
-			fmt.Printf("\n✅ Vendor complete! Copied %d proto files.\n", copiedFiles)
-			return nil
-		},
-	}
-}
-
-// newDepsCommand creates the deps command.
-func newDepsCommand() *redant.Command {
-	return &redant.Command{
-		Use:        "deps",
-		Short:      "显示依赖列表及状态",
-		Middleware: withParseConfig(),
-		Handler: func(ctx context.Context, inv *redant.Invocation) error {
-			if len(globalCfg.Depends) == 0 {
-				fmt.Println("📭 No dependencies configured")
-				return nil
-			}
-
-			resolver := depresolver.NewManager("", "")
-
-			fmt.Println()
-			fmt.Println("📦 Dependencies:")
-			fmt.Println()
-			fmt.Printf("  %-35s %-10s %-12s %s\n", "NAME", "SOURCE", "VERSION", "STATUS")
-			fmt.Printf("  %-35s %-10s %-12s %s\n", "----", "------", "-------", "------")
-
-			for _, dep := range globalCfg.Depends {
-				if dep.Name == "" || dep.Url == "" {
-					continue
-				}
-
-				source := depresolver.Source(dep.Source)
-				if source == "" {
-					source = depresolver.DetectSource(dep.Url)
-				}
-
-				version := getDepVersion(dep)
-				status := getDepStatus(ctx, resolver, dep, source)
-				optFlag := getOptionalFlag(dep)
-
-				fmt.Printf("  %-35s %-10s %-12s %s%s\n",
-					dep.Name, source.DisplayName(), version, status, optFlag)
-			}
-
-			fmt.Println()
-			fmt.Printf("  Total: %d dependencies\n\n", len(globalCfg.Depends))
-			return nil
+			fmt.Printf("\n✅ Vendor complete! Copied %d files.\n", copiedFiles)
+			return svc.EnforceCacheLimits()
 		},
 	}
 }
 
 // newCleanCommand creates the clean command.
 func newCleanCommand(dryRun *bool) *redant.Command {
+	var olderThan, maxSize string
+	var unused bool
+
 	return &redant.Command{
 		Use:   "clean",
 		Short: "清理依赖缓存",
@@ -163,6 +148,21 @@ func newCleanCommand(dryRun *bool) *redant.Command {
 				Description: "只显示要删除的内容，不实际删除",
 				Value:       redant.BoolOf(dryRun),
 			},
+			redant.Option{
+				Flag:        "older-than",
+				Description: "只删除超过指定时长未使用的缓存，如 30d、2w、12h",
+				Value:       redant.StringOf(&olderThan),
+			},
+			redant.Option{
+				Flag:        "max-size",
+				Description: "按最近使用时间淘汰缓存，直到不超过指定大小，如 2GB",
+				Value:       redant.StringOf(&maxSize),
+			},
+			redant.Option{
+				Flag:        "unused",
+				Description: "删除不再被任何已知项目引用的缓存",
+				Value:       redant.BoolOf(&unused),
+			},
 		},
 		Handler: func(ctx context.Context, inv *redant.Invocation) error {
 			resolver := depresolver.NewManager("", "")
@@ -179,6 +179,18 @@ func newCleanCommand(dryRun *bool) *redant.Command {
 			fmt.Printf("🗑️  Cache directory: %s\n", cacheDir)
 			fmt.Printf("   Files: %d, Size: %s\n\n", fileCount, sizeStr)
 
+			if olderThan != "" || maxSize != "" || unused {
+				policy, err := cachePolicy(olderThan, maxSize)
+				if err != nil {
+					return err
+				}
+				if unused {
+					policy.Unused = cacheEntryUnused(resolver)
+				}
+				policy.DryRun = *dryRun
+				return runCacheGC(resolver, policy)
+			}
+
 			if *dryRun {
 				fmt.Println("🔍 Dry-run mode: no files will be deleted.")
 				return nil
@@ -196,36 +208,110 @@ func newCleanCommand(dryRun *bool) *redant.Command {
 	}
 }
 
+// runCacheGC removes the cache entries selected by policy and prints them.
+func runCacheGC(resolver *depresolver.Manager, policy depresolver.GCPolicy) error {
+	removed, err := resolver.GC(policy)
+	if err != nil {
+		return fmt.Errorf("failed to clean cache: %w", err)
+	}
+	if len(removed) == 0 {
+		fmt.Println("✨ Nothing to clean")
+		return nil
+	}
+
+	for _, e := range removed {
+		name := e.URL
+		if e.Version != "" {
+			name += "@" + e.Version
+		}
+		if name == "" {
+			name = e.Key
+		}
+		fmt.Printf("  - [%s] %s (%s, last used %s)\n",
+			e.Source.DisplayName(), name, formatBytes(e.Size), e.LastUsed.Format("2006-01-02"))
+	}
+	fmt.Println()
+
+	if policy.DryRun {
+		fmt.Printf("🔍 Dry-run mode: %d entries (%s) would be removed.\n", len(removed), formatBytes(cacheEntriesSize(removed)))
+		return nil
+	}
+	fmt.Printf("✨ Cleaned %d entries (%s)\n", len(removed), formatBytes(cacheEntriesSize(removed)))
+	return nil
+}
+
 // Helper functions
 
-func getDepVersion(dep *depend) string {
-	if dep.Version != nil && *dep.Version != "" {
-		return *dep.Version
+// cachePolicy builds a GC policy from --older-than and --max-size style
+// values; empty values are not applied.
+func cachePolicy(maxAge, maxSize string) (depresolver.GCPolicy, error) {
+	var policy depresolver.GCPolicy
+	if maxAge != "" {
+		age, err := depresolver.ParseAge(maxAge)
+		if err != nil {
+			return policy, err
+		}
+		policy.MaxAge = age
+	}
+	if maxSize != "" {
+		size, err := depresolver.ParseByteSize(maxSize)
+		if err != nil {
+			return policy, err
+		}
+		policy.MaxSize = size
+	}
+	return policy, nil
+}
+
+// cacheEntryUnused reports whether no project recorded in a cache entry still
+// depends on it. Projects whose config is gone or unreadable no longer count.
+func cacheEntryUnused(resolver *depresolver.Manager) func(e *depresolver.CacheEntry) bool {
+	projectKeys := make(map[string]map[string]bool)
+	return func(e *depresolver.CacheEntry) bool {
+		for _, project := range e.Projects {
+			keys, ok := projectKeys[project]
+			if !ok {
+				keys = projectCacheKeys(resolver, project)
+				projectKeys[project] = keys
+			}
+			if keys[e.Key] {
+				return false
+			}
+		}
+		return true
 	}
-	return "-"
 }
 
-func getDepStatus(ctx context.Context, resolver *depresolver.Manager, dep *depend, source depresolver.Source) string {
-	resolverDep := &depresolver.Dependency{
-		Name:    dep.Name,
-		Source:  source,
-		URL:     dep.Url,
-		Path:    dep.Path,
-		Version: dep.Version,
+// projectCacheKeys returns the cache keys of the dependencies of a project.
+func projectCacheKeys(resolver *depresolver.Manager, configPath string) map[string]bool {
+	content, err := os.ReadFile(configPath)
+	if err != nil {
+		return nil
+	}
+	if expanded, err := envsubst.Bytes(content); err == nil {
+		content = expanded
+	}
+
+	var cfg Config
+	if err := yaml.Unmarshal(content, &cfg); err != nil {
+		return nil
 	}
 
-	result, err := resolver.Resolve(ctx, resolverDep)
-	if err == nil && result.LocalPath != "" && pathutil.IsExist(result.LocalPath) {
-		return "🟢 cached"
+	keys := make(map[string]bool)
+	for _, dep := range cfg.Depends {
+		if key := resolver.CacheKey(toResolverDep(dep)); key != "" {
+			keys[key] = true
+		}
 	}
-	return "⚪ not cached"
+	return keys
 }
 
-func getOptionalFlag(dep *depend) string {
-	if dep.Optional != nil && *dep.Optional {
-		return " (optional)"
+func cacheEntriesSize(entries []*depresolver.CacheEntry) int64 {
+	var size int64
+	for _, e := range entries {
+		size += e.Size
 	}
-	return ""
+	return size
 }
 
 func calculateCacheSize(cacheDir string) (int64, int) {
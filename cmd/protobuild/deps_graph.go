@@ -0,0 +1,239 @@
+package protobuild
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/bufbuild/protocompile/ast"
+	"github.com/bufbuild/protocompile/parser"
+	"github.com/bufbuild/protocompile/reporter"
+	"github.com/pubgo/funk/v2/pathutil"
+
+	"github.com/pubgo/protobuild/internal/depresolver"
+)
+
+// Cache states of a dependency shown by deps.
+const (
+	depCached           = "cached"
+	depNotCached        = "not_cached"
+	depChecksumMismatch = "checksum_mismatch"
+)
+
+// depNode is a configured dependency with its cache state and proto files.
+type depNode struct {
+	dep      *depend
+	source   depresolver.Source
+	status   string
+	dir      string // resolved directory, if cached
+	checksum string // h1: hash of dir, to pin it with checksum
+	mirror   string // URL the dependency is fetched from, if rewritten
+
+	// files maps the import paths the dependency provides to its files.
+	files map[string]string
+	// imports maps the import paths used by the dependency to its files
+	// importing them.
+	imports map[string][]string
+	// requires are the names of the dependencies it imports files of.
+	requires []string
+}
+
+// depConflict is an import path provided by more than one dependency.
+type depConflict struct {
+	Import string   `json:"import"`
+	Deps   []string `json:"deps"`
+}
+
+// depGraph relates the proto files provided and imported by the configured
+// dependencies and the project.
+type depGraph struct {
+	nodes     []*depNode
+	providers map[string][]*depNode // import path -> providing dependencies
+	// projectImports maps import paths to the project files importing them.
+	projectImports map[string][]string
+}
+
+// loadDepGraph resolves the dependencies of cfg from the caches, without
+// downloading, and indexes the proto files they provide.
+func loadDepGraph(ctx context.Context, resolver *depresolver.Manager, cfg *Config) (*depGraph, error) {
+	g := &depGraph{providers: make(map[string][]*depNode)}
+	for _, dep := range cfg.Depends {
+		if dep.Name == "" || dep.Url == "" {
+			continue
+		}
+
+		node := resolveDepNode(ctx, resolver, dep)
+		g.nodes = append(g.nodes, node)
+		if node.dir == "" {
+			continue
+		}
+
+		files, err := dependencyFiles(dep.Name, dep, node.dir)
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range files {
+			if !strings.HasSuffix(f.target, ".proto") {
+				continue
+			}
+			name := filepath.ToSlash(f.target)
+			node.files[name] = f.source
+			g.providers[name] = append(g.providers[name], node)
+		}
+	}
+	return g, nil
+}
+
+// resolveDepNode returns the cache state of dep. It only looks in the
+// caches, nothing is downloaded and the cache metadata is not changed.
+func resolveDepNode(ctx context.Context, resolver *depresolver.Manager, dep *depend) *depNode {
+	source := depresolver.Source(dep.Source)
+	if source == "" {
+		source = depresolver.DetectSource(dep.Url)
+	}
+	node := &depNode{
+		dep:     dep,
+		source:  source,
+		status:  depNotCached,
+		files:   make(map[string]string),
+		imports: make(map[string][]string),
+	}
+
+	// Go modules are fetched through GOPROXY, not mirrors.
+	if source != depresolver.SourceGoMod && source != depresolver.SourceLocal {
+		if mirror := resolver.MirrorURL(dep.Url); mirror != dep.Url {
+			node.mirror = mirror
+		}
+	}
+
+	resolverDep := toResolverDep(dep)
+	resolverDep.Source = source
+	result, err := resolver.Lookup(ctx, resolverDep)
+	switch {
+	case errors.Is(err, depresolver.ErrChecksumMismatch):
+		node.status = depChecksumMismatch
+	case err != nil || result.LocalPath == "" || pathutil.IsNotExist(result.LocalPath):
+	default:
+		node.status = depCached
+		node.dir = result.LocalPath
+		node.checksum, _ = depresolver.HashDir(result.LocalPath)
+	}
+	return node
+}
+
+// loadImports parses the imports of the project files under roots and of
+// the dependency files, and links each dependency to the dependencies it
+// imports from. Files that do not parse are skipped.
+func (g *depGraph) loadImports(roots, excludes []string) {
+	g.projectImports = make(map[string][]string)
+	walker := NewProtoWalker(roots, excludes)
+	for _, dir := range walker.GetAllProtoDirs() {
+		for _, file := range walker.GetProtoFiles(dir) {
+			for _, imp := range protoImports(file) {
+				g.projectImports[imp] = append(g.projectImports[imp], file)
+			}
+		}
+	}
+
+	for _, node := range g.nodes {
+		requires := make(map[string]bool)
+		for name, file := range node.files {
+			for _, imp := range protoImports(file) {
+				node.imports[imp] = append(node.imports[imp], name)
+				for _, provider := range g.providers[imp] {
+					if provider != node {
+						requires[provider.dep.Name] = true
+					}
+				}
+			}
+		}
+		node.requires = sortedKeys(requires)
+	}
+}
+
+// protoImports returns the import paths of a proto file, or nil if it
+// cannot be parsed.
+func protoImports(path string) []string {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	fileNode, err := parser.Parse(path, f, reporter.NewHandler(nil))
+	if err != nil {
+		return nil
+	}
+	var imports []string
+	for _, decl := range fileNode.Decls {
+		if imp, ok := decl.(*ast.ImportNode); ok {
+			imports = append(imports, imp.Name.AsString())
+		}
+	}
+	return imports
+}
+
+// conflicts returns the import paths provided by more than one dependency,
+// which vendor cannot place in the vendor directory.
+func (g *depGraph) conflicts() []depConflict {
+	var conflicts []depConflict
+	for name, providers := range g.providers {
+		if len(providers) < 2 {
+			continue
+		}
+		conflict := depConflict{Import: name}
+		for _, provider := range providers {
+			conflict.Deps = append(conflict.Deps, provider.dep.Name)
+		}
+		conflicts = append(conflicts, conflict)
+	}
+	sort.Slice(conflicts, func(i, j int) bool { return conflicts[i].Import < conflicts[j].Import })
+	return conflicts
+}
+
+// node returns the dependency named name, or nil.
+func (g *depGraph) node(name string) *depNode {
+	idx := slices.IndexFunc(g.nodes, func(n *depNode) bool { return n.dep.Name == name })
+	if idx < 0 {
+		return nil
+	}
+	return g.nodes[idx]
+}
+
+// used returns the names of the dependencies the project files import
+// from, directly or through other dependencies. loadImports must be called
+// first.
+func (g *depGraph) used() map[string]bool {
+	used := make(map[string]bool)
+	var visit func(node *depNode)
+	visit = func(node *depNode) {
+		if used[node.dep.Name] {
+			return
+		}
+		used[node.dep.Name] = true
+		for _, name := range node.requires {
+			if n := g.node(name); n != nil {
+				visit(n)
+			}
+		}
+	}
+	for imp := range g.projectImports {
+		for _, provider := range g.providers[imp] {
+			visit(provider)
+		}
+	}
+	return used
+}
+
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
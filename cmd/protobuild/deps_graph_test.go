@@ -0,0 +1,79 @@
+package protobuild
+
+import (
+	"context"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/pubgo/protobuild/internal/depresolver"
+)
+
+func TestDepGraph(t *testing.T) {
+	tmpDir := t.TempDir()
+	writeTestFiles(t, map[string]string{
+		filepath.Join(tmpDir, "api", "google", "api", "http.proto"): `syntax = "proto3";`,
+		filepath.Join(tmpDir, "api", "google", "api", "annotations.proto"): `syntax = "proto3";
+import "google/api/http.proto";
+import "google/protobuf/descriptor.proto";`,
+		filepath.Join(tmpDir, "wkt", "descriptor.proto"): `syntax = "proto3";`,
+		filepath.Join(tmpDir, "extra", "http.proto"):     `syntax = "proto3";`,
+		filepath.Join(tmpDir, "unused", "a.proto"):       `syntax = "proto3";`,
+		filepath.Join(tmpDir, "proto", "svc.proto"): `syntax = "proto3";
+import "google/api/annotations.proto";`,
+	})
+
+	cfg := &Config{Depends: []*depend{
+		{Name: "google", Source: "local", Url: filepath.Join(tmpDir, "api", "google")},
+		{Name: "google/protobuf", Source: "local", Url: filepath.Join(tmpDir, "wkt")},
+		{Name: "google/api", Source: "local", Url: filepath.Join(tmpDir, "extra")},
+		{Name: "unused", Source: "local", Url: filepath.Join(tmpDir, "unused")},
+		{Name: "missing", Source: "local", Url: filepath.Join(tmpDir, "missing")},
+	}}
+	resolver := depresolver.NewManager(t.TempDir(), "").WithOffline(true)
+	graph, err := loadDepGraph(context.Background(), resolver, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	graph.loadImports([]string{filepath.Join(tmpDir, "proto")}, nil)
+
+	if got := graph.node("missing").status; got != depNotCached {
+		t.Errorf("missing status = %q, want %q", got, depNotCached)
+	}
+	if got := graph.node("google").requires; !slices.Equal(got, []string{"google/api", "google/protobuf"}) {
+		t.Errorf("requires = %v", got)
+	}
+
+	want := []depConflict{{Import: "google/api/http.proto", Deps: []string{"google", "google/api"}}}
+	if got := graph.conflicts(); len(got) != 1 || got[0].Import != want[0].Import || !slices.Equal(got[0].Deps, want[0].Deps) {
+		t.Errorf("conflicts() = %+v, want %+v", got, want)
+	}
+
+	used := graph.used()
+	for _, name := range []string{"google", "google/api", "google/protobuf"} {
+		if !used[name] {
+			t.Errorf("%s is not used", name)
+		}
+	}
+	if used["unused"] {
+		t.Error("unused dependency is used")
+	}
+
+	if got := graph.projectImports["google/api/annotations.proto"]; len(got) != 1 {
+		t.Errorf("project imports = %v", got)
+	}
+}
+
+func TestProtoImports(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "a.proto")
+	writeTestFiles(t, map[string]string{path: `syntax = "proto3";
+import "b.proto";
+import public "c/d.proto";`})
+
+	if got := protoImports(path); !slices.Equal(got, []string{"b.proto", "c/d.proto"}) {
+		t.Errorf("protoImports() = %v", got)
+	}
+	if got := protoImports(filepath.Join(t.TempDir(), "none.proto")); got != nil {
+		t.Errorf("protoImports() of a missing file = %v", got)
+	}
+}
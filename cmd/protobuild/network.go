@@ -0,0 +1,99 @@
+package protobuild
+
+import (
+	"os"
+
+	"github.com/pubgo/funk/v2/errors"
+
+	"github.com/pubgo/protobuild/internal/config"
+	"github.com/pubgo/protobuild/internal/depresolver"
+)
+
+// userCfg is the user configuration, loaded with the project config.
+var userCfg = &config.UserConfig{}
+
+// proxyEnvs maps the proxy settings to their environment variables. curl,
+// and so git, reads only the lower case http_proxy, so both cases are set.
+var proxyEnvs = []struct {
+	value func(*config.Proxy) string
+	envs  []string
+}{
+	{func(p *config.Proxy) string { return p.HTTP }, []string{"HTTP_PROXY", "http_proxy"}},
+	{func(p *config.Proxy) string { return p.HTTPS }, []string{"HTTPS_PROXY", "https_proxy"}},
+	{func(p *config.Proxy) string { return p.NoProxy }, []string{"NO_PROXY", "no_proxy"}},
+	{func(p *config.Proxy) string { return p.GoProxy }, []string{"GOPROXY"}},
+}
+
+// loadNetworkConfig loads the user configuration and exports the proxy
+// settings, so downloads, git and go commands use them.
+func loadNetworkConfig() error {
+	cfg, err := config.LoadUserConfig()
+	if err != nil {
+		return errors.Wrapf(err, "failed to load user config %s", config.UserConfigPath())
+	}
+	userCfg = cfg
+	applyProxy(mergeProxy(userCfg.Proxy, globalCfg.Proxy))
+	return nil
+}
+
+// mergeProxy returns the proxy settings of user, falling back to project
+// for the settings user does not have.
+func mergeProxy(user, project *config.Proxy) *config.Proxy {
+	merged := &config.Proxy{}
+	for _, p := range []*config.Proxy{project, user} {
+		if p == nil {
+			continue
+		}
+		if p.HTTP != "" {
+			merged.HTTP = p.HTTP
+		}
+		if p.HTTPS != "" {
+			merged.HTTPS = p.HTTPS
+		}
+		if p.NoProxy != "" {
+			merged.NoProxy = p.NoProxy
+		}
+		if p.GoProxy != "" {
+			merged.GoProxy = p.GoProxy
+		}
+	}
+	return merged
+}
+
+// applyProxy exports the proxy settings. A variable already set in the
+// environment wins, like go env settings are overridden by the environment.
+func applyProxy(proxy *config.Proxy) {
+	for _, p := range proxyEnvs {
+		value := p.value(proxy)
+		if value == "" || anyEnvSet(p.envs) {
+			continue
+		}
+		for _, env := range p.envs {
+			_ = os.Setenv(env, value)
+		}
+	}
+}
+
+func anyEnvSet(envs []string) bool {
+	for _, env := range envs {
+		if os.Getenv(env) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// dependencyMirrors returns the mirror rules of the user and the project
+// config. User rules come first, so they win over project rules with the
+// same prefix.
+func dependencyMirrors() []depresolver.Mirror {
+	var mirrors []depresolver.Mirror
+	for _, rules := range [][]*config.Mirror{userCfg.Mirrors, globalCfg.Mirrors} {
+		for _, rule := range rules {
+			if rule != nil {
+				mirrors = append(mirrors, depresolver.Mirror{From: rule.From, To: rule.To})
+			}
+		}
+	}
+	return mirrors
+}
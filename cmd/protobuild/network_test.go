@@ -0,0 +1,55 @@
+package protobuild
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/pubgo/protobuild/internal/config"
+	"github.com/pubgo/protobuild/internal/depresolver"
+)
+
+func TestMergeProxy(t *testing.T) {
+	got := mergeProxy(
+		&config.Proxy{HTTPS: "http://user:3128"},
+		&config.Proxy{HTTPS: "http://project:3128", GoProxy: "https://goproxy.example.com"},
+	)
+	want := config.Proxy{HTTPS: "http://user:3128", GoProxy: "https://goproxy.example.com"}
+	if *got != want {
+		t.Errorf("mergeProxy() = %+v, want %+v", *got, want)
+	}
+}
+
+func TestApplyProxy(t *testing.T) {
+	for _, env := range []string{"HTTPS_PROXY", "https_proxy", "GOPROXY"} {
+		t.Setenv(env, "")
+	}
+	t.Setenv("GOPROXY", "direct")
+
+	applyProxy(&config.Proxy{HTTPS: "http://proxy:3128", GoProxy: "https://goproxy.example.com"})
+
+	if got := os.Getenv("https_proxy"); got != "http://proxy:3128" {
+		t.Errorf("https_proxy = %q", got)
+	}
+	if got := os.Getenv("GOPROXY"); got != "direct" {
+		t.Errorf("GOPROXY = %q, the environment should win", got)
+	}
+}
+
+func TestLoadNetworkConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	writeTestFiles(t, map[string]string{path: "mirrors:\n  - from: https://github.com/\n    to: https://user.example.com/\n"})
+	t.Setenv("PROTOBUILD_USER_CONFIG", path)
+
+	oldCfg, oldUserCfg := globalCfg, userCfg
+	defer func() { globalCfg, userCfg = oldCfg, oldUserCfg }()
+	globalCfg = Config{Mirrors: []*config.Mirror{{From: "https://github.com/", To: "https://project.example.com/"}}}
+
+	if err := loadNetworkConfig(); err != nil {
+		t.Fatal(err)
+	}
+	resolver := depresolver.NewManager(t.TempDir(), "").WithMirrors(dependencyMirrors())
+	if got := resolver.MirrorURL("https://github.com/foo/bar.git"); got != "https://user.example.com/foo/bar.git" {
+		t.Errorf("MirrorURL() = %q, want the user rule", got)
+	}
+}
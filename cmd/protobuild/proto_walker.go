@@ -109,23 +109,25 @@ func (w *ProtoWalker) CollectPluginConfigs(baseCfg *Config, pluginCfgName string
 				return nil
 			}
 
-			// Check for directory-specific plugin config
-			var pluginCfg *Config
-			pluginCfgPath := filepath.Join(path, pluginCfgName)
-			if pathutil.IsExist(pluginCfgPath) {
-				pluginCfg = parsePluginConfig(pluginCfgPath)
-			} else {
-				// Inherit from parent directory
-				for dir, v := range pluginMap {
-					if strings.HasPrefix(path, dir) {
-						pluginCfg = v
-						break
-					}
+			// Start from the effective config of the nearest parent directory,
+			// which is already merged with the base config.
+			var parentCfg *Config
+			parent := ""
+			for dir, v := range pluginMap {
+				if isSubDir(dir, path) && len(dir) > len(parent) {
+					parent, parentCfg = dir, v
 				}
 			}
 
-			// Merge with base config
-			pluginCfg = mergePluginConfig(baseCfg, pluginCfg)
+			// Merge only configs read from disk, so inherited settings such
+			// as linter overrides are not applied twice.
+			pluginCfg := parentCfg
+			if pluginCfg == nil {
+				pluginCfg = mergePluginConfig(baseCfg)
+			}
+			if pluginCfgPath := filepath.Join(path, pluginCfgName); pathutil.IsExist(pluginCfgPath) {
+				pluginCfg = mergePluginConfig(pluginCfg, parsePluginConfig(pluginCfgPath))
+			}
 
 			// Check excludes
 			for _, e := range pluginCfg.Excludes {
@@ -142,6 +144,12 @@ func (w *ProtoWalker) CollectPluginConfigs(baseCfg *Config, pluginCfgName string
 	return pluginMap
 }
 
+// isSubDir reports whether path is below dir.
+func isSubDir(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != "." && filepath.IsLocal(rel)
+}
+
 // CountProtoFiles counts total proto files in resolved paths.
 func CountProtoFiles(paths map[string]string) int {
 	var total int
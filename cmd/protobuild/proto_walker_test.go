@@ -4,6 +4,8 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+
+	"github.com/pubgo/protobuild/internal/config"
 )
 
 func TestProtoWalker_GetProtoFiles(t *testing.T) {
@@ -164,3 +166,95 @@ func TestFormatBytes(t *testing.T) {
 		}
 	}
 }
+
+func TestCollectPluginConfigs_LinterOverrides(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	legacyDir := filepath.Join(tmpDir, "legacy")
+	nestedDir := filepath.Join(legacyDir, "v1")
+	if err := os.MkdirAll(nestedDir, 0755); err != nil {
+		t.Fatalf("failed to create dirs: %v", err)
+	}
+
+	pluginCfg := []byte("linter:\n  rules:\n    disabled_rules:\n      - core::0192::has-comments\n")
+	if err := os.WriteFile(filepath.Join(legacyDir, protoPluginCfg), pluginCfg, 0644); err != nil {
+		t.Fatalf("failed to create plugin config: %v", err)
+	}
+
+	base := &Config{
+		Root: []string{tmpDir},
+		Linter: &config.Linter{
+			Rules: &config.LinterRules{EnabledRules: []string{"core::0192::has-comments"}},
+		},
+	}
+
+	walker := NewProtoWalker([]string{tmpDir}, nil)
+	pluginMap := walker.CollectPluginConfigs(base, protoPluginCfg)
+
+	rootRules := toLinterConfig(pluginMap[tmpDir].Linter).Rules
+	if !rootRules.IsRuleEnabled("core::0192::has-comments", "") {
+		t.Error("expected rule enabled in root directory")
+	}
+
+	for _, dir := range []string{legacyDir, nestedDir} {
+		rules := toLinterConfig(pluginMap[dir].Linter).Rules
+		if rules.IsRuleEnabled("core::0192::has-comments", "") {
+			t.Errorf("expected rule disabled in %s", dir)
+		}
+	}
+
+	if len(base.Linter.Overrides) != 0 {
+		t.Error("base config should not be modified")
+	}
+}
+
+func TestCollectPluginConfigs_Hierarchy(t *testing.T) {
+	tmpDir := t.TempDir()
+	apiDir := filepath.Join(tmpDir, "api")
+	v1Dir := filepath.Join(apiDir, "v1")
+	internalDir := filepath.Join(v1Dir, "internal")
+	v2Dir := filepath.Join(apiDir, "v2")
+	siblingDir := filepath.Join(tmpDir, "api2")
+	writeTestFiles(t, map[string]string{
+		filepath.Join(apiDir, protoPluginCfg):      "linter:\n  rules:\n    disabled_rules:\n      - core::0192::has-comments\n",
+		filepath.Join(v1Dir, protoPluginCfg):       "includes:\n  - third_party\n",
+		filepath.Join(internalDir, protoPluginCfg): "linter:\n  rules:\n    disabled_rules:\n      - core::0140::lower-snake\n",
+		filepath.Join(v2Dir, "a.proto"):            "",
+		filepath.Join(siblingDir, "a.proto"):       "",
+	})
+
+	base := &Config{
+		Root: []string{tmpDir},
+		Linter: &config.Linter{
+			Rules: &config.LinterRules{EnabledRules: []string{"core::0192::has-comments", "core::0140::lower-snake"}},
+		},
+	}
+
+	walker := NewProtoWalker([]string{tmpDir}, nil)
+	pluginMap := walker.CollectPluginConfigs(base, protoPluginCfg)
+
+	// api2 is not below api and does not inherit its config.
+	if got := pluginMap[siblingDir].Linter.Overrides; len(got) != 0 {
+		t.Errorf("api2 overrides = %d, want none", len(got))
+	}
+
+	// Each config on disk adds one override, inherited ones are not merged
+	// again.
+	for dir, want := range map[string]int{apiDir: 1, v1Dir: 1, v2Dir: 1, internalDir: 2} {
+		if got := len(pluginMap[dir].Linter.Overrides); got != want {
+			t.Errorf("%s overrides = %d, want %d", dir, got, want)
+		}
+	}
+
+	// The nested config merges with its parent's, not the base config.
+	rules := toLinterConfig(pluginMap[internalDir].Linter).Rules
+	if rules.IsRuleEnabled("core::0192::has-comments", "") || rules.IsRuleEnabled("core::0140::lower-snake", "") {
+		t.Error("expected both rules disabled in api/v1/internal")
+	}
+	if got := pluginMap[internalDir].Includes; len(got) != 1 || got[0] != "third_party" {
+		t.Errorf("api/v1/internal includes = %v, want the includes of api/v1", got)
+	}
+	if !toLinterConfig(pluginMap[siblingDir].Linter).Rules.IsRuleEnabled("core::0192::has-comments", "") {
+		t.Error("expected rule enabled in api2")
+	}
+}
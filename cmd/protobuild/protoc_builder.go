@@ -54,10 +54,12 @@ func (c *ProtocCommand) Execute() error {
 	mainCmd, retagCmd := c.build()
 
 	logger := log.GetLogger("protobuild")
-	logger.Info().Msg(mainCmd)
+	if mainCmd != "" {
+		logger.Info().Msg(mainCmd)
 
-	if err := shutil.Shell(mainCmd).Run(); err != nil {
-		return fmt.Errorf("protoc failed: %w", err)
+		if err := shutil.Shell(mainCmd).Run(); err != nil {
+			return fmt.Errorf("protoc failed: %w", err)
+		}
 	}
 
 	// Run retag plugin separately if configured
@@ -71,9 +73,22 @@ func (c *ProtocCommand) Execute() error {
 	return nil
 }
 
+// includePaths returns the -I paths of the command, in the order protoc
+// searches them.
+func (c *ProtocCommand) includePaths() []string {
+	return protocIncludePaths(c.includes, c.vendor, c.pwd)
+}
+
+// protocIncludePaths returns the import paths of the project: the includes,
+// then the vendor directory, then the project root. gen and build resolve
+// imports the same way through it.
+func protocIncludePaths(includes []string, vendor, pwd string) []string {
+	return lo.Uniq(lo.Compact(append(append([]string{}, includes...), vendor, pwd)))
+}
+
 // build constructs the protoc command strings.
 func (c *ProtocCommand) build() (mainCmd, retagCmd string) {
-	includes := lo.Uniq(append(c.includes, c.vendor, c.pwd))
+	includes := c.includePaths()
 
 	// Build base command with includes
 	var base strings.Builder
@@ -85,11 +100,18 @@ func (c *ProtocCommand) build() (mainCmd, retagCmd string) {
 	var pluginArgs strings.Builder
 	var retagArgs strings.Builder
 
+	var builtins int
 	for _, plg := range c.cfg.Plugins {
 		if plg.SkipRun {
 			continue
 		}
 
+		// Builtin plugins run in-process, see runBuiltinPlugins.
+		if isBuiltinPlugin(plg) {
+			builtins++
+			continue
+		}
+
 		args := c.buildPluginArgs(plg)
 
 		if plg.Name == reTagPluginName {
@@ -101,7 +123,10 @@ func (c *ProtocCommand) build() (mainCmd, retagCmd string) {
 
 	protoFiles := filepath.Join(c.protoPath, "*.proto")
 
-	mainCmd = base.String() + pluginArgs.String() + " " + protoFiles
+	// Skip protoc when every plugin but retag is builtin.
+	if pluginArgs.Len() > 0 || builtins == 0 {
+		mainCmd = base.String() + pluginArgs.String() + " " + protoFiles
+	}
 	if retagArgs.Len() > 0 {
 		retagCmd = base.String() + retagArgs.String() + " " + protoFiles
 	}
@@ -164,7 +189,7 @@ func (c *ProtocCommand) buildPluginArgs(plg *plugin) string {
 // resolveOutputDir determines the output directory for a plugin.
 func (c *ProtocCommand) resolveOutputDir(plg *plugin) string {
 	// Special handling for doc plugin
-	if plg.Name == "doc" {
+	if plg.Name == "doc" || plg.Name == builtinDocPluginName {
 		out := filepath.Join(plg.Out, c.protoPath)
 		assert.Must(pathutil.IsNotExistMkDir(out))
 		return out
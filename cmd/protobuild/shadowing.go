@@ -0,0 +1,179 @@
+package protobuild
+
+import (
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Ways an import path found in more than one include path is reported, set
+// by import_shadowing.
+const (
+	shadowingWarn   = "warn"
+	shadowingError  = "error"
+	shadowingIgnore = "ignore"
+)
+
+// shadowedImport is an import path found in more than one include path.
+// protoc uses the file of the first include path, the others are shadowed.
+type shadowedImport struct {
+	Import   string
+	Winner   string
+	Shadowed []string
+}
+
+// findShadowedImports returns the import paths found in more than one of
+// the include paths, in the order protoc searches them. Hidden directories
+// below an include path, such as the vendor directory under the project
+// root, and directories that are include paths themselves, such as proto
+// directories under the project root, are not searched.
+func findShadowedImports(includes []string) []shadowedImport {
+	includeDirs := make(map[string]bool)
+	for _, inc := range includes {
+		if abs, err := filepath.Abs(inc); err == nil {
+			includeDirs[abs] = true
+		}
+	}
+
+	files := make(map[string][]string)
+	for _, inc := range includes {
+		_ = filepath.WalkDir(inc, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return nil
+			}
+			if d.IsDir() {
+				if path == inc {
+					return nil
+				}
+				if strings.HasPrefix(d.Name(), ".") {
+					return filepath.SkipDir
+				}
+				if abs, err := filepath.Abs(path); err == nil && includeDirs[abs] {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if !strings.HasSuffix(path, ".proto") {
+				return nil
+			}
+
+			rel, err := filepath.Rel(inc, path)
+			if err != nil {
+				return nil
+			}
+			name := filepath.ToSlash(rel)
+			// The same file reached through two spellings of one include
+			// path does not shadow itself.
+			if !containsFile(files[name], path) {
+				files[name] = append(files[name], path)
+			}
+			return nil
+		})
+	}
+
+	var shadowed []shadowedImport
+	for name, paths := range files {
+		if len(paths) < 2 {
+			continue
+		}
+		shadowed = append(shadowed, shadowedImport{Import: name, Winner: paths[0], Shadowed: paths[1:]})
+	}
+	sort.Slice(shadowed, func(i, j int) bool { return shadowed[i].Import < shadowed[j].Import })
+	return shadowed
+}
+
+func containsFile(paths []string, path string) bool {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return false
+	}
+	for _, p := range paths {
+		if pAbs, err := filepath.Abs(p); err == nil && pAbs == abs {
+			return true
+		}
+	}
+	return false
+}
+
+// shadowingChecked holds the result of checkImportShadowing per mode and
+// include set, so that proto directories sharing their include paths are
+// checked and reported once.
+var shadowingChecked = make(map[string]error)
+
+// checkImportShadowing prints the import paths shadowed across includes
+// and, if mode is error, fails on them.
+func checkImportShadowing(mode string, includes []string) error {
+	switch mode {
+	case "", shadowingWarn, shadowingError:
+	case shadowingIgnore:
+		return nil
+	default:
+		return fmt.Errorf("invalid import_shadowing %q, want %s, %s or %s", mode, shadowingWarn, shadowingError, shadowingIgnore)
+	}
+
+	key := mode + "\x00" + strings.Join(includes, "\x00")
+	if err, ok := shadowingChecked[key]; ok {
+		return err
+	}
+	err := reportShadowedImports(mode, includes)
+	shadowingChecked[key] = err
+	return err
+}
+
+func reportShadowedImports(mode string, includes []string) error {
+	shadowed := findShadowedImports(includes)
+	if len(shadowed) == 0 {
+		return nil
+	}
+
+	fmt.Printf("⚠️  %d import paths are found in more than one include path:\n", len(shadowed))
+	for _, s := range shadowed {
+		fmt.Printf("  - %s\n", s.Import)
+		fmt.Printf("      using:    %s\n", describeIncludeFile(s.Winner))
+		for _, path := range s.Shadowed {
+			fmt.Printf("      shadowed: %s\n", describeIncludeFile(path))
+		}
+	}
+
+	if mode == shadowingError {
+		return fmt.Errorf("%d import paths are shadowed, rename or exclude them, or set import_shadowing: warn", len(shadowed))
+	}
+	return nil
+}
+
+// describeIncludeFile returns path with the dependency it was vendored from,
+// if any.
+func describeIncludeFile(path string) string {
+	if name := vendorDepName(globalCfg.Vendor, globalCfg.Depends, path); name != "" {
+		return fmt.Sprintf("%s (dep %s)", path, name)
+	}
+	return path
+}
+
+// vendorDepName returns the name of the dependency a file in the vendor
+// directory belongs to, or "". Dependencies are vendored under their name,
+// the longest matching name wins.
+func vendorDepName(vendor string, deps []*depend, path string) string {
+	if vendor == "" {
+		return ""
+	}
+	rel, err := filepath.Rel(vendor, path)
+	if err != nil || !filepath.IsLocal(rel) {
+		return ""
+	}
+	rel = filepath.ToSlash(rel)
+
+	var name string
+	for _, dep := range deps {
+		depName := strings.Trim(filepath.ToSlash(dep.Name), "/")
+		if depName == "" || len(depName) <= len(name) {
+			continue
+		}
+		if strings.HasPrefix(rel, depName+"/") {
+			name = depName
+		}
+	}
+	return name
+}
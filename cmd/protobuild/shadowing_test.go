@@ -0,0 +1,101 @@
+package protobuild
+
+import (
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestFindShadowedImports(t *testing.T) {
+	tmpDir := t.TempDir()
+	project := filepath.Join(tmpDir, "proto")
+	vendor := filepath.Join(tmpDir, ".proto")
+	writeTestFiles(t, map[string]string{
+		filepath.Join(project, "google", "api", "http.proto"): "project",
+		filepath.Join(project, "acme", "a.proto"):             "project",
+		filepath.Join(project, ".hidden", "b.proto"):          "hidden",
+		filepath.Join(vendor, "google", "api", "http.proto"):  "vendored",
+		filepath.Join(vendor, ".hidden", "b.proto"):           "hidden",
+	})
+
+	includes := []string{project, vendor, project + string(filepath.Separator)}
+	got := findShadowedImports(includes)
+	if len(got) != 1 {
+		t.Fatalf("findShadowedImports() = %+v, want one import", got)
+	}
+	if got[0].Import != "google/api/http.proto" {
+		t.Errorf("Import = %q", got[0].Import)
+	}
+	if want := filepath.Join(project, "google", "api", "http.proto"); got[0].Winner != want {
+		t.Errorf("Winner = %q, want the file of the first include path %q", got[0].Winner, want)
+	}
+	if want := []string{filepath.Join(vendor, "google", "api", "http.proto")}; !slices.Equal(got[0].Shadowed, want) {
+		t.Errorf("Shadowed = %v, want %v", got[0].Shadowed, want)
+	}
+}
+
+func TestFindShadowedImports_NestedIncludes(t *testing.T) {
+	tmpDir := t.TempDir()
+	root := filepath.Join(tmpDir, "app")
+	project := filepath.Join(root, "proto")
+	vendor := filepath.Join(tmpDir, "vendor")
+	writeTestFiles(t, map[string]string{
+		filepath.Join(project, "a.proto"):         "project",
+		filepath.Join(vendor, "proto", "a.proto"): "vendored",
+		filepath.Join(root, "api", "b.proto"):     "root",
+		filepath.Join(vendor, "api", "b.proto"):   "vendored",
+	})
+
+	// The project root is searched without the proto directory below it,
+	// which is an include path of its own.
+	got := findShadowedImports([]string{project, vendor, root})
+	if len(got) != 1 || got[0].Import != "api/b.proto" {
+		t.Fatalf("findShadowedImports() = %+v, want only api/b.proto", got)
+	}
+	if want := filepath.Join(vendor, "api", "b.proto"); got[0].Winner != want {
+		t.Errorf("Winner = %q, want %q", got[0].Winner, want)
+	}
+}
+
+func TestCheckImportShadowing(t *testing.T) {
+	tmpDir := t.TempDir()
+	a, b := filepath.Join(tmpDir, "a"), filepath.Join(tmpDir, "b")
+	writeTestFiles(t, map[string]string{
+		filepath.Join(a, "x.proto"): "a",
+		filepath.Join(b, "x.proto"): "b",
+	})
+
+	tests := map[string]bool{"": false, "warn": false, "ignore": false, "error": true, "bogus": true}
+	for mode, wantErr := range tests {
+		if err := checkImportShadowing(mode, []string{a, b}); (err != nil) != wantErr {
+			t.Errorf("checkImportShadowing(%q) = %v, want error %v", mode, err, wantErr)
+		}
+	}
+
+	// Include sets are checked once, the result is reused.
+	c := filepath.Join(tmpDir, "c")
+	if err := checkImportShadowing(shadowingError, []string{a, c}); err != nil {
+		t.Fatal(err)
+	}
+	writeTestFiles(t, map[string]string{filepath.Join(c, "x.proto"): "c"})
+	if err := checkImportShadowing(shadowingError, []string{a, c}); err != nil {
+		t.Errorf("checkImportShadowing() = %v, want the cached result", err)
+	}
+}
+
+func TestVendorDepName(t *testing.T) {
+	vendor := filepath.Join("work", ".proto")
+	deps := []*depend{{Name: "google"}, {Name: "google/api"}, {Name: "envoy"}}
+
+	tests := map[string]string{
+		filepath.Join(vendor, "google", "api", "http.proto"):  "google/api",
+		filepath.Join(vendor, "google", "type", "date.proto"): "google",
+		filepath.Join(vendor, "acme", "a.proto"):              "",
+		filepath.Join("work", "proto", "a.proto"):             "",
+	}
+	for path, want := range tests {
+		if got := vendorDepName(vendor, deps, path); got != want {
+			t.Errorf("vendorDepName(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
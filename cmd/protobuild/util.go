@@ -5,6 +5,7 @@ import (
 	"log/slog"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/a8m/envsubst"
 	"github.com/cnf/structhash"
@@ -14,6 +15,7 @@ import (
 	"github.com/pubgo/funk/v2/errors"
 	"github.com/pubgo/funk/v2/pathutil"
 	"github.com/pubgo/funk/v2/strutil"
+	"github.com/pubgo/protobuild/cmd/format"
 	"github.com/pubgo/protobuild/cmd/linters"
 	"github.com/pubgo/protobuild/internal/config"
 	"gopkg.in/yaml.v3"
@@ -30,16 +32,80 @@ func toLinterConfig(l *config.Linter) linters.LinterConfig {
 		IgnoreCommentDisablesFlag: l.IgnoreCommentDisablesFlag,
 	}
 
-	if l.Rules != nil {
-		cfg.Rules = lint.Config{
-			EnabledRules:  l.Rules.EnabledRules,
-			DisabledRules: l.Rules.DisabledRules,
+	// Later configs take precedence, so directory overrides follow the root rules.
+	for _, rules := range append([]*config.LinterRules{l.Rules}, l.Overrides...) {
+		if rules == nil {
+			continue
+		}
+		cfg.Rules = append(cfg.Rules, lint.Config{
+			EnabledRules:  rules.EnabledRules,
+			DisabledRules: rules.DisabledRules,
+		})
+
+		for rule, sev := range rules.Severity {
+			if cfg.Severity == nil {
+				cfg.Severity = make(map[string]linters.Severity)
+			}
+			cfg.Severity[rule] = linters.Severity(strings.ToLower(sev))
 		}
 	}
 
 	return cfg
 }
 
+// toFormatOptions converts the shared config Format to format.Options.
+// Imports are resolved with the project vendor and include paths.
+func toFormatOptions(cfg *Config) format.Options {
+	opts := format.DefaultOptions()
+	opts.Vendor = cfg.Vendor
+	opts.ImportPaths = cfg.Includes
+
+	f := cfg.Format
+	if f == nil {
+		return opts
+	}
+
+	if f.IndentWidth > 0 {
+		opts.IndentWidth = f.IndentWidth
+	}
+	if f.MaxLineLength > 0 {
+		opts.MaxLineLength = f.MaxLineLength
+	}
+	if f.CompactOptions > 0 {
+		opts.CompactOptionsThreshold = f.CompactOptions
+	}
+	if f.Imports != nil {
+		if f.Imports.Sort != nil {
+			opts.SortImports = *f.Imports.Sort
+		}
+		opts.GroupImports = f.Imports.Group
+		opts.RemoveUnusedImports = f.Imports.RemoveUnused
+	}
+
+	return opts
+}
+
+// mergeLinterConfig layers a directory-level linter config on top of base.
+func mergeLinterConfig(base, override *config.Linter) *config.Linter {
+	if base == nil {
+		base = &config.Linter{}
+	}
+
+	merged := *base
+	merged.Overrides = append(append([]*config.LinterRules{}, base.Overrides...), override.Rules)
+	merged.Overrides = append(merged.Overrides, override.Overrides...)
+
+	if override.FormatType != "" {
+		merged.FormatType = override.FormatType
+	}
+
+	if override.IgnoreCommentDisablesFlag {
+		merged.IgnoreCommentDisablesFlag = true
+	}
+
+	return &merged
+}
+
 func mergePluginConfig(base *Config, pluginConfigs ...*Config) *Config {
 	base = clone.Clone(base).(*Config)
 	for _, cfg := range pluginConfigs {
@@ -66,6 +132,10 @@ func mergePluginConfig(base *Config, pluginConfigs ...*Config) *Config {
 		if len(cfg.Plugins) > 0 {
 			base.Plugins = cfg.Plugins
 		}
+
+		if cfg.Linter != nil {
+			base.Linter = mergeLinterConfig(base.Linter, cfg.Linter)
+		}
 	}
 
 	if base.BasePlugin == nil {
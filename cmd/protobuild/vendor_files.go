@@ -0,0 +1,98 @@
+package protobuild
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/bmatcuk/doublestar/v4"
+)
+
+// defaultVendorInclude is vendored for dependencies without include globs.
+var defaultVendorInclude = []string{"**/*.proto"}
+
+// vendorMapping selects the files of a dependency to vendor and maps them to
+// their path in the vendor tree. Paths are slash separated and relative to
+// the resolved dependency directory.
+type vendorMapping struct {
+	include     []string
+	exclude     []string
+	stripPrefix string
+	// renames are the rename prefixes, longest first.
+	renames []string
+	rename  map[string]string
+}
+
+// newVendorMapping returns the mapping of dep, which may be nil.
+func newVendorMapping(dep *depend) (*vendorMapping, error) {
+	m := &vendorMapping{include: defaultVendorInclude}
+	if dep == nil {
+		return m, nil
+	}
+
+	if len(dep.Include) > 0 {
+		m.include = dep.Include
+	}
+	m.exclude = dep.Exclude
+	for _, pattern := range append(append([]string{}, m.include...), m.exclude...) {
+		if !doublestar.ValidatePattern(pattern) {
+			return nil, fmt.Errorf("dependency %s: invalid glob %q", dep.Name, pattern)
+		}
+	}
+
+	m.stripPrefix = strings.Trim(dep.StripPrefix, "/")
+	m.rename = make(map[string]string, len(dep.Rename))
+	for from, to := range dep.Rename {
+		from = strings.Trim(from, "/")
+		if from == "" {
+			return nil, fmt.Errorf("dependency %s: empty rename source", dep.Name)
+		}
+		m.rename[from] = strings.Trim(to, "/")
+		m.renames = append(m.renames, from)
+	}
+	sort.Slice(m.renames, func(i, j int) bool { return len(m.renames[i]) > len(m.renames[j]) })
+	return m, nil
+}
+
+// target returns the vendor path of the file rel, or false if the file is
+// not vendored. Globs match rel; strip_prefix and then the longest matching
+// rename prefix are applied to the result.
+func (m *vendorMapping) target(rel string) (string, bool) {
+	if !matchAny(m.include, rel) || matchAny(m.exclude, rel) {
+		return "", false
+	}
+
+	target := rel
+	if m.stripPrefix != "" {
+		if trimmed, ok := cutPathPrefix(target, m.stripPrefix); ok {
+			target = trimmed
+		}
+	}
+	for _, from := range m.renames {
+		if rest, ok := cutPathPrefix(target, from); ok {
+			target = path.Join(m.rename[from], rest)
+			break
+		}
+	}
+	return target, target != "" && target != "."
+}
+
+func matchAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := doublestar.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// cutPathPrefix removes the path prefix from p at a path element boundary.
+// A prefix equal to p leaves an empty path.
+func cutPathPrefix(p, prefix string) (string, bool) {
+	if p == prefix {
+		return "", true
+	}
+	rest, ok := strings.CutPrefix(p, prefix+"/")
+	return rest, ok
+}
@@ -2,21 +2,30 @@ package protobuild
 
 import (
 	"context"
+	"crypto/sha256"
 	"fmt"
 	"io"
 	"io/fs"
 	"os"
 	"path/filepath"
-	"strings"
+	"slices"
+	"sort"
+	"time"
 
-	"github.com/pubgo/funk/v2/assert"
 	"github.com/pubgo/funk/v2/errors"
 	"github.com/pubgo/funk/v2/pathutil"
-	"github.com/pubgo/funk/v2/recovery"
 	"github.com/pubgo/protobuild/internal/depresolver"
+	"github.com/pubgo/protobuild/internal/lockfile"
 	"github.com/schollz/progressbar/v3"
 )
 
+// vendorLockTimeout is how long vendor waits for another vendor run.
+const vendorLockTimeout = 10 * time.Minute
+
+// defaultRefreshTTL is how long git branches and HTTP archives are used from
+// the cache before they are checked for changes, unless cache.ttl is set.
+const defaultRefreshTTL = 24 * time.Hour
+
 // VendorService handles dependency vendoring operations.
 type VendorService struct {
 	resolver *depresolver.Manager
@@ -26,8 +35,11 @@ type VendorService struct {
 // NewVendorService creates a new VendorService.
 func NewVendorService(config *Config) *VendorService {
 	return &VendorService{
-		resolver: depresolver.NewManager("", ""),
-		config:   config,
+		resolver: depresolver.NewManager("", "").
+			WithProject(protoCfg).
+			WithOffline(offline).
+			WithMirrors(dependencyMirrors()),
+		config: config,
 	}
 }
 
@@ -38,8 +50,10 @@ type VendorResult struct {
 	Changed       bool
 }
 
-// ResolveDependencies resolves all configured dependencies.
-func (s *VendorService) ResolveDependencies(ctx context.Context, update bool) (*VendorResult, error) {
+// ResolveDependencies resolves all configured dependencies. With update, the
+// cached copies of the named dependencies, or of all if none are named, are
+// downloaded again.
+func (s *VendorService) ResolveDependencies(ctx context.Context, update bool, names ...string) (*VendorResult, error) {
 	result := &VendorResult{
 		ResolvedPaths: make(map[string]string),
 	}
@@ -50,12 +64,27 @@ func (s *VendorService) ResolveDependencies(ctx context.Context, update bool) (*
 		return result, nil
 	}
 
+	ttl, err := s.refreshTTL()
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid cache config")
+	}
+	s.resolver.WithRefreshTTL(ttl)
+
+	updateDeps := validDeps
+	if len(names) > 0 {
+		if updateDeps, err = selectDeps(validDeps, names); err != nil {
+			return nil, err
+		}
+	}
+
 	fmt.Printf("\n🔍 Resolving %d dependencies...\n\n", len(validDeps))
 
-	// Clean cache if update flag is set
+	// Evict cached dependencies if update flag is set
 	if update {
-		fmt.Println("🗑️  Cleaning dependency cache...")
-		_ = s.resolver.CleanCache()
+		fmt.Println("🗑️  Cleaning cached dependencies...")
+		for _, dep := range updateDeps {
+			_ = s.resolver.Evict(toResolverDep(dep))
+		}
 		fmt.Println()
 	}
 
@@ -65,11 +94,12 @@ func (s *VendorService) ResolveDependencies(ctx context.Context, update bool) (*
 			continue
 		}
 
-		resolverDep := s.toResolverDep(dep)
+		resolverDep := toResolverDep(dep)
 		resolved, err := s.resolver.Resolve(ctx, resolverDep)
 
 		if err != nil {
-			if dep.Optional != nil && *dep.Optional {
+			// Integrity failures are never skipped, even for optional deps.
+			if dep.Optional != nil && *dep.Optional && !errors.Is(err, depresolver.ErrChecksumMismatch) {
 				fmt.Printf("  ⚠️  [optional] %s - skipped\n", dep.Name)
 				continue
 			}
@@ -100,59 +130,181 @@ func (s *VendorService) ResolveDependencies(ctx context.Context, update bool) (*
 	return result, nil
 }
 
-// CopyToVendor copies resolved dependencies to the vendor directory.
+// Lock takes the vendor lock of the project, so concurrent vendor runs do
+// not update the vendor directory at the same time. It waits for a running
+// vendor to finish.
+func (s *VendorService) Lock() (unlock func(), err error) {
+	vendor, err := filepath.Abs(s.config.Vendor)
+	if err != nil {
+		return nil, err
+	}
+
+	path := filepath.Join(filepath.Dir(s.resolver.CacheDir()), "locks", fmt.Sprintf("%x.lock", sha256.Sum256([]byte(vendor))))
+	lock, err := lockfile.Acquire(path, vendorLockTimeout, func() {
+		fmt.Printf("⏳ Waiting for another vendor run on %s...\n", s.config.Vendor)
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to lock vendor directory")
+	}
+	return func() { _ = lock.Unlock() }, nil
+}
+
+// CopyToVendor copies resolved dependencies to the vendor directory. The
+// files are copied into a staging directory that then replaces the vendor
+// directory, which is left unchanged if copying fails.
 func (s *VendorService) CopyToVendor(resolvedPaths map[string]string) (int, error) {
 	fmt.Printf("\n📁 Updating vendor directory: %s\n", s.config.Vendor)
-	_ = os.RemoveAll(s.config.Vendor)
 
-	totalFiles := CountProtoFiles(resolvedPaths)
+	vendor := filepath.Clean(s.config.Vendor)
+	recoverVendor(vendor)
+	staging, err := os.MkdirTemp(filepath.Dir(vendor), filepath.Base(vendor)+".staging-")
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to create staging directory")
+	}
+	defer func() { _ = os.RemoveAll(staging) }()
+
+	files, err := s.vendorFiles(resolvedPaths)
+	if err != nil {
+		return 0, err
+	}
 
-	bar := progressbar.NewOptions(totalFiles,
-		progressbar.OptionSetDescription("  📋 Copying proto files"),
+	bar := progressbar.NewOptions(len(files),
+		progressbar.OptionSetDescription("  📋 Copying files"),
 		progressbar.OptionShowCount(),
 		progressbar.OptionSetWidth(30),
 		progressbar.OptionOnCompletion(func() { fmt.Println() }),
 	)
 
-	var copiedFiles int
-	for name, localPath := range resolvedPaths {
+	for _, f := range files {
+		newPath := filepath.Join(staging, f.target)
+		if err := os.MkdirAll(filepath.Dir(newPath), 0o755); err != nil {
+			return 0, err
+		}
+		if _, err := copyFile(newPath, f.source); err != nil {
+			return 0, errors.WrapTags(err, errors.Tags{"path": f.source})
+		}
+		_ = bar.Add(1)
+	}
+
+	_ = bar.Finish()
+
+	if err := replaceVendor(staging, vendor); err != nil {
+		return 0, err
+	}
+	return len(files), nil
+}
+
+// vendorFile is a file to vendor.
+type vendorFile struct {
+	source string
+	target string // relative to the vendor directory
+}
+
+// vendorFiles lists the files of the resolved dependencies to vendor, as
+// selected and mapped by their include, exclude, strip_prefix and rename
+// settings. Two files with the same target are an error.
+func (s *VendorService) vendorFiles(resolvedPaths map[string]string) ([]vendorFile, error) {
+	names := make([]string, 0, len(resolvedPaths))
+	for name := range resolvedPaths {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var files []vendorFile
+	sources := make(map[string]string)
+	for _, name := range names {
+		localPath := resolvedPaths[name]
 		if pathutil.IsNotExist(localPath) {
 			fmt.Printf("  ⚠️  Path not found: %s (%s)\n", name, localPath)
 			continue
 		}
 
-		newUrl := filepath.Join(s.config.Vendor, name)
-		err := filepath.Walk(localPath, func(path string, info fs.FileInfo, err error) (gErr error) {
-			if err != nil {
-				return err
+		var dep *depend
+		if idx := slices.IndexFunc(s.config.Depends, func(d *depend) bool { return d.Name == name }); idx >= 0 {
+			dep = s.config.Depends[idx]
+		}
+		depFiles, err := dependencyFiles(name, dep, localPath)
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range depFiles {
+			if prev, ok := sources[f.target]; ok {
+				return nil, fmt.Errorf("%s and %s are both vendored as %s", prev, f.source, f.target)
 			}
+			sources[f.target] = f.source
+			files = append(files, f)
+		}
+	}
+	return files, nil
+}
 
-			defer recovery.Err(&gErr, func(err error) error {
-				return errors.WrapTags(err, errors.Tags{
-					"path": path,
-					"name": info.Name(),
-				})
-			})
-
-			if info.IsDir() || !strings.HasSuffix(info.Name(), ".proto") {
-				return nil
-			}
+// dependencyFiles lists the files of the dependency name resolved at
+// localPath to vendor, with targets relative to the vendor directory. dep
+// may be nil.
+func dependencyFiles(name string, dep *depend, localPath string) ([]vendorFile, error) {
+	mapping, err := newVendorMapping(dep)
+	if err != nil {
+		return nil, err
+	}
 
-			newPath := filepath.Join(newUrl, strings.TrimPrefix(path, localPath))
-			assert.Must(pathutil.IsNotExistMkDir(filepath.Dir(newPath)))
-			assert.Must1(copyFile(newPath, path))
-			copiedFiles++
-			_ = bar.Add(1)
+	var files []vendorFile
+	err = filepath.WalkDir(localPath, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
 
-			return nil
-		})
+		rel, err := filepath.Rel(localPath, path)
 		if err != nil {
-			return copiedFiles, err
+			return err
+		}
+		target, ok := mapping.target(filepath.ToSlash(rel))
+		if !ok {
+			return nil
 		}
+		if !filepath.IsLocal(filepath.FromSlash(target)) {
+			return fmt.Errorf("dependency %s: %s is mapped outside the vendor directory: %s", name, rel, target)
+		}
+
+		files = append(files, vendorFile{source: path, target: filepath.Join(name, filepath.FromSlash(target))})
+		return nil
+	})
+	return files, err
+}
+
+// replaceVendor replaces the vendor directory with staging. The old vendor
+// directory is moved aside first and restored if the replacement fails.
+func replaceVendor(staging, vendor string) error {
+	backup := vendor + ".old"
+	_ = os.RemoveAll(backup)
+	if err := os.Rename(vendor, backup); err != nil && !os.IsNotExist(err) {
+		return errors.Wrap(err, "failed to move vendor directory aside")
+	}
+	if err := os.Rename(staging, vendor); err != nil {
+		_ = os.Rename(backup, vendor)
+		return errors.Wrap(err, "failed to replace vendor directory")
 	}
+	_ = os.RemoveAll(backup)
+	return nil
+}
 
-	_ = bar.Finish()
-	return copiedFiles, nil
+// recoverVendor cleans up after a vendor run that was interrupted: leftover
+// staging directories are removed and a vendor directory that was moved
+// aside is restored.
+func recoverVendor(vendor string) {
+	stagings, _ := filepath.Glob(vendor + ".staging-*")
+	for _, dir := range stagings {
+		_ = os.RemoveAll(dir)
+	}
+
+	backup := vendor + ".old"
+	if pathutil.IsNotExist(backup) {
+		return
+	}
+	if pathutil.IsNotExist(vendor) {
+		_ = os.Rename(backup, vendor)
+		return
+	}
+	_ = os.RemoveAll(backup)
 }
 
 // CleanCache cleans the dependency cache.
@@ -160,11 +312,54 @@ func (s *VendorService) CleanCache() error {
 	return s.resolver.CleanCache()
 }
 
+// EnforceCacheLimits removes cache entries over the limits of the cache
+// config, if any.
+func (s *VendorService) EnforceCacheLimits() error {
+	if s.config.Cache == nil {
+		return nil
+	}
+
+	policy, err := cachePolicy(s.config.Cache.MaxAge, s.config.Cache.MaxSize)
+	if err != nil {
+		return errors.Wrap(err, "invalid cache config")
+	}
+	removed, err := s.resolver.GC(policy)
+	if err != nil {
+		return err
+	}
+	if len(removed) > 0 {
+		fmt.Printf("🧹 Cache GC: removed %d entries (%s)\n", len(removed), formatBytes(cacheEntriesSize(removed)))
+	}
+	return nil
+}
+
 // CacheDir returns the cache directory path.
 func (s *VendorService) CacheDir() string {
 	return s.resolver.CacheDir()
 }
 
+// refreshTTL returns the refresh interval of mutable refs from the cache
+// config, defaultRefreshTTL if it is not set.
+func (s *VendorService) refreshTTL() (time.Duration, error) {
+	if s.config.Cache == nil || s.config.Cache.TTL == "" {
+		return defaultRefreshTTL, nil
+	}
+	return depresolver.ParseAge(s.config.Cache.TTL)
+}
+
+// selectDeps returns the dependencies with the given names.
+func selectDeps(deps []*depend, names []string) ([]*depend, error) {
+	var selected []*depend
+	for _, name := range names {
+		idx := slices.IndexFunc(deps, func(dep *depend) bool { return dep.Name == name })
+		if idx < 0 {
+			return nil, fmt.Errorf("unknown dependency %q", name)
+		}
+		selected = append(selected, deps[idx])
+	}
+	return selected, nil
+}
+
 // filterValidDeps returns dependencies with valid name and url.
 func (s *VendorService) filterValidDeps() []*depend {
 	var valid []*depend
@@ -177,7 +372,7 @@ func (s *VendorService) filterValidDeps() []*depend {
 }
 
 // toResolverDep converts a config depend to depresolver.Dependency.
-func (s *VendorService) toResolverDep(dep *depend) *depresolver.Dependency {
+func toResolverDep(dep *depend) *depresolver.Dependency {
 	return &depresolver.Dependency{
 		Name:     dep.Name,
 		Source:   depresolver.Source(dep.Source),
@@ -185,6 +380,7 @@ func (s *VendorService) toResolverDep(dep *depend) *depresolver.Dependency {
 		Path:     dep.Path,
 		Version:  dep.Version,
 		Optional: dep.Optional,
+		Checksum: dep.Checksum,
 	}
 }
 
@@ -0,0 +1,181 @@
+package protobuild
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeTestFiles(t *testing.T, files map[string]string) {
+	t.Helper()
+	for path, content := range files {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestCopyToVendor(t *testing.T) {
+	tmpDir := t.TempDir()
+	vendorDir := filepath.Join(tmpDir, ".proto")
+	depDir := filepath.Join(tmpDir, "dep")
+	writeTestFiles(t, map[string]string{
+		filepath.Join(vendorDir, "old", "old.proto"): "old",
+		filepath.Join(depDir, "a", "a.proto"):        "a",
+		filepath.Join(depDir, "README.md"):           "skipped",
+	})
+
+	svc := &VendorService{config: &Config{Vendor: vendorDir}}
+	copied, err := svc.CopyToVendor(map[string]string{"acme": depDir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if copied != 1 {
+		t.Errorf("copied %d files, want 1", copied)
+	}
+	if data, err := os.ReadFile(filepath.Join(vendorDir, "acme", "a", "a.proto")); err != nil || string(data) != "a" {
+		t.Errorf("vendored file = %q, %v", data, err)
+	}
+	if _, err := os.Stat(filepath.Join(vendorDir, "old")); !os.IsNotExist(err) {
+		t.Error("stale vendor content should be removed")
+	}
+
+	leftovers, _ := filepath.Glob(filepath.Join(tmpDir, ".proto.*"))
+	if len(leftovers) != 0 {
+		t.Errorf("staging leftovers: %v", leftovers)
+	}
+}
+
+func TestCopyToVendor_FailureKeepsVendor(t *testing.T) {
+	tmpDir := t.TempDir()
+	vendorDir := filepath.Join(tmpDir, ".proto")
+	depDir := filepath.Join(tmpDir, "dep")
+	writeTestFiles(t, map[string]string{
+		filepath.Join(vendorDir, "old", "old.proto"): "old",
+		filepath.Join(depDir, "a.proto"):             "a",
+	})
+	// A dangling symlink fails to copy.
+	if err := os.Symlink(filepath.Join(tmpDir, "missing"), filepath.Join(depDir, "b.proto")); err != nil {
+		t.Skip(err)
+	}
+
+	svc := &VendorService{config: &Config{Vendor: vendorDir}}
+	if _, err := svc.CopyToVendor(map[string]string{"acme": depDir}); err == nil {
+		t.Fatal("expected copy error")
+	}
+	if data, err := os.ReadFile(filepath.Join(vendorDir, "old", "old.proto")); err != nil || string(data) != "old" {
+		t.Errorf("vendor directory changed after failure: %q, %v", data, err)
+	}
+	leftovers, _ := filepath.Glob(filepath.Join(tmpDir, ".proto.*"))
+	if len(leftovers) != 0 {
+		t.Errorf("staging leftovers: %v", leftovers)
+	}
+}
+
+func TestRecoverVendor(t *testing.T) {
+	tmpDir := t.TempDir()
+	vendorDir := filepath.Join(tmpDir, ".proto")
+	writeTestFiles(t, map[string]string{
+		filepath.Join(vendorDir+".old", "a.proto"):         "a",
+		filepath.Join(vendorDir+".staging-123", "b.proto"): "b",
+	})
+
+	recoverVendor(vendorDir)
+
+	if _, err := os.Stat(filepath.Join(vendorDir, "a.proto")); err != nil {
+		t.Errorf("vendor directory not restored: %v", err)
+	}
+	leftovers, _ := filepath.Glob(filepath.Join(tmpDir, ".proto.*"))
+	if len(leftovers) != 0 {
+		t.Errorf("leftovers not removed: %v", leftovers)
+	}
+}
+
+func TestVendorMapping(t *testing.T) {
+	dep := &depend{
+		Name:        "googleapis",
+		Include:     []string{"**/*.proto", "**/*.yaml", "LICENSE"},
+		Exclude:     []string{"**/internal/**"},
+		StripPrefix: "src/",
+		Rename:      map[string]string{"google": "gapi", "google/api/http.proto": "http/http.proto", "LICENSE": "LICENSE.googleapis"},
+	}
+	m, err := newVendorMapping(dep)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]string{
+		"src/google/type/date.proto":    "gapi/type/date.proto",
+		"src/google/api/http.proto":     "http/http.proto",
+		"src/google/api/service.yaml":   "gapi/api/service.yaml",
+		"src/acme/a.proto":              "acme/a.proto",
+		"other/b.proto":                 "other/b.proto",
+		"LICENSE":                       "LICENSE.googleapis",
+		"src/google/internal/x.proto":   "",
+		"src/google/api/BUILD.bazel":    "",
+		"src/googleapis/extra.proto":    "googleapis/extra.proto",
+		"src/google/api/nested/LICENSE": "",
+	}
+	for rel, want := range tests {
+		got, ok := m.target(rel)
+		if want == "" {
+			if ok {
+				t.Errorf("target(%q) = %q, want not vendored", rel, got)
+			}
+			continue
+		}
+		if !ok || got != want {
+			t.Errorf("target(%q) = %q, %v, want %q", rel, got, ok, want)
+		}
+	}
+
+	if _, err := newVendorMapping(&depend{Name: "bad", Include: []string{"[a-"}}); err == nil {
+		t.Error("expected error for invalid glob")
+	}
+}
+
+func TestCopyToVendor_Mapping(t *testing.T) {
+	tmpDir := t.TempDir()
+	vendorDir := filepath.Join(tmpDir, ".proto")
+	depDir := filepath.Join(tmpDir, "dep")
+	writeTestFiles(t, map[string]string{
+		filepath.Join(depDir, "proto", "acme", "a.proto"):     "a",
+		filepath.Join(depDir, "proto", "acme", "a.yaml"):      "config",
+		filepath.Join(depDir, "proto", "internal", "x.proto"): "x",
+		filepath.Join(depDir, "LICENSE"):                      "license",
+	})
+
+	svc := &VendorService{config: &Config{
+		Vendor: vendorDir,
+		Depends: []*depend{{
+			Name:        "acme",
+			Include:     []string{"**/*.proto", "**/*.yaml", "LICENSE"},
+			Exclude:     []string{"**/internal/**"},
+			StripPrefix: "proto",
+		}},
+	}}
+	copied, err := svc.CopyToVendor(map[string]string{"acme": depDir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if copied != 3 {
+		t.Errorf("copied %d files, want 3", copied)
+	}
+	for _, path := range []string{"acme/acme/a.proto", "acme/acme/a.yaml", "acme/LICENSE"} {
+		if _, err := os.Stat(filepath.Join(vendorDir, filepath.FromSlash(path))); err != nil {
+			t.Errorf("%s not vendored: %v", path, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(vendorDir, "acme", "internal")); !os.IsNotExist(err) {
+		t.Error("excluded files vendored")
+	}
+
+	// Mapping two files to the same path is an error.
+	svc.config.Depends[0].Rename = map[string]string{"acme/a.yaml": "acme/a.proto"}
+	if _, err := svc.CopyToVendor(map[string]string{"acme": depDir}); err == nil {
+		t.Error("expected error for conflicting targets")
+	}
+}
@@ -17,6 +17,14 @@ type Config struct {
 	Plugins    []*Plugin `yaml:"plugins,omitempty" json:"plugins" hash:"-"`
 	Installers []string  `yaml:"installers,omitempty" json:"installers" hash:"-"`
 	Linter     *Linter   `yaml:"linter,omitempty" json:"linter,omitempty" hash:"-"`
+	Format     *Format   `yaml:"format,omitempty" json:"format,omitempty" hash:"-"`
+	Cache      *Cache    `yaml:"cache,omitempty" json:"cache,omitempty" hash:"-"`
+	Mirrors    []*Mirror `yaml:"mirrors,omitempty" json:"mirrors,omitempty" hash:"-"`
+	Proxy      *Proxy    `yaml:"proxy,omitempty" json:"proxy,omitempty" hash:"-"`
+
+	// ImportShadowing how an import path found in more than one include
+	// path is reported: warn (default), error or ignore
+	ImportShadowing string `yaml:"import_shadowing,omitempty" json:"import_shadowing,omitempty" hash:"-"`
 
 	// Changed is used internally to track if config has been modified (lowercase for internal use)
 	Changed bool `yaml:"-" json:"-"`
@@ -46,6 +54,10 @@ type Plugin struct {
 	// Docker run via Docker container
 	Docker string `yaml:"docker,omitempty" json:"docker,omitempty"`
 
+	// Template directory of pongo2 templates, rendered in-process instead of
+	// running a protoc plugin
+	Template string `yaml:"template,omitempty" json:"template,omitempty"`
+
 	// Remote remote plugin URL
 	Remote string `yaml:"remote,omitempty" json:"remote,omitempty"`
 
@@ -84,6 +96,22 @@ type Depend struct {
 
 	// Optional skip if not found
 	Optional *bool `yaml:"optional,omitempty" json:"optional,omitempty"`
+
+	// Checksum h1: hash of the resolved files as printed by deps, or a
+	// type:value checksum of the downloaded archive, e.g. sha256:<hex>
+	Checksum string `yaml:"checksum,omitempty" json:"checksum,omitempty"`
+
+	// Include globs of the files to vendor, relative to path; default **/*.proto
+	Include []string `yaml:"include,omitempty" json:"include,omitempty"`
+
+	// Exclude globs of the files not to vendor, e.g. **/internal/**
+	Exclude []string `yaml:"exclude,omitempty" json:"exclude,omitempty"`
+
+	// StripPrefix directory removed from the vendored file paths
+	StripPrefix string `yaml:"strip_prefix,omitempty" json:"strip_prefix,omitempty"`
+
+	// Rename maps path prefixes to new prefixes in the vendor directory
+	Rename map[string]string `yaml:"rename,omitempty" json:"rename,omitempty"`
 }
 
 // Linter represents linter configuration.
@@ -91,12 +119,73 @@ type Linter struct {
 	Rules                     *LinterRules `yaml:"rules,omitempty" json:"rules,omitempty" hash:"-"`
 	FormatType                string       `yaml:"format_type,omitempty" json:"format_type,omitempty"`
 	IgnoreCommentDisablesFlag bool         `yaml:"ignore_comment_disables_flag,omitempty" json:"ignore_comment_disables_flag,omitempty"`
+
+	// Overrides directory-level rules from protobuf.plugin.yaml, layered on top
+	// of Rules in order. Populated when merging configs, never serialized.
+	Overrides []*LinterRules `yaml:"-" json:"-" hash:"-"`
+}
+
+// Format represents builtin formatter configuration.
+type Format struct {
+	// IndentWidth spaces per indentation level, default 2
+	IndentWidth int `yaml:"indent_width,omitempty" json:"indent_width,omitempty"`
+	// MaxLineLength wraps long compact field options, 0 disables wrapping
+	MaxLineLength int `yaml:"max_line_length,omitempty" json:"max_line_length,omitempty"`
+	// CompactOptions maximum number of compact options kept on one line, default 1
+	CompactOptions int `yaml:"compact_options,omitempty" json:"compact_options,omitempty"`
+	// Imports import sorting and grouping
+	Imports *FormatImports `yaml:"imports,omitempty" json:"imports,omitempty"`
+}
+
+// FormatImports represents import formatting configuration.
+type FormatImports struct {
+	// Sort imports by path, default true
+	Sort *bool `yaml:"sort,omitempty" json:"sort,omitempty"`
+	// Group imports into google/protobuf, vendored third-party and local groups
+	Group bool `yaml:"group,omitempty" json:"group,omitempty"`
+	// RemoveUnused removes imports that are not used by the file
+	RemoveUnused bool `yaml:"remove_unused,omitempty" json:"remove_unused,omitempty"`
+}
+
+// Cache represents dependency cache limits, enforced after vendor.
+type Cache struct {
+	// MaxAge removes entries not used within the age, e.g. 30d
+	MaxAge string `yaml:"max_age,omitempty" json:"max_age,omitempty"`
+	// MaxSize evicts least recently used entries above the size, e.g. 2GB
+	MaxSize string `yaml:"max_size,omitempty" json:"max_size,omitempty"`
+	// TTL is how long git branches and HTTP archives are used from the
+	// cache before they are checked for changes, e.g. 12h; 0 disables it
+	TTL string `yaml:"ttl,omitempty" json:"ttl,omitempty"`
+}
+
+// Mirror rewrites dependency URLs with the prefix From to the prefix To.
+type Mirror struct {
+	// From URL prefix to rewrite, e.g. https://github.com/
+	From string `yaml:"from" json:"from"`
+	// To replacement prefix, e.g. https://git.example.com/github/
+	To string `yaml:"to" json:"to"`
+}
+
+// Proxy represents network proxy configuration. Each setting is exported
+// to the environment unless the variable is already set.
+type Proxy struct {
+	// HTTP proxy of http URLs (HTTP_PROXY)
+	HTTP string `yaml:"http,omitempty" json:"http,omitempty"`
+	// HTTPS proxy of https URLs (HTTPS_PROXY)
+	HTTPS string `yaml:"https,omitempty" json:"https,omitempty"`
+	// NoProxy hosts accessed directly (NO_PROXY)
+	NoProxy string `yaml:"no_proxy,omitempty" json:"no_proxy,omitempty"`
+	// GoProxy module proxy of gomod dependencies (GOPROXY)
+	GoProxy string `yaml:"goproxy,omitempty" json:"goproxy,omitempty"`
 }
 
 // LinterRules represents linter rules configuration.
 type LinterRules struct {
 	EnabledRules  []string `yaml:"enabled_rules,omitempty" json:"enabled_rules,omitempty"`
 	DisabledRules []string `yaml:"disabled_rules,omitempty" json:"disabled_rules,omitempty"`
+
+	// Severity maps rule names or prefixes to error, warning or info.
+	Severity map[string]string `yaml:"severity,omitempty" json:"severity,omitempty"`
 }
 
 // GetVersion returns the version string or empty if nil.
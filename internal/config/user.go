@@ -0,0 +1,54 @@
+package config
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+)
+
+// UserConfig represents the per-user configuration shared by all projects,
+// for settings of the machine rather than the project, e.g. mirrors of an
+// enterprise network.
+type UserConfig struct {
+	Mirrors []*Mirror `yaml:"mirrors,omitempty" json:"mirrors,omitempty"`
+	Proxy   *Proxy    `yaml:"proxy,omitempty" json:"proxy,omitempty"`
+}
+
+// UserConfigPath returns the path of the user configuration file,
+// $PROTOBUILD_USER_CONFIG or protobuild/config.yaml in the user config
+// directory, e.g. ~/.config/protobuild/config.yaml.
+func UserConfigPath() string {
+	if path := os.Getenv("PROTOBUILD_USER_CONFIG"); path != "" {
+		return path
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "protobuild", "config.yaml")
+}
+
+// LoadUserConfig loads the user configuration. A missing file is an empty
+// configuration.
+func LoadUserConfig() (*UserConfig, error) {
+	cfg := &UserConfig{}
+	path := UserConfigPath()
+	if path == "" {
+		return cfg, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return cfg, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := yaml.Unmarshal(data, cfg); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
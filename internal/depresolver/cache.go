@@ -0,0 +1,335 @@
+package depresolver
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cacheMetaSuffix is the suffix of the metadata file kept next to each cache
+// entry directory, so the downloaded content is left untouched.
+const cacheMetaSuffix = ".meta.json"
+
+// CacheEntry describes a downloaded dependency in the cache.
+type CacheEntry struct {
+	Source   Source    `json:"source"`
+	URL      string    `json:"url"`
+	Version  string    `json:"version,omitempty"`
+	Created  time.Time `json:"created"`
+	LastUsed time.Time `json:"last_used"`
+	Size     int64     `json:"size"`
+	// Checked is when the upstream was last checked for changes.
+	Checked time.Time `json:"checked,omitempty"`
+	// ETag and LastModified are the HTTP validators of HTTP sources.
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"last_modified,omitempty"`
+	// Hash is the content hash of a download verified by an archive
+	// checksum, checked again on cache hits.
+	Hash string `json:"hash,omitempty"`
+	// Projects are the config files of the projects that used the entry.
+	Projects []string `json:"projects,omitempty"`
+
+	// Dir is the entry directory; Key is its cache key.
+	Dir string `json:"-"`
+	Key string `json:"-"`
+}
+
+// GCPolicy selects cache entries to remove. Zero fields are not applied.
+type GCPolicy struct {
+	// MaxAge removes entries not used within the duration.
+	MaxAge time.Duration
+	// MaxSize removes the least recently used entries until the cache is
+	// at most this many bytes.
+	MaxSize int64
+	// Unused removes the entries it reports true for.
+	Unused func(e *CacheEntry) bool
+	// DryRun reports the entries without removing them.
+	DryRun bool
+}
+
+// WithProject records the project config path in the metadata of the cache
+// entries resolved by m, for `clean --unused`.
+func (m *Manager) WithProject(configPath string) *Manager {
+	if abs, err := filepath.Abs(configPath); err == nil {
+		configPath = abs
+	}
+	m.project = configPath
+	return m
+}
+
+// CacheKey returns the cache key of a dependency, or "" if the dependency has
+// no cache entry of its own: local sources, and gomod sources, which are
+// cached per module version.
+func (m *Manager) CacheKey(dep *Dependency) string {
+	source := m.detectSource(dep)
+	if source == SourceLocal || source == SourceGoMod {
+		return ""
+	}
+	return m.cacheKeyForDependency(dep, source)
+}
+
+// CacheEntries returns the entries of the dependency cache. Entries without
+// metadata, e.g. from older versions, get it from the file system.
+func (m *Manager) CacheEntries() ([]*CacheEntry, error) {
+	sources, err := os.ReadDir(m.cacheDir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []*CacheEntry
+	for _, source := range sources {
+		if !source.IsDir() {
+			continue
+		}
+		sourceDir := filepath.Join(m.cacheDir, source.Name())
+		dirs, err := os.ReadDir(sourceDir)
+		if err != nil {
+			return nil, err
+		}
+		for _, d := range dirs {
+			if !d.IsDir() {
+				continue
+			}
+			entry, err := m.readCacheEntry(filepath.Join(sourceDir, d.Name()))
+			if err != nil {
+				return nil, err
+			}
+			if entry.Source == "" {
+				entry.Source = Source(source.Name())
+			}
+			entries = append(entries, entry)
+		}
+	}
+	return entries, nil
+}
+
+// GC removes the cache entries selected by policy and returns them, least
+// recently used first.
+func (m *Manager) GC(policy GCPolicy) ([]*CacheEntry, error) {
+	entries, err := m.CacheEntries()
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].LastUsed.Before(entries[j].LastUsed) })
+
+	var removed, kept []*CacheEntry
+	var keptSize int64
+	now := time.Now()
+	for _, e := range entries {
+		switch {
+		case policy.MaxAge > 0 && now.Sub(e.LastUsed) > policy.MaxAge,
+			policy.Unused != nil && policy.Unused(e):
+			removed = append(removed, e)
+		default:
+			kept = append(kept, e)
+			keptSize += e.Size
+		}
+	}
+
+	// Evict least recently used entries over the size limit.
+	for policy.MaxSize > 0 && keptSize > policy.MaxSize && len(kept) > 0 {
+		removed = append(removed, kept[0])
+		keptSize -= kept[0].Size
+		kept = kept[1:]
+	}
+
+	if policy.DryRun {
+		return removed, nil
+	}
+	for _, e := range removed {
+		if err := m.RemoveCacheEntry(e); err != nil {
+			return nil, err
+		}
+	}
+	return removed, nil
+}
+
+// Evict removes the cache entry of dep, if any, so that it is downloaded
+// again on the next Resolve.
+func (m *Manager) Evict(dep *Dependency) error {
+	source := m.detectSource(dep)
+	if source == SourceLocal || source == SourceGoMod {
+		return nil
+	}
+	return m.RemoveCacheEntry(&CacheEntry{Dir: m.cachePathForDependency(dep, source)})
+}
+
+// RemoveCacheEntry removes a cache entry and its metadata.
+func (m *Manager) RemoveCacheEntry(e *CacheEntry) error {
+	if err := os.RemoveAll(e.Dir); err != nil {
+		return err
+	}
+	if err := os.Remove(e.Dir + cacheMetaSuffix); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// touchCacheEntry updates the metadata of the cache entry at dir after it
+// was used, or downloaded with the given HTTP validators.
+func (m *Manager) touchCacheEntry(dep *Dependency, source Source, dir string, downloaded bool, validators httpValidators) error {
+	if m.readOnly {
+		return nil
+	}
+
+	entry, err := m.readCacheEntry(dir)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	entry.Source = source
+	entry.URL = strings.TrimSpace(dep.URL)
+	entry.Version = dependencyVersion(dep)
+	entry.LastUsed = now
+	if downloaded {
+		entry.Created = now
+		entry.Checked = now
+		entry.Size = dirSize(dir)
+		entry.ETag = validators.ETag
+		entry.LastModified = validators.LastModified
+		entry.Hash = ""
+		if archiveChecksum(dep) != "" {
+			entry.Hash, _ = HashDir(dir)
+		}
+	}
+	if m.project != "" && !slices.Contains(entry.Projects, m.project) {
+		entry.Projects = append(entry.Projects, m.project)
+		sort.Strings(entry.Projects)
+	}
+	return writeCacheEntry(entry)
+}
+
+func writeCacheEntry(entry *CacheEntry) error {
+	data, err := json.MarshalIndent(entry, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(entry.Dir+cacheMetaSuffix, data, 0o644)
+}
+
+// verifyCacheEntry checks that the entry at dir still has the content hash
+// recorded on download.
+func (m *Manager) verifyCacheEntry(dir string) error {
+	entry, err := m.readCacheEntry(dir)
+	if err != nil {
+		return err
+	}
+	if entry.Hash == "" {
+		return fmt.Errorf("%w: cached content was never verified", ErrChecksumMismatch)
+	}
+	got, err := HashDir(dir)
+	if err != nil {
+		return err
+	}
+	if got != entry.Hash {
+		return fmt.Errorf("%w: cached content changed since its verified download", ErrChecksumMismatch)
+	}
+	return nil
+}
+
+// readCacheEntry reads the metadata of the entry at dir, falling back to the
+// file system for missing metadata.
+func (m *Manager) readCacheEntry(dir string) (*CacheEntry, error) {
+	entry := &CacheEntry{Dir: dir, Key: filepath.Base(dir)}
+
+	data, err := os.ReadFile(dir + cacheMetaSuffix)
+	switch {
+	case err == nil:
+		if err := json.Unmarshal(data, entry); err != nil {
+			return nil, fmt.Errorf("invalid cache metadata %s: %w", dir+cacheMetaSuffix, err)
+		}
+	case !os.IsNotExist(err):
+		return nil, err
+	}
+
+	if entry.LastUsed.IsZero() || entry.Size == 0 {
+		if info, err := os.Stat(dir); err == nil {
+			if entry.LastUsed.IsZero() {
+				entry.LastUsed = info.ModTime()
+			}
+			if entry.Created.IsZero() {
+				entry.Created = info.ModTime()
+			}
+			if entry.Size == 0 {
+				entry.Size = dirSize(dir)
+			}
+		}
+	}
+	return entry, nil
+}
+
+func dirSize(dir string) int64 {
+	var size int64
+	_ = filepath.WalkDir(dir, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return nil
+		}
+		if info, err := d.Info(); err == nil {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size
+}
+
+// ParseByteSize parses a size such as 512MB, 2G or 1.5GiB. Units are
+// powers of 1024; a bare number is bytes.
+func ParseByteSize(s string) (int64, error) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	units := []struct {
+		suffix string
+		scale  float64
+	}{
+		{"TIB", 1 << 40}, {"GIB", 1 << 30}, {"MIB", 1 << 20}, {"KIB", 1 << 10},
+		{"TB", 1 << 40}, {"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10},
+		{"T", 1 << 40}, {"G", 1 << 30}, {"M", 1 << 20}, {"K", 1 << 10},
+		{"B", 1},
+	}
+
+	scale := 1.0
+	for _, u := range units {
+		if strings.HasSuffix(s, u.suffix) {
+			s = strings.TrimSpace(strings.TrimSuffix(s, u.suffix))
+			scale = u.scale
+			break
+		}
+	}
+
+	n, err := strconv.ParseFloat(s, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	return int64(n * scale), nil
+}
+
+// ParseAge parses a duration such as 30d, 2w or 12h. In addition to
+// time.ParseDuration units it accepts d (days) and w (weeks).
+func ParseAge(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	for suffix, unit := range map[string]time.Duration{"d": 24 * time.Hour, "w": 7 * 24 * time.Hour} {
+		if n, ok := strings.CutSuffix(s, suffix); ok {
+			v, err := strconv.ParseFloat(n, 64)
+			if err != nil || v < 0 {
+				return 0, fmt.Errorf("invalid duration %q", s)
+			}
+			return time.Duration(v * float64(unit)), nil
+		}
+	}
+
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid duration %q", s)
+	}
+	return d, nil
+}
@@ -0,0 +1,268 @@
+package depresolver
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+// addCacheEntry creates a cache entry of size bytes for dep, last used at
+// lastUsed.
+func addCacheEntry(t *testing.T, m *Manager, dep *Dependency, size int, lastUsed time.Time) *CacheEntry {
+	t.Helper()
+
+	dir := m.cachePathForDependency(dep, dep.Source)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "a.proto"), make([]byte, size), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.touchCacheEntry(dep, dep.Source, dir, true, httpValidators{}); err != nil {
+		t.Fatal(err)
+	}
+
+	entry, err := m.readCacheEntry(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry.LastUsed = lastUsed
+	if err := writeCacheEntry(entry); err != nil {
+		t.Fatal(err)
+	}
+	return entry
+}
+
+func TestTouchCacheEntry(t *testing.T) {
+	m := NewManager(t.TempDir(), "").WithProject("protobuf.yaml")
+	dep := &Dependency{Source: SourceGit, URL: "https://github.com/acme/protos", Version: strPtr("v1.0.0")}
+	addCacheEntry(t, m, dep, 10, time.Now())
+
+	entries, err := m.CacheEntries()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("CacheEntries() = %d entries, want 1", len(entries))
+	}
+
+	e := entries[0]
+	wantProject, _ := filepath.Abs("protobuf.yaml")
+	if e.Source != SourceGit || e.URL != dep.URL || e.Version != "v1.0.0" || e.Size != 10 {
+		t.Errorf("entry = %+v", e)
+	}
+	if e.Key != m.CacheKey(dep) {
+		t.Errorf("Key = %q, want %q", e.Key, m.CacheKey(dep))
+	}
+	if len(e.Projects) != 1 || e.Projects[0] != wantProject {
+		t.Errorf("Projects = %v, want [%s]", e.Projects, wantProject)
+	}
+}
+
+func TestLookup_KeepsMetadata(t *testing.T) {
+	m := NewManager(t.TempDir(), "")
+	dep := &Dependency{Source: SourceGit, URL: "https://github.com/acme/protos", Version: strPtr("v1.0.0")}
+	lastUsed := time.Now().Add(-48 * time.Hour).Truncate(time.Second)
+	addCacheEntry(t, m, dep, 10, lastUsed)
+
+	res, err := m.WithProject("protobuf.yaml").Lookup(context.Background(), dep)
+	if err != nil || res.LocalPath == "" {
+		t.Fatalf("Lookup() = %+v, %v", res, err)
+	}
+
+	entry, err := m.readCacheEntry(m.cachePathForDependency(dep, dep.Source))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !entry.LastUsed.Equal(lastUsed) || len(entry.Projects) != 0 {
+		t.Errorf("entry = %+v, want the metadata unchanged", entry)
+	}
+
+	if _, err := m.Lookup(context.Background(), &Dependency{Source: SourceGit, URL: "https://github.com/acme/missing"}); !errors.Is(err, ErrOffline) {
+		t.Errorf("Lookup() of an uncached dependency = %v, want ErrOffline", err)
+	}
+}
+
+func TestCacheEntries_WithoutMetadata(t *testing.T) {
+	m := NewManager(t.TempDir(), "")
+	dir := filepath.Join(m.CacheDir(), "http", "legacy")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "a.proto"), []byte("syntax"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := m.CacheEntries()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Source != SourceHTTP || entries[0].Size != 6 || entries[0].LastUsed.IsZero() {
+		t.Errorf("CacheEntries() = %+v", entries)
+	}
+}
+
+func TestCacheKey_NotCached(t *testing.T) {
+	m := NewManager(t.TempDir(), "")
+	if key := m.CacheKey(&Dependency{Source: SourceGoMod, URL: "github.com/acme/protos"}); key != "" {
+		t.Errorf("CacheKey(gomod) = %q, want empty", key)
+	}
+	if key := m.CacheKey(&Dependency{Source: SourceLocal, URL: "./protos"}); key != "" {
+		t.Errorf("CacheKey(local) = %q, want empty", key)
+	}
+}
+
+func TestGC(t *testing.T) {
+	now := time.Now()
+	newManager := func(t *testing.T) (*Manager, []*CacheEntry) {
+		m := NewManager(t.TempDir(), "")
+		return m, []*CacheEntry{
+			addCacheEntry(t, m, &Dependency{Source: SourceGit, URL: "https://example.com/old"}, 100, now.Add(-60*24*time.Hour)),
+			addCacheEntry(t, m, &Dependency{Source: SourceGit, URL: "https://example.com/mid"}, 100, now.Add(-10*24*time.Hour)),
+			addCacheEntry(t, m, &Dependency{Source: SourceGit, URL: "https://example.com/new"}, 100, now),
+		}
+	}
+	urls := func(entries []*CacheEntry) []string {
+		var out []string
+		for _, e := range entries {
+			out = append(out, e.URL)
+		}
+		return out
+	}
+
+	tests := []struct {
+		name   string
+		policy func(entries []*CacheEntry) GCPolicy
+		want   []string
+	}{
+		{
+			name:   "max age",
+			policy: func([]*CacheEntry) GCPolicy { return GCPolicy{MaxAge: 30 * 24 * time.Hour} },
+			want:   []string{"https://example.com/old"},
+		},
+		{
+			name:   "max size evicts least recently used",
+			policy: func([]*CacheEntry) GCPolicy { return GCPolicy{MaxSize: 150} },
+			want:   []string{"https://example.com/old", "https://example.com/mid"},
+		},
+		{
+			name: "unused",
+			policy: func(entries []*CacheEntry) GCPolicy {
+				return GCPolicy{Unused: func(e *CacheEntry) bool { return e.Key == entries[1].Key }}
+			},
+			want: []string{"https://example.com/mid"},
+		},
+		{
+			name:   "no limits",
+			policy: func([]*CacheEntry) GCPolicy { return GCPolicy{} },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, entries := newManager(t)
+			removed, err := m.GC(tt.policy(entries))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := urls(removed); !slices.Equal(got, tt.want) {
+				t.Fatalf("GC() removed %v, want %v", got, tt.want)
+			}
+
+			left, err := m.CacheEntries()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(left) != len(entries)-len(tt.want) {
+				t.Errorf("%d entries left, want %d", len(left), len(entries)-len(tt.want))
+			}
+			for _, e := range removed {
+				if _, err := os.Stat(e.Dir + cacheMetaSuffix); !os.IsNotExist(err) {
+					t.Errorf("metadata of %s not removed", e.URL)
+				}
+			}
+		})
+	}
+}
+
+func TestGC_DryRun(t *testing.T) {
+	m := NewManager(t.TempDir(), "")
+	addCacheEntry(t, m, &Dependency{Source: SourceGit, URL: "https://example.com/old"}, 10, time.Now().Add(-time.Hour))
+
+	removed, err := m.GC(GCPolicy{MaxAge: time.Minute, DryRun: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(removed) != 1 {
+		t.Fatalf("GC() removed %d entries, want 1", len(removed))
+	}
+	if _, err := os.Stat(removed[0].Dir); err != nil {
+		t.Errorf("dry run removed the entry: %v", err)
+	}
+}
+
+func TestParseByteSize(t *testing.T) {
+	tests := map[string]int64{
+		"512":    512,
+		"10B":    10,
+		"2k":     2 << 10,
+		"512MB":  512 << 20,
+		"1.5GiB": 3 << 29,
+		"1 T":    1 << 40,
+	}
+	for in, want := range tests {
+		if got, err := ParseByteSize(in); err != nil || got != want {
+			t.Errorf("ParseByteSize(%q) = %d, %v, want %d", in, got, err, want)
+		}
+	}
+
+	for _, in := range []string{"", "abc", "-1G", "1X"} {
+		if _, err := ParseByteSize(in); err == nil {
+			t.Errorf("ParseByteSize(%q) should fail", in)
+		}
+	}
+}
+
+func TestParseAge(t *testing.T) {
+	tests := map[string]time.Duration{
+		"30d":   30 * 24 * time.Hour,
+		"2w":    14 * 24 * time.Hour,
+		"12h":   12 * time.Hour,
+		"1h30m": 90 * time.Minute,
+	}
+	for in, want := range tests {
+		if got, err := ParseAge(in); err != nil || got != want {
+			t.Errorf("ParseAge(%q) = %v, %v, want %v", in, got, err, want)
+		}
+	}
+
+	for _, in := range []string{"", "d", "-1d", "1y"} {
+		if _, err := ParseAge(in); err == nil {
+			t.Errorf("ParseAge(%q) should fail", in)
+		}
+	}
+}
+
+func TestEvict(t *testing.T) {
+	m := NewManager(t.TempDir(), "")
+	keep := addCacheEntry(t, m, &Dependency{Source: SourceGit, URL: "https://example.com/keep"}, 10, time.Now())
+	dep := &Dependency{Source: SourceGit, URL: "https://example.com/evict"}
+	evict := addCacheEntry(t, m, dep, 10, time.Now())
+
+	if err := m.Evict(dep); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(evict.Dir); !os.IsNotExist(err) {
+		t.Error("evicted entry still exists")
+	}
+	if _, err := os.Stat(keep.Dir); err != nil {
+		t.Errorf("other entry removed: %v", err)
+	}
+	if err := m.Evict(&Dependency{Source: SourceLocal, URL: "./protos"}); err != nil {
+		t.Errorf("Evict(local) = %v", err)
+	}
+}
@@ -0,0 +1,110 @@
+package depresolver
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/mod/sumdb/dirhash"
+)
+
+// ErrChecksumMismatch reports that the content of a dependency does not
+// match its checksum.
+var ErrChecksumMismatch = errors.New("checksum mismatch")
+
+// HashDir returns the content hash of the files under dir in the h1: format
+// of go.sum. Git metadata is ignored, so a git dependency hashes the same
+// as an archive of its files.
+func HashDir(dir string) (string, error) {
+	var files []string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if d.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		files = append(files, filepath.ToSlash(rel))
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return dirhash.Hash1(files, func(name string) (io.ReadCloser, error) {
+		return os.Open(filepath.Join(dir, filepath.FromSlash(name)))
+	})
+}
+
+// isContentChecksum reports whether checksum is an h1: content hash rather
+// than an archive checksum.
+func isContentChecksum(checksum string) bool {
+	return strings.HasPrefix(strings.TrimSpace(checksum), "h1:")
+}
+
+// archiveChecksum returns the go-getter checksum of the downloaded file of
+// dep, type:value, or "". A bare hex value is a sha256 checksum.
+func archiveChecksum(dep *Dependency) string {
+	checksum := strings.TrimSpace(dep.Checksum)
+	if checksum == "" || isContentChecksum(checksum) {
+		return ""
+	}
+	if !strings.Contains(checksum, ":") {
+		return "sha256:" + checksum
+	}
+	return checksum
+}
+
+// validateChecksum checks the checksum setting of dep. Archive checksums
+// need a downloaded file, so they are limited to HTTP and object storage.
+func validateChecksum(dep *Dependency, source Source) error {
+	checksum := archiveChecksum(dep)
+	if checksum == "" {
+		return nil
+	}
+
+	if source != SourceHTTP && source != SourceS3 && source != SourceGCS {
+		return fmt.Errorf("archive checksum %q needs an http, s3 or gcs source, use an h1: content hash instead", checksum)
+	}
+
+	kind, value, _ := strings.Cut(checksum, ":")
+	switch kind {
+	case "md5", "sha1", "sha256", "sha512":
+	default:
+		return fmt.Errorf("unsupported checksum type %q", kind)
+	}
+	if _, err := hex.DecodeString(value); err != nil || value == "" {
+		return fmt.Errorf("invalid %s checksum %q", kind, value)
+	}
+	return nil
+}
+
+// verifyContent checks the files under dir against the h1: checksum of dep,
+// if it has one.
+func verifyContent(dep *Dependency, dir string) error {
+	want := strings.TrimSpace(dep.Checksum)
+	if !isContentChecksum(want) {
+		return nil
+	}
+
+	got, err := HashDir(dir)
+	if err != nil {
+		return err
+	}
+	if got != want {
+		return fmt.Errorf("%w: want %s, got %s", ErrChecksumMismatch, want, got)
+	}
+	return nil
+}
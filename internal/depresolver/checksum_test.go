@@ -0,0 +1,155 @@
+package depresolver
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestHashDir(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "a.proto"), []byte("a"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	want, err := HashDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(want, "h1:") {
+		t.Errorf("HashDir() = %q, want h1: hash", want)
+	}
+
+	if err := os.MkdirAll(filepath.Join(dir, ".git"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, ".git", "HEAD"), []byte("ref"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := HashDir(dir); got != want {
+		t.Errorf("HashDir() with .git = %q, want %q", got, want)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "a.proto"), []byte("b"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := HashDir(dir); got == want {
+		t.Error("HashDir() should change with the content")
+	}
+}
+
+func TestValidateChecksum(t *testing.T) {
+	sum := fmt.Sprintf("%x", sha256.Sum256([]byte("x")))
+	tests := []struct {
+		source   Source
+		checksum string
+		wantErr  bool
+	}{
+		{SourceHTTP, "", false},
+		{SourceGit, "h1:abc=", false},
+		{SourceHTTP, sum, false},
+		{SourceS3, "sha256:" + sum, false},
+		{SourceGCS, "md5:d41d8cd98f00b204e9800998ecf8427e", false},
+		{SourceGit, sum, true},
+		{SourceHTTP, "crc32:abcd", true},
+		{SourceHTTP, "sha256:xyz", true},
+	}
+	for _, tt := range tests {
+		err := validateChecksum(&Dependency{Checksum: tt.checksum}, tt.source)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("validateChecksum(%s, %q) = %v, wantErr %v", tt.source, tt.checksum, err, tt.wantErr)
+		}
+	}
+}
+
+func TestBuildGetterURL_Checksum(t *testing.T) {
+	m := NewManager(t.TempDir(), "")
+	dep := &Dependency{URL: "https://example.com/a.tar.gz", Checksum: "abc123"}
+	if got := m.buildGetterURL(dep, SourceHTTP); got != "https://example.com/a.tar.gz?checksum=sha256:abc123" {
+		t.Errorf("buildGetterURL() = %q", got)
+	}
+	dep.Checksum = "h1:abc="
+	if got := m.buildGetterURL(dep, SourceHTTP); got != "https://example.com/a.tar.gz" {
+		t.Errorf("buildGetterURL() with content hash = %q", got)
+	}
+}
+
+func TestResolve_ContentChecksum(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "a.proto"), []byte("a"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	sum, err := HashDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := NewManager(t.TempDir(), "")
+	if _, err := m.Resolve(context.Background(), &Dependency{Name: "a", Source: SourceLocal, URL: dir, Checksum: sum}); err != nil {
+		t.Errorf("Resolve() with matching checksum = %v", err)
+	}
+
+	_, err = m.Resolve(context.Background(), &Dependency{Name: "a", Source: SourceLocal, URL: dir, Checksum: "h1:bad="})
+	var resolveErr *ResolveError
+	if !errors.Is(err, ErrChecksumMismatch) || !errors.As(err, &resolveErr) || resolveErr.Operation != "verify" {
+		t.Errorf("Resolve() with wrong checksum = %v, want verify mismatch", err)
+	}
+}
+
+func TestResolve_ArchiveChecksum(t *testing.T) {
+	content := []byte("syntax = \"proto3\";")
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(content)
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	m := NewManager(t.TempDir(), "")
+	sum := fmt.Sprintf("%x", sha256.Sum256(content))
+
+	_, err := m.Resolve(ctx, &Dependency{Name: "bad", Source: SourceHTTP, URL: srv.URL + "/a.proto?filename=a.proto", Checksum: fmt.Sprintf("%x", sha256.Sum256([]byte("other")))})
+	if !errors.Is(err, ErrChecksumMismatch) {
+		t.Fatalf("Resolve() with wrong checksum = %v, want mismatch", err)
+	}
+
+	dep := &Dependency{Name: "good", Source: SourceHTTP, URL: srv.URL + "/a.proto?filename=a.proto", Checksum: sum}
+	res, err := m.Resolve(ctx, dep)
+	if err != nil {
+		t.Fatalf("Resolve() = %v", err)
+	}
+
+	// A cache hit is checked against the content verified on download.
+	if _, err := m.Resolve(ctx, dep); err != nil {
+		t.Fatalf("Resolve() from cache = %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(res.LocalPath, "a.proto"), []byte("tampered"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Resolve(ctx, dep); !errors.Is(err, ErrChecksumMismatch) {
+		t.Errorf("Resolve() of tampered cache = %v, want mismatch", err)
+	}
+}
+
+func TestResolve_ArchiveChecksumFailureNotCached(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("content"))
+	}))
+	defer srv.Close()
+
+	m := NewManager(t.TempDir(), "")
+	dep := &Dependency{Name: "bad", Source: SourceHTTP, URL: srv.URL + "/a.proto?filename=a.proto", Checksum: fmt.Sprintf("%x", sha256.Sum256([]byte("other")))}
+	for i := 0; i < 2; i++ {
+		if _, err := m.Resolve(context.Background(), dep); !errors.Is(err, ErrChecksumMismatch) {
+			t.Fatalf("Resolve() #%d = %v, want mismatch", i, err)
+		}
+	}
+	if _, err := os.Stat(m.cachePathForDependency(dep, SourceHTTP)); !os.IsNotExist(err) {
+		t.Error("failed download left in the cache")
+	}
+}
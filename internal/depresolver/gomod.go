@@ -2,16 +2,19 @@ package depresolver
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/pubgo/funk/v2/pathutil"
 	"github.com/schollz/progressbar/v3"
+	"golang.org/x/mod/module"
+	"golang.org/x/mod/semver"
 
 	"github.com/pubgo/protobuild/internal/modutil"
-	"github.com/pubgo/protobuild/internal/shutil"
 )
 
 // resolveGoMod resolves dependencies using Go modules
@@ -37,17 +40,53 @@ func (m *Manager) resolveGoMod(ctx context.Context, dep *Dependency) (*ResolveRe
 		}, nil
 	}
 
-	// Load versions from go.mod graph
-	versions := modutil.LoadVersionGraph()
+	// Versions, replacements and workspace modules come from go.mod and
+	// go.work, read without running the go command.
+	ws, err := modutil.LoadWorkspace(".")
+	if err != nil {
+		return nil, &ResolveError{
+			Dependency: dep,
+			Source:     SourceGoMod,
+			URL:        url,
+			Operation:  "resolve",
+			Err:        err,
+		}
+	}
 
-	// Resolve version
-	version := m.resolveGoModVersion(dep, url, versions)
+	modPath, version := url, ""
+	if mod, ok := ws.Lookup(url); ok {
+		// Workspace modules and replacements by local directories are
+		// used in place.
+		if mod.Dir != "" {
+			return m.resolveGoModDir(dep, url, mod.Dir)
+		}
+		modPath, version = mod.Path, mod.Version
+	} else {
+		version = m.resolveGoModVersion(dep, url)
+	}
 
 	// Check if we need to download
 	changed := false
-	modCachePath := filepath.Join(m.gomodPath, fmt.Sprintf("%s@%s", url, version))
+	modCachePath := m.goModuleDir(modPath, version)
+
+	if m.offline && modCachePath == "" {
+		if dep.Optional != nil && *dep.Optional {
+			return &ResolveResult{LocalPath: "", Changed: false}, nil
+		}
+		module := modPath
+		if version != "" {
+			module += "@" + version
+		}
+		return nil, &ResolveError{
+			Dependency: dep,
+			Source:     SourceGoMod,
+			URL:        module,
+			Operation:  "resolve",
+			Err:        fmt.Errorf("%w: not in the module cache (%s)", ErrOffline, m.gomodPath),
+		}
+	}
 
-	if version == "" || pathutil.IsNotExist(modCachePath) {
+	if modCachePath == "" {
 		changed = true
 
 		displayName := strings.TrimSpace(dep.Name)
@@ -56,12 +95,11 @@ func (m *Manager) resolveGoMod(ctx context.Context, dep *Dependency) (*ResolveRe
 		}
 
 		fmt.Printf("  📥 [Go Module] %s\n", displayName)
-		fmt.Printf("     URL: %s\n", url)
+		fmt.Printf("     URL: %s\n", modPath)
 		if version == "" {
 			fmt.Println("     Version: auto")
 		} else {
 			fmt.Printf("     Version: %s\n", version)
-			fmt.Printf("     Cache: %s\n", modCachePath)
 		}
 		if dep.Path != "" {
 			fmt.Printf("     Path: %s\n", dep.Path)
@@ -79,54 +117,47 @@ func (m *Manager) resolveGoMod(ctx context.Context, dep *Dependency) (*ResolveRe
 		// Start spinner in background
 		done := make(chan struct{})
 		go func() {
+			ticker := time.NewTicker(180 * time.Millisecond)
+			defer ticker.Stop()
 			for {
 				select {
 				case <-done:
 					return
 				case <-ctx.Done():
 					return
-				default:
+				case <-ticker.C:
 					_ = bar.Add(1)
 				}
 			}
 		}()
 
-		var err error
-		if version == "" {
-			err = shutil.Shell("go", "get", "-d", url+"/...").Run()
-		} else {
-			err = shutil.Shell("go", "get", "-d", fmt.Sprintf("%s@%s", url, version)).Run()
+		version, err = m.queryGoModule(ctx, modPath, version)
+		if err == nil {
+			if modCachePath = m.goModuleDir(modPath, version); modCachePath == "" {
+				modCachePath, err = m.downloadGoModule(ctx, modPath, version, ws)
+			}
 		}
 
 		close(done)
 		_ = bar.Finish()
 
 		if err != nil {
-			if dep.Optional != nil && *dep.Optional {
+			if dep.Optional != nil && *dep.Optional && !errors.Is(err, ErrChecksumMismatch) {
 				return &ResolveResult{LocalPath: "", Changed: false}, nil
 			}
 			return nil, &ResolveError{
 				Dependency: dep,
 				Source:     SourceGoMod,
-				URL:        url,
+				URL:        modPath,
 				Operation:  "download",
 				Err:        err,
 			}
 		}
+	}
 
-		// Reload versions after download
-		versions = modutil.LoadVersions()
-		version = versions[url]
-		if version == "" {
-			return nil, &ResolveError{
-				Dependency: dep,
-				Source:     SourceGoMod,
-				URL:        url,
-				Operation:  "resolve",
-				Err:        fmt.Errorf("version not found after download, check if module path is correct"),
-			}
-		}
-		modCachePath = filepath.Join(m.gomodPath, fmt.Sprintf("%s@%s", url, version))
+	// Modules from proxies are kept in the dependency cache.
+	if modCachePath == m.goModuleCachePath(modPath, version) {
+		_ = m.touchCacheEntry(goModuleDependency(modPath, version), SourceGoMod, modCachePath, changed, httpValidators{})
 	}
 
 	// Build final path
@@ -159,33 +190,63 @@ func (m *Manager) resolveGoMod(ctx context.Context, dep *Dependency) (*ResolveRe
 	}, nil
 }
 
-// resolveGoModVersion resolves the version for a Go module dependency
-func (m *Manager) resolveGoModVersion(dep *Dependency, url string, versions map[string]string) string {
-	// Priority: version in versions map > explicit version > local cache scan
-	if v, ok := versions[url]; ok {
-		return v
+// resolveGoModDir resolves a Go module dependency to a local module
+// directory.
+func (m *Manager) resolveGoModDir(dep *Dependency, url, dir string) (*ResolveResult, error) {
+	localPath := dir
+	if dep.Path != "" {
+		localPath = filepath.Join(dir, dep.Path)
 	}
+	if pathutil.IsNotExist(localPath) {
+		if dep.Optional != nil && *dep.Optional {
+			return &ResolveResult{LocalPath: "", Changed: false}, nil
+		}
+		return nil, &ResolveError{
+			Dependency: dep,
+			Source:     SourceGoMod,
+			URL:        url,
+			Operation:  "validate",
+			Err:        fmt.Errorf("local module directory '%s' not found", localPath),
+		}
+	}
+	return &ResolveResult{LocalPath: localPath, Changed: false}, nil
+}
 
+// resolveGoModVersion resolves the version of a Go module dependency that is
+// not required by go.mod, such as a module only required by other modules:
+// the explicit version, or the highest version in the local module cache.
+// This is not the version minimal version selection would pick, which needs
+// the whole module graph; pin the version to get another one.
+func (m *Manager) resolveGoModVersion(dep *Dependency, url string) string {
 	if dep.Version != nil && *dep.Version != "" {
 		return *dep.Version
 	}
 
 	// Try to find in local cache
-	dir := filepath.Dir(filepath.Join(m.gomodPath, url))
+	escPath, err := module.EscapePath(url)
+	if err != nil {
+		return ""
+	}
+	dir := filepath.Dir(filepath.Join(m.gomodPath, escPath))
 	entries, err := os.ReadDir(dir)
 	if err != nil {
 		return ""
 	}
 
-	_, name := filepath.Split(url)
+	_, name := filepath.Split(escPath)
+	var latest string
 	for _, entry := range entries {
-		if !entry.IsDir() {
+		if !entry.IsDir() || !strings.HasPrefix(entry.Name(), name+"@") {
+			continue
+		}
+		version, err := module.UnescapeVersion(strings.TrimPrefix(entry.Name(), name+"@"))
+		if err != nil || !semver.IsValid(version) {
 			continue
 		}
-		if strings.HasPrefix(entry.Name(), name+"@") {
-			return strings.TrimPrefix(entry.Name(), name+"@")
+		if latest == "" || semver.Compare(version, latest) > 0 {
+			latest = version
 		}
 	}
 
-	return ""
+	return latest
 }
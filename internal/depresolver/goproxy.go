@@ -0,0 +1,350 @@
+package depresolver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pubgo/funk/v2/pathutil"
+	"golang.org/x/mod/module"
+	"golang.org/x/mod/semver"
+	"golang.org/x/mod/sumdb/dirhash"
+	modzip "golang.org/x/mod/zip"
+
+	"github.com/pubgo/protobuild/internal/modutil"
+)
+
+const defaultGoProxy = "https://proxy.golang.org,direct"
+
+// errGoModuleNotFound reports a 404 or 410 response of a module proxy, after
+// which the next proxy in GOPROXY is tried.
+var errGoModuleNotFound = errors.New("module not found")
+
+// goProxy is an entry of GOPROXY: a proxy URL, "direct" or "off".
+type goProxy struct {
+	url string
+	// fallback tries the next entry on any error (| separator) instead of
+	// only if the module is not found (, separator).
+	fallback bool
+}
+
+// goProxies returns the proxies of the module path from GOPROXY.
+// GONOPROXY, which defaults to GOPRIVATE, selects modules fetched directly.
+func goProxies(path string) []goProxy {
+	noProxy := os.Getenv("GONOPROXY")
+	if noProxy == "" {
+		noProxy = os.Getenv("GOPRIVATE")
+	}
+	if noProxy != "" && module.MatchPrefixPatterns(noProxy, path) {
+		return []goProxy{{url: "direct"}}
+	}
+
+	list := os.Getenv("GOPROXY")
+	if list == "" {
+		list = defaultGoProxy
+	}
+
+	var proxies []goProxy
+	for list != "" {
+		entry, sep := list, byte(0)
+		if i := strings.IndexAny(list, ",|"); i >= 0 {
+			entry, sep, list = list[:i], list[i], list[i+1:]
+		} else {
+			list = ""
+		}
+		if entry = strings.TrimSpace(entry); entry != "" {
+			proxies = append(proxies, goProxy{url: strings.TrimRight(entry, "/"), fallback: sep == '|'})
+		}
+	}
+	return proxies
+}
+
+// tryGoProxies calls fetch with each proxy of path until one succeeds, as
+// the go command does.
+func tryGoProxies(path string, fetch func(proxy string) error) error {
+	err := fmt.Errorf("GOPROXY list is empty")
+	for _, p := range goProxies(path) {
+		if p.url == "off" {
+			if errors.Is(err, errGoModuleNotFound) {
+				return err
+			}
+			return fmt.Errorf("module lookup disabled by GOPROXY=off")
+		}
+		err = fetch(p.url)
+		if err == nil || !p.fallback && !errors.Is(err, errGoModuleNotFound) {
+			return err
+		}
+	}
+	return err
+}
+
+// goModuleDir returns the directory of path@version in the Go module cache
+// or the dependency cache, or "" if it is in neither.
+func (m *Manager) goModuleDir(path, version string) string {
+	if version == "" {
+		return ""
+	}
+	if escPath, err := module.EscapePath(path); err == nil {
+		if escVersion, err := module.EscapeVersion(version); err == nil {
+			dir := filepath.Join(m.gomodPath, escPath+"@"+escVersion)
+			if pathutil.IsDir(dir) {
+				return dir
+			}
+		}
+	}
+	if dir := m.goModuleCachePath(path, version); pathutil.IsDir(dir) {
+		return dir
+	}
+	return ""
+}
+
+// goModuleCachePath returns the dependency cache directory of path@version
+// downloaded from a module proxy.
+func (m *Manager) goModuleCachePath(path, version string) string {
+	return m.cachePathForDependency(goModuleDependency(path, version), SourceGoMod)
+}
+
+func goModuleDependency(path, version string) *Dependency {
+	return &Dependency{Source: SourceGoMod, URL: path, Version: &version}
+}
+
+// queryGoModule resolves a version query, e.g. a branch or "" for the
+// latest version, to a module version.
+func (m *Manager) queryGoModule(ctx context.Context, path, query string) (string, error) {
+	if query != "" && semver.IsValid(query) && semver.Canonical(query) == query {
+		return query, nil
+	}
+
+	var version string
+	err := tryGoProxies(path, func(proxy string) error {
+		if proxy == "direct" {
+			if query == "" {
+				query = "latest"
+			}
+			var info struct{ Version string }
+			if err := runGoDirect(ctx, &info, "list", "-m", "-json", path+"@"+query); err != nil {
+				return err
+			}
+			version = info.Version
+			return nil
+		}
+
+		escPath, err := module.EscapePath(path)
+		if err != nil {
+			return err
+		}
+		url := proxy + "/" + escPath + "/@latest"
+		if query != "" {
+			escQuery, err := module.EscapeVersion(query)
+			if err != nil {
+				return err
+			}
+			url = proxy + "/" + escPath + "/@v/" + escQuery + ".info"
+		}
+		data, err := fetchGoProxy(ctx, url)
+		if err != nil {
+			return err
+		}
+		var info struct{ Version string }
+		if err := json.Unmarshal(data, &info); err != nil {
+			return fmt.Errorf("parse %s: %w", url, err)
+		}
+		version = info.Version
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	if version == "" {
+		return "", fmt.Errorf("no version of %s matches %q", path, query)
+	}
+	return version, nil
+}
+
+// downloadGoModule downloads path@version and returns its directory.
+// Module zips from proxies are checked against the go.sum files of ws or
+// the checksum database and extracted into the dependency cache; direct
+// downloads use the go command and the Go module cache.
+func (m *Manager) downloadGoModule(ctx context.Context, path, version string, ws *modutil.Workspace) (string, error) {
+	var dir string
+	err := tryGoProxies(path, func(proxy string) error {
+		if proxy == "direct" {
+			var info struct{ Dir string }
+			if err := runGoDirect(ctx, &info, "mod", "download", "-json", path+"@"+version); err != nil {
+				return err
+			}
+			dir = info.Dir
+			return nil
+		}
+
+		zipFile, err := fetchGoModuleZip(ctx, proxy, path, version)
+		if err != nil {
+			return err
+		}
+		defer os.Remove(zipFile)
+
+		if err := m.verifyGoModuleZip(ctx, path, version, zipFile, ws); err != nil {
+			return err
+		}
+		dir, err = m.extractGoModule(path, version, zipFile)
+		return err
+	})
+	return dir, err
+}
+
+// fetchGoModuleZip downloads the module zip of path@version from proxy to
+// a temporary file.
+func fetchGoModuleZip(ctx context.Context, proxy, path, version string) (string, error) {
+	escPath, err := module.EscapePath(path)
+	if err != nil {
+		return "", err
+	}
+	escVersion, err := module.EscapeVersion(version)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := getGoProxy(ctx, proxy+"/"+escPath+"/@v/"+escVersion+".zip")
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	f, err := os.CreateTemp("", "protobuild-gomod-*.zip")
+	if err != nil {
+		return "", err
+	}
+	_, err = io.Copy(f, resp.Body)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(f.Name())
+		return "", err
+	}
+	return f.Name(), nil
+}
+
+// verifyGoModuleZip checks the hash of a module zip against go.sum, or the
+// checksum database if go.sum has no entry for it.
+func (m *Manager) verifyGoModuleZip(ctx context.Context, path, version, zipFile string, ws *modutil.Workspace) error {
+	got, err := dirhash.HashZip(zipFile, dirhash.Hash1)
+	if err != nil {
+		return err
+	}
+
+	want := ws.Sum(path, version)
+	if want == "" {
+		if want, err = m.lookupGoSum(ctx, path, version); err != nil || want == "" {
+			return err
+		}
+	}
+	if got != want {
+		return fmt.Errorf("%w: %s@%s: want %s, got %s", ErrChecksumMismatch, path, version, want, got)
+	}
+	return nil
+}
+
+// extractGoModule extracts a module zip into the dependency cache.
+func (m *Manager) extractGoModule(path, version, zipFile string) (string, error) {
+	dir := m.goModuleCachePath(path, version)
+	if err := os.MkdirAll(filepath.Dir(dir), 0o755); err != nil {
+		return "", err
+	}
+	tmp, err := os.MkdirTemp(filepath.Dir(dir), filepath.Base(dir)+".download-*")
+	if err != nil {
+		return "", err
+	}
+	if err := modzip.Unzip(tmp, module.Version{Path: path, Version: version}, zipFile); err != nil {
+		_ = os.RemoveAll(tmp)
+		return "", err
+	}
+	if err := replaceDir(tmp, dir); err != nil {
+		_ = os.RemoveAll(tmp)
+		return "", err
+	}
+	return dir, nil
+}
+
+// fetchGoProxy returns the body of a module proxy URL.
+func fetchGoProxy(ctx context.Context, url string) ([]byte, error) {
+	resp, err := getGoProxy(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return io.ReadAll(resp.Body)
+}
+
+// getGoProxy requests a module proxy URL. 404 and 410 responses are
+// errGoModuleNotFound.
+func getGoProxy(ctx context.Context, url string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case resp.StatusCode == http.StatusOK:
+		return resp, nil
+	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone:
+		resp.Body.Close()
+		return nil, fmt.Errorf("%w: GET %s: %s", errGoModuleNotFound, url, resp.Status)
+	default:
+		resp.Body.Close()
+		return nil, fmt.Errorf("GET %s: %s", url, resp.Status)
+	}
+}
+
+// runGoDirect runs a go command fetching modules directly from their
+// repositories and decodes its JSON output into v. It runs outside of any
+// module, so no go.mod file is changed.
+func runGoDirect(ctx context.Context, v any, args ...string) error {
+	goBin, err := exec.LookPath("go")
+	if err != nil {
+		return fmt.Errorf("GOPROXY=direct needs the go command: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Minute)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, goBin, args...)
+	cmd.Dir = os.TempDir()
+	cmd.Env = append(os.Environ(), "GOPROXY=direct", "GOWORK=off", "GOFLAGS=-mod=mod")
+	cmd.Stderr = os.Stderr
+	out, err := cmd.Output()
+	// go mod download -json reports errors in its output.
+	var result struct{ Error string }
+	if json.Unmarshal(out, &result) == nil && result.Error != "" {
+		return errors.New(result.Error)
+	}
+	if err != nil {
+		return fmt.Errorf("go %s: %w", strings.Join(args, " "), err)
+	}
+	return json.Unmarshal(out, v)
+}
+
+// defaultGoModCache returns the Go module cache directory: $GOMODCACHE or
+// pkg/mod in the first GOPATH entry, which defaults to ~/go.
+func defaultGoModCache() string {
+	if dir := os.Getenv("GOMODCACHE"); dir != "" {
+		return dir
+	}
+	gopath := filepath.SplitList(os.Getenv("GOPATH"))
+	if len(gopath) > 0 && gopath[0] != "" {
+		return filepath.Join(gopath[0], "pkg", "mod")
+	}
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, "go", "pkg", "mod")
+}
@@ -0,0 +1,215 @@
+package depresolver
+
+import (
+	"archive/zip"
+	"context"
+	"crypto/rand"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/mod/sumdb"
+	"golang.org/x/mod/sumdb/dirhash"
+	"golang.org/x/mod/sumdb/note"
+)
+
+func TestGoProxies(t *testing.T) {
+	t.Setenv("GONOPROXY", "")
+	t.Setenv("GOPRIVATE", "corp.example.com")
+	t.Setenv("GOPROXY", "https://a.example.com/, https://b.example.com|direct")
+
+	want := []goProxy{
+		{url: "https://a.example.com"},
+		{url: "https://b.example.com", fallback: true},
+		{url: "direct"},
+	}
+	got := goProxies("github.com/acme/api")
+	if len(got) != len(want) {
+		t.Fatalf("goProxies() = %+v, want %+v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("goProxies()[%d] = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+
+	if got := goProxies("corp.example.com/api"); len(got) != 1 || got[0].url != "direct" {
+		t.Errorf("goProxies() of a private module = %+v, want direct", got)
+	}
+}
+
+func writeTestFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// writeModuleZip writes the module zip of path@version with files and
+// returns its h1: hash.
+func writeModuleZip(t *testing.T, file, path, version string, files map[string]string) string {
+	t.Helper()
+	f, err := os.Create(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := zip.NewWriter(f)
+	for name, content := range files {
+		w, err := zw.Create(path + "@" + version + "/" + name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	sum, err := dirhash.HashZip(file, dirhash.Hash1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sum
+}
+
+// newGoProxyServer serves example.com/acme@v1.0.0 as module proxy and its
+// hash from a checksum database, and sets the go environment to use them.
+func newGoProxyServer(t *testing.T) {
+	t.Helper()
+	zipFile := filepath.Join(t.TempDir(), "acme.zip")
+	sum := writeModuleZip(t, zipFile, "example.com/acme", "v1.0.0", map[string]string{
+		"go.mod":        "module example.com/acme\n",
+		"proto/a.proto": "syntax = \"proto3\";",
+	})
+
+	skey, vkey, err := note.GenerateKey(rand.Reader, "sum.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sumDB := sumdb.NewServer(sumdb.NewTestServer(skey, func(path, version string) ([]byte, error) {
+		return []byte(path + " " + version + " " + sum + "\n"), nil
+	}))
+
+	mux := http.NewServeMux()
+	mux.Handle("/sumdb/", http.StripPrefix("/sumdb", sumDB))
+	mux.HandleFunc("/example.com/acme/@latest", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"Version":"v1.0.0"}`))
+	})
+	mux.HandleFunc("/example.com/acme/@v/v1.0.0.zip", func(w http.ResponseWriter, r *http.Request) {
+		http.ServeFile(w, r, zipFile)
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	t.Setenv("GOPROXY", srv.URL)
+	t.Setenv("GOSUMDB", vkey+" "+srv.URL+"/sumdb")
+	t.Setenv("GONOSUMDB", "")
+	t.Setenv("GONOPROXY", "")
+	t.Setenv("GOPRIVATE", "")
+	t.Setenv("GOWORK", "off")
+}
+
+func TestResolveGoMod_Proxy(t *testing.T) {
+	newGoProxyServer(t)
+	t.Chdir(t.TempDir())
+
+	m := NewManager(t.TempDir(), t.TempDir())
+	dep := &Dependency{Name: "acme", Source: SourceGoMod, URL: "example.com/acme", Path: "proto"}
+	res, err := m.Resolve(context.Background(), dep)
+	if err != nil {
+		t.Fatalf("Resolve() = %v", err)
+	}
+	if res.Version != "v1.0.0" || !res.Changed {
+		t.Errorf("Resolve() = %+v, want a download of the latest version", res)
+	}
+	if _, err := os.Stat(filepath.Join(res.LocalPath, "a.proto")); err != nil {
+		t.Errorf("module not extracted: %v", err)
+	}
+
+	// The module is cached; a second resolution does not download.
+	res, err = m.Resolve(context.Background(), &Dependency{Name: "acme", Source: SourceGoMod, URL: "example.com/acme", Version: &res.Version})
+	if err != nil || res.Changed {
+		t.Errorf("Resolve() from cache = %+v, %v", res, err)
+	}
+}
+
+func TestResolveGoMod_GoSumMismatch(t *testing.T) {
+	newGoProxyServer(t)
+	dir := t.TempDir()
+	writeTestFile(t, filepath.Join(dir, "go.mod"), "module example.com/app\n\nrequire example.com/acme v1.0.0\n")
+	writeTestFile(t, filepath.Join(dir, "go.sum"), "example.com/acme v1.0.0 h1:bad=\n")
+	t.Chdir(dir)
+
+	m := NewManager(t.TempDir(), t.TempDir())
+	_, err := m.Resolve(context.Background(), &Dependency{Name: "acme", Source: SourceGoMod, URL: "example.com/acme"})
+	if !errors.Is(err, ErrChecksumMismatch) {
+		t.Fatalf("Resolve() = %v, want checksum mismatch", err)
+	}
+	if _, err := os.Stat(m.goModuleCachePath("example.com/acme", "v1.0.0")); !os.IsNotExist(err) {
+		t.Error("unverified module left in the cache")
+	}
+}
+
+func TestResolveGoMod_LocalReplace(t *testing.T) {
+	t.Setenv("GOWORK", "off")
+	t.Setenv("GOPROXY", "off")
+	dir := t.TempDir()
+	writeTestFile(t, filepath.Join(dir, "go.mod"), "module example.com/app\n\nrequire example.com/acme v1.0.0\n\nreplace example.com/acme => ./acme\n")
+	writeTestFile(t, filepath.Join(dir, "acme", "proto", "a.proto"), "syntax = \"proto3\";")
+	t.Chdir(dir)
+
+	m := NewManager(t.TempDir(), t.TempDir())
+	res, err := m.Resolve(context.Background(), &Dependency{Name: "acme", Source: SourceGoMod, URL: "example.com/acme", Path: "proto"})
+	if err != nil {
+		t.Fatalf("Resolve() = %v", err)
+	}
+	if want := filepath.Join(dir, "acme", "proto"); res.LocalPath != want {
+		t.Errorf("LocalPath = %q, want %q", res.LocalPath, want)
+	}
+}
+
+func TestResolveGoModVersion_Cache(t *testing.T) {
+	gomodPath := t.TempDir()
+	for _, v := range []string{"v1.9.0", "v1.10.0", "v1.11.0-rc.1", "bogus"} {
+		if err := os.MkdirAll(filepath.Join(gomodPath, "example.com", "acme@"+v), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeTestFile(t, filepath.Join(gomodPath, "example.com", "acme-extra@v2.0.0", "a.proto"), "")
+
+	m := NewManager(t.TempDir(), gomodPath)
+	if got := m.resolveGoModVersion(&Dependency{URL: "example.com/acme"}, "example.com/acme"); got != "v1.11.0-rc.1" {
+		t.Errorf("resolveGoModVersion() = %q, want the highest cached version", got)
+	}
+	os.Remove(filepath.Join(gomodPath, "example.com", "acme@v1.11.0-rc.1"))
+	if got := m.resolveGoModVersion(&Dependency{URL: "example.com/acme"}, "example.com/acme"); got != "v1.10.0" {
+		t.Errorf("resolveGoModVersion() = %q, want v1.10.0", got)
+	}
+	if got := m.resolveGoModVersion(&Dependency{URL: "example.com/acme", Version: strPtr("v1.9.0")}, "example.com/acme"); got != "v1.9.0" {
+		t.Errorf("resolveGoModVersion() = %q, want the pinned version", got)
+	}
+}
+
+func TestResolveGoMod_ProxyOff(t *testing.T) {
+	t.Setenv("GOWORK", "off")
+	t.Setenv("GOPROXY", "off")
+	t.Chdir(t.TempDir())
+
+	m := NewManager(t.TempDir(), t.TempDir())
+	_, err := m.Resolve(context.Background(), &Dependency{Name: "acme", Source: SourceGoMod, URL: "example.com/acme"})
+	if err == nil || !strings.Contains(err.Error(), "GOPROXY=off") {
+		t.Errorf("Resolve() = %v, want GOPROXY=off error", err)
+	}
+}
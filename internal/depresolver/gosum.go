@@ -0,0 +1,192 @@
+package depresolver
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"golang.org/x/mod/module"
+	"golang.org/x/mod/sumdb"
+)
+
+// goSumDBKey is the verifier key of sum.golang.org.
+const goSumDBKey = "sum.golang.org+033de0ae+Ac4zctda0e5eza+HJyk9SxEdh+s3Ppe3o2UhZhlzqHSY"
+
+// lookupGoSum returns the h1: hash of the module zip of path@version from
+// the checksum database of GOSUMDB, or "" if GOSUMDB=off or the module
+// matches GONOSUMDB, which defaults to GOPRIVATE.
+func (m *Manager) lookupGoSum(ctx context.Context, path, version string) (string, error) {
+	noSumDB := os.Getenv("GONOSUMDB")
+	if noSumDB == "" {
+		noSumDB = os.Getenv("GOPRIVATE")
+	}
+	if noSumDB != "" && module.MatchPrefixPatterns(noSumDB, path) {
+		return "", nil
+	}
+
+	ops, err := newSumDBOps(ctx, path, filepath.Join(filepath.Dir(m.cacheDir), "sumdb"))
+	if err != nil || ops == nil {
+		return "", err
+	}
+	lines, err := sumdb.NewClient(ops).Lookup(path, version)
+	if err != nil {
+		return "", fmt.Errorf("checksum database: %w", err)
+	}
+	for _, line := range lines {
+		if fields := strings.Fields(line); len(fields) == 3 && fields[0] == path && fields[1] == version {
+			return fields[2], nil
+		}
+	}
+	return "", fmt.Errorf("checksum database: no hash of %s@%s", path, version)
+}
+
+// sumDBOps implements sumdb.ClientOps over HTTP, with the verified tree and
+// tiles cached in dir.
+type sumDBOps struct {
+	ctx  context.Context
+	key  string
+	urls []string
+	dir  string
+}
+
+// newSumDBOps returns the client operations of the checksum database of
+// GOSUMDB, "name+hash+key [url]" or a known database name, or nil if it is
+// off. The database is read through the module proxies of path, if they
+// support it, and then directly.
+func newSumDBOps(ctx context.Context, path, dir string) (*sumDBOps, error) {
+	gosumdb := os.Getenv("GOSUMDB")
+	if gosumdb == "" {
+		gosumdb = "sum.golang.org"
+	}
+	if gosumdb == "off" {
+		return nil, nil
+	}
+
+	fields := strings.Fields(gosumdb)
+	key, url := fields[0], ""
+	if len(fields) > 1 {
+		url = strings.TrimRight(fields[1], "/")
+	}
+	switch key {
+	case "sum.golang.org":
+		key = goSumDBKey
+	case "sum.golang.google.cn":
+		key = goSumDBKey
+		if url == "" {
+			url = "https://sum.golang.google.cn"
+		}
+	}
+	name, _, ok := strings.Cut(key, "+")
+	if !ok {
+		return nil, fmt.Errorf("GOSUMDB %q: missing verifier key", gosumdb)
+	}
+
+	ops := &sumDBOps{ctx: ctx, key: key, dir: dir}
+	if url != "" {
+		ops.urls = []string{url}
+		return ops, nil
+	}
+	for _, p := range goProxies(path) {
+		if p.url != "direct" && p.url != "off" {
+			ops.urls = append(ops.urls, p.url+"/sumdb/"+name)
+		}
+	}
+	ops.urls = append(ops.urls, "https://"+name)
+	return ops, nil
+}
+
+func (o *sumDBOps) ReadRemote(path string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(o.ctx, time.Minute)
+	defer cancel()
+
+	var err error
+	for _, url := range o.urls {
+		var data []byte
+		if data, err = o.get(ctx, url+path); err == nil {
+			return data, nil
+		}
+	}
+	return nil, err
+}
+
+func (o *sumDBOps) get(ctx context.Context, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET %s: %s", url, resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+func (o *sumDBOps) ReadConfig(file string) ([]byte, error) {
+	if file == "key" {
+		return []byte(o.key), nil
+	}
+	data, err := os.ReadFile(filepath.Join(o.dir, filepath.FromSlash(file)))
+	if os.IsNotExist(err) {
+		// Start from an empty tree.
+		return []byte{}, nil
+	}
+	return data, err
+}
+
+func (o *sumDBOps) WriteConfig(file string, old, data []byte) error {
+	cur, err := o.ReadConfig(file)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(cur, old) {
+		return sumdb.ErrWriteConflict
+	}
+	return writeFileAtomic(filepath.Join(o.dir, filepath.FromSlash(file)), data)
+}
+
+func (o *sumDBOps) ReadCache(file string) ([]byte, error) {
+	return os.ReadFile(filepath.Join(o.dir, "cache", filepath.FromSlash(file)))
+}
+
+func (o *sumDBOps) WriteCache(file string, data []byte) {
+	_ = writeFileAtomic(filepath.Join(o.dir, "cache", filepath.FromSlash(file)), data)
+}
+
+func (o *sumDBOps) Log(string) {}
+
+func (o *sumDBOps) SecurityError(msg string) {
+	fmt.Fprintln(os.Stderr, msg)
+}
+
+// writeFileAtomic writes a file through a temporary file, so readers never
+// see partial content.
+func writeFileAtomic(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), path)
+	}
+	if err != nil {
+		_ = os.Remove(f.Name())
+	}
+	return err
+}
@@ -4,6 +4,7 @@ package depresolver
 import (
 	"context"
 	"crypto/sha256"
+	"errors"
 	"fmt"
 	"io"
 	"os"
@@ -63,6 +64,9 @@ type Dependency struct {
 	Path     string  `yaml:"path,omitempty"`     // subdirectory within the source
 	Version  *string `yaml:"version,omitempty"`  // module version; for git it is used as tag/branch/commit
 	Optional *bool   `yaml:"optional,omitempty"` // skip if not found
+	// Checksum is an h1: hash of the resolved files, or a go-getter
+	// type:value checksum of the downloaded archive (bare hex is sha256).
+	Checksum string `yaml:"checksum,omitempty"`
 }
 
 // ResolveResult contains the result of dependency resolution
@@ -72,11 +76,16 @@ type ResolveResult struct {
 	Changed   bool   // whether the dependency was updated
 }
 
+// ErrOffline reports that a dependency is not available locally in offline
+// mode.
+var ErrOffline = errors.New("not available offline")
+
 // ResolveError provides detailed error information for dependency resolution failures
 type ResolveError struct {
 	Dependency *Dependency
 	Source     Source
 	URL        string
+	Mirror     string // URL the dependency was fetched from, if rewritten
 	Operation  string // "download", "resolve", "validate"
 	Err        error
 }
@@ -86,6 +95,12 @@ func (e *ResolveError) Error() string {
 	sb.WriteString(fmt.Sprintf("\n❌ Failed to %s dependency: %s\n", e.Operation, e.Dependency.Name))
 	sb.WriteString(fmt.Sprintf("   Source:  %s\n", e.Source.DisplayName()))
 	sb.WriteString(fmt.Sprintf("   URL:     %s\n", e.URL))
+	if e.Mirror != "" {
+		sb.WriteString(fmt.Sprintf("   Mirror:  %s\n", e.Mirror))
+	}
+	if e.Source == SourceGoMod && os.Getenv("GOPROXY") != "" {
+		sb.WriteString(fmt.Sprintf("   GOPROXY: %s\n", os.Getenv("GOPROXY")))
+	}
 	if version := dependencyVersion(e.Dependency); version != "" {
 		sb.WriteString(fmt.Sprintf("   Version: %s\n", version))
 	}
@@ -95,6 +110,25 @@ func (e *ResolveError) Error() string {
 	sb.WriteString(fmt.Sprintf("   Error:   %s\n", e.Err.Error()))
 	sb.WriteString("\n💡 Suggestions:\n")
 
+	if errors.Is(e.Err, ErrChecksumMismatch) {
+		sb.WriteString("   • The content differs from the pinned checksum, check the upstream for unexpected changes\n")
+		sb.WriteString("   • If the change is expected, update checksum with the hash shown by 'protobuild deps'\n")
+		sb.WriteString(fmt.Sprintf("   • If the cache is corrupted, re-download it: protobuild vendor -u %s\n", e.Dependency.Name))
+		return sb.String()
+	}
+
+	if errors.Is(e.Err, ErrOffline) {
+		sb.WriteString("   • Fetch it once with network access, e.g. run 'protobuild vendor' without --offline\n")
+		if e.Source == SourceGoMod {
+			sb.WriteString(fmt.Sprintf("   • Or download the module: go mod download %s\n", e.URL))
+		}
+		return sb.String()
+	}
+
+	if e.Mirror != "" {
+		sb.WriteString("   • Check that the mirror serves this dependency, or fix the mirrors rule that matches it\n")
+	}
+
 	// Add helpful suggestions based on source type and error
 	switch e.Source {
 	case SourceGit:
@@ -116,6 +150,7 @@ func (e *ResolveError) Error() string {
 	case SourceGoMod:
 		sb.WriteString("   • Check if the module path is correct\n")
 		sb.WriteString("   • Verify the version exists in the module\n")
+		sb.WriteString("   • Check that GOPROXY can serve the module, see 'go env GOPROXY'\n")
 		sb.WriteString("   • Run 'go mod tidy' to update dependencies\n")
 	case SourceLocal:
 		sb.WriteString("   • Check if the local path exists\n")
@@ -132,7 +167,13 @@ func (e *ResolveError) Unwrap() error {
 // Manager manages dependency resolution
 type Manager struct {
 	cacheDir  string
-	gomodPath string // $GOPATH/pkg/mod
+	gomodPath string // Go module cache, $GOMODCACHE
+	project   string // config path recorded in cache metadata
+
+	refreshTTL time.Duration // refresh interval of mutable refs, 0 = never
+	offline    bool          // never access the network
+	readOnly   bool          // never write cache metadata
+	mirrors    []Mirror      // URL rewrite rules of getter-based sources
 }
 
 // NewManager creates a new dependency manager
@@ -145,7 +186,7 @@ func NewManager(cacheDir, gomodPath string) *Manager {
 		cacheDir = filepath.Join(home, ".cache", "protobuild", "deps")
 	}
 	if gomodPath == "" {
-		gomodPath = filepath.Join(os.Getenv("GOPATH"), "pkg", "mod")
+		gomodPath = defaultGoModCache()
 	}
 
 	return &Manager{
@@ -154,6 +195,23 @@ func NewManager(cacheDir, gomodPath string) *Manager {
 	}
 }
 
+// WithOffline makes Resolve use only the dependency and module caches. A
+// dependency that is not cached fails with ErrOffline.
+func (m *Manager) WithOffline(offline bool) *Manager {
+	m.offline = offline
+	return m
+}
+
+// Lookup resolves dep from the caches only, like an offline Resolve, but
+// leaves the cache metadata untouched, so inspecting dependencies does not
+// change what GC evicts.
+func (m *Manager) Lookup(ctx context.Context, dep *Dependency) (*ResolveResult, error) {
+	lookup := *m
+	lookup.offline = true
+	lookup.readOnly = true
+	return lookup.Resolve(ctx, dep)
+}
+
 // Resolve resolves a dependency
 func (m *Manager) Resolve(ctx context.Context, dep *Dependency) (*ResolveResult, error) {
 	if dep == nil {
@@ -165,15 +223,54 @@ func (m *Manager) Resolve(ctx context.Context, dep *Dependency) (*ResolveResult,
 	dep.Source = source
 	m.normalizeVersion(dep)
 
+	if err := validateChecksum(dep, source); err != nil {
+		return nil, &ResolveError{
+			Dependency: dep,
+			Source:     source,
+			URL:        dep.URL,
+			Operation:  "validate",
+			Err:        err,
+		}
+	}
+
+	var result *ResolveResult
+	var err error
 	switch source {
 	case SourceLocal:
-		return m.resolveLocal(dep)
+		result, err = m.resolveLocal(dep)
 	case SourceGoMod:
-		return m.resolveGoMod(ctx, dep)
+		result, err = m.resolveGoMod(ctx, dep)
 	default:
 		// Use go-getter for git, http, s3, gcs sources
-		return m.resolveWithGetter(ctx, dep, source)
+		result, err = m.resolveWithGetter(ctx, dep, source)
+	}
+	if err != nil {
+		var resolveErr *ResolveError
+		if errors.As(err, &resolveErr) && source != SourceLocal && source != SourceGoMod {
+			if mirror := m.MirrorURL(dep.URL); mirror != strings.TrimSpace(dep.URL) {
+				resolveErr.Mirror = mirror
+			}
+		}
+		return nil, err
 	}
+	if result.LocalPath == "" {
+		return result, nil
+	}
+
+	if err := verifyContent(dep, result.LocalPath); err != nil {
+		// Do not keep unverified downloads in the cache.
+		if result.Changed && source != SourceLocal && source != SourceGoMod {
+			_ = m.Evict(dep)
+		}
+		return nil, &ResolveError{
+			Dependency: dep,
+			Source:     source,
+			URL:        dep.URL,
+			Operation:  "verify",
+			Err:        err,
+		}
+	}
+	return result, nil
 }
 
 // normalizeVersion trims and normalizes the version field.
@@ -286,7 +383,22 @@ func (m *Manager) resolveWithGetter(ctx context.Context, dep *Dependency, source
 
 	// Check if we need to download
 	changed := false
-	if pathutil.IsNotExist(cachePath) {
+	if m.offline && pathutil.IsNotExist(cachePath) {
+		if dep.Optional != nil && *dep.Optional {
+			return &ResolveResult{LocalPath: "", Changed: false}, nil
+		}
+		return nil, &ResolveError{
+			Dependency: dep,
+			Source:     source,
+			URL:        m.buildGetterURL(dep, source),
+			Operation:  "resolve",
+			Err:        fmt.Errorf("%w: not in the dependency cache (%s)", ErrOffline, cachePath),
+		}
+	}
+
+	refresh := pathutil.IsExist(cachePath) && m.isStale(ctx, dep, source, cachePath)
+	var validators httpValidators
+	if pathutil.IsNotExist(cachePath) || refresh {
 		changed = true
 
 		displayName := strings.TrimSpace(dep.Name)
@@ -295,7 +407,11 @@ func (m *Manager) resolveWithGetter(ctx context.Context, dep *Dependency, source
 		}
 
 		getterURL := m.buildGetterURL(dep, source)
-		fmt.Printf("  📥 [%s] %s\n", source.DisplayName(), displayName)
+		if refresh {
+			fmt.Printf("  🔄 [%s] %s (refreshing mutable ref)\n", source.DisplayName(), displayName)
+		} else {
+			fmt.Printf("  📥 [%s] %s\n", source.DisplayName(), displayName)
+		}
 		fmt.Printf("     URL: %s\n", getterURL)
 		if version := dependencyVersion(dep); version != "" {
 			fmt.Printf("     Version: %s\n", version)
@@ -316,15 +432,64 @@ func (m *Manager) resolveWithGetter(ctx context.Context, dep *Dependency, source
 			}
 		}
 
+		// Record the validators before downloading, so a change in between
+		// is detected by the next check rather than missed.
+		if source == SourceHTTP {
+			validators, _ = fetchHTTPValidators(ctx, m.MirrorURL(dep.URL))
+		}
+
+		// A refresh downloads next to the cached copy, which is kept if
+		// the download fails.
+		destPath := cachePath
+		if refresh {
+			destPath = cachePath + ".refresh"
+			_ = os.RemoveAll(destPath)
+		}
+
 		// Download using go-getter
-		if err := m.downloadWithGetter(ctx, dep, source, cachePath); err != nil {
-			if dep.Optional != nil && *dep.Optional {
+		err := m.downloadWithGetter(ctx, dep, source, destPath)
+		switch {
+		case err != nil && refresh:
+			_ = os.RemoveAll(destPath)
+			fmt.Printf("  ⚠️  Refresh failed, using cached copy: %v\n", errors.Unwrap(err))
+			changed = false
+		case err != nil:
+			// Do not leave a partial download in the cache.
+			_ = os.RemoveAll(destPath)
+			if dep.Optional != nil && *dep.Optional && !errors.Is(err, ErrChecksumMismatch) {
 				return &ResolveResult{LocalPath: "", Changed: false}, nil
 			}
 			return nil, err
+		case refresh:
+			if err := replaceDir(destPath, cachePath); err != nil {
+				return nil, &ResolveError{
+					Dependency: dep,
+					Source:     source,
+					URL:        dep.URL,
+					Operation:  "resolve",
+					Err:        fmt.Errorf("failed to update cache: %w", err),
+				}
+			}
+		}
+	}
+
+	// A cached archive with a checksum must still have the content that
+	// was verified on download.
+	if !changed && archiveChecksum(dep) != "" {
+		if err := m.verifyCacheEntry(cachePath); err != nil {
+			return nil, &ResolveError{
+				Dependency: dep,
+				Source:     source,
+				URL:        dep.URL,
+				Operation:  "verify",
+				Err:        err,
+			}
 		}
 	}
 
+	// Metadata is best effort; a failure must not fail the resolution.
+	_ = m.touchCacheEntry(dep, source, cachePath, changed, validators)
+
 	// Build final path with subdirectory
 	localPath := cachePath
 	if dep.Path != "" {
@@ -352,6 +517,14 @@ func (m *Manager) resolveWithGetter(ctx context.Context, dep *Dependency, source
 	}, nil
 }
 
+// replaceDir replaces dst with src.
+func replaceDir(src, dst string) error {
+	if err := os.RemoveAll(dst); err != nil {
+		return err
+	}
+	return os.Rename(src, dst)
+}
+
 // cachePathForDependency builds a stable cache path for getter-based sources.
 func (m *Manager) cachePathForDependency(dep *Dependency, source Source) string {
 	cacheKey := m.cacheKeyForDependency(dep, source)
@@ -368,6 +541,7 @@ func (m *Manager) cacheKeyForDependency(dep *Dependency, source Source) string {
 // Notes:
 //   - dep.Path is intentionally excluded so multiple subpaths share one downloaded source.
 //   - For getter-based sources, URL normalization follows buildGetterURL behavior.
+//   - Mirror rules are not applied, so the cache survives a mirror change.
 func (m *Manager) cacheSeedForDependency(dep *Dependency, source Source) string {
 	if dep == nil {
 		return string(source)
@@ -386,7 +560,7 @@ func (m *Manager) cacheSeedForDependency(dep *Dependency, source Source) string
 			v := trimmedVersion
 			normalized.Version = &v
 		}
-		return fmt.Sprintf("%s|%s", source, m.buildGetterURL(&normalized, source))
+		return fmt.Sprintf("%s|%s", source, formatGetterURL(&normalized, source))
 	}
 
 	if trimmedVersion == "" {
@@ -457,6 +631,10 @@ func (m *Manager) downloadWithGetter(ctx context.Context, dep *Dependency, sourc
 	tracker.Finish()
 
 	if err != nil {
+		var checksumErr *getter.ChecksumError
+		if errors.As(err, &checksumErr) {
+			err = fmt.Errorf("%w: %v", ErrChecksumMismatch, err)
+		}
 		return &ResolveError{
 			Dependency: dep,
 			Source:     source,
@@ -468,8 +646,16 @@ func (m *Manager) downloadWithGetter(ctx context.Context, dep *Dependency, sourc
 	return nil
 }
 
-// buildGetterURL constructs the go-getter URL with appropriate prefix and query parameters
+// buildGetterURL constructs the go-getter URL of dep, fetched through the
+// mirror rules.
 func (m *Manager) buildGetterURL(dep *Dependency, source Source) string {
+	mirrored := *dep
+	mirrored.URL = m.MirrorURL(dep.URL)
+	return formatGetterURL(&mirrored, source)
+}
+
+// formatGetterURL constructs the go-getter URL with appropriate prefix and query parameters
+func formatGetterURL(dep *Dependency, source Source) string {
 	url := dep.URL
 
 	switch source {
@@ -537,6 +723,18 @@ func (m *Manager) buildGetterURL(dep *Dependency, source Source) string {
 		}
 	}
 
+	// go-getter verifies the downloaded file against the checksum parameter.
+	if checksum := archiveChecksum(dep); checksum != "" && !hasGetterQueryParam(url, "checksum") {
+		switch source {
+		case SourceHTTP, SourceS3, SourceGCS:
+			if strings.Contains(url, "?") {
+				url += "&checksum=" + checksum
+			} else {
+				url += "?checksum=" + checksum
+			}
+		}
+	}
+
 	return url
 }
 
@@ -1,6 +1,8 @@
 package depresolver
 
 import (
+	"context"
+	"errors"
 	"os"
 	"path/filepath"
 	"strings"
@@ -443,3 +445,42 @@ func TestCleanCache(t *testing.T) {
 		t.Error("Cache directory should be removed")
 	}
 }
+
+func TestResolveOffline(t *testing.T) {
+	ctx := context.Background()
+	m := NewManager(t.TempDir(), t.TempDir()).WithOffline(true)
+
+	tests := []*Dependency{
+		{Name: "git", Source: SourceGit, URL: "https://example.invalid/protos.git"},
+		{Name: "http", Source: SourceHTTP, URL: "https://example.invalid/protos.tar.gz"},
+		{Name: "gomod", Source: SourceGoMod, URL: "example.invalid/protos", Version: strPtr("v1.0.0")},
+	}
+	for _, dep := range tests {
+		_, err := m.Resolve(ctx, dep)
+		var resolveErr *ResolveError
+		if !errors.As(err, &resolveErr) || !errors.Is(err, ErrOffline) {
+			t.Errorf("Resolve(%s) error = %v, want offline ResolveError", dep.Name, err)
+			continue
+		}
+		if !strings.Contains(resolveErr.Error(), "without --offline") {
+			t.Errorf("Resolve(%s) error has no offline suggestion:\n%s", dep.Name, resolveErr.Error())
+		}
+	}
+
+	optional := true
+	res, err := m.Resolve(ctx, &Dependency{Name: "opt", Source: SourceGit, URL: "https://example.invalid/opt.git", Optional: &optional})
+	if err != nil || res.LocalPath != "" {
+		t.Errorf("optional dependency should be skipped offline, got %+v, %v", res, err)
+	}
+
+	// Cached dependencies resolve without the network.
+	dep := &Dependency{Name: "cached", Source: SourceGit, URL: "https://example.invalid/cached.git"}
+	cached := m.cachePathForDependency(dep, SourceGit)
+	if err := os.MkdirAll(cached, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	res, err = m.Resolve(ctx, dep)
+	if err != nil || res.LocalPath != cached {
+		t.Errorf("Resolve(cached) = %+v, %v", res, err)
+	}
+}
@@ -0,0 +1,59 @@
+package depresolver
+
+import (
+	"strings"
+)
+
+// Mirror rewrites dependency URLs starting with From to start with To,
+// e.g. to fetch GitHub repositories from an internal mirror.
+type Mirror struct {
+	From string
+	To   string
+}
+
+// WithMirrors makes Resolve fetch git, HTTP and object storage dependencies
+// through the mirror rules. The rule with the longest matching prefix wins;
+// of rules with the same prefix, the first. Go modules are fetched through
+// GOPROXY instead.
+//
+// Cache keys use the original URL, so changing mirrors keeps the cache.
+func (m *Manager) WithMirrors(mirrors []Mirror) *Manager {
+	m.mirrors = nil
+	for _, mirror := range mirrors {
+		mirror.From = strings.TrimSpace(mirror.From)
+		mirror.To = strings.TrimSpace(mirror.To)
+		if mirror.From != "" {
+			m.mirrors = append(m.mirrors, mirror)
+		}
+	}
+	return m
+}
+
+// MirrorURL returns the URL a dependency at url is fetched from. A forced
+// getter prefix such as git:: is kept and not matched against the rules.
+func (m *Manager) MirrorURL(url string) string {
+	url = strings.TrimSpace(url)
+	forced, rest := splitForcedGetter(url)
+
+	var best *Mirror
+	for i := range m.mirrors {
+		mirror := &m.mirrors[i]
+		if strings.HasPrefix(rest, mirror.From) && (best == nil || len(mirror.From) > len(best.From)) {
+			best = mirror
+		}
+	}
+	if best == nil {
+		return url
+	}
+	return forced + best.To + strings.TrimPrefix(rest, best.From)
+}
+
+// splitForcedGetter splits a go-getter forced getter prefix, e.g. git::,
+// from url.
+func splitForcedGetter(url string) (string, string) {
+	idx := strings.Index(url, "::")
+	if idx <= 0 || strings.ContainsAny(url[:idx], "/:") {
+		return "", url
+	}
+	return url[:idx+2], url[idx+2:]
+}
@@ -0,0 +1,83 @@
+package depresolver
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMirrorURL(t *testing.T) {
+	m := NewManager(t.TempDir(), "").WithMirrors([]Mirror{
+		{From: "https://github.com/", To: "https://git.example.com/github/"},
+		{From: "https://github.com/acme/", To: "https://acme.example.com/"},
+		{From: "https://github.com/", To: "https://ignored.example.com/"},
+		{From: " ", To: "https://empty.example.com/"},
+	})
+
+	tests := map[string]string{
+		"https://github.com/foo/bar.git":      "https://git.example.com/github/foo/bar.git",
+		"https://github.com/acme/api.git":     "https://acme.example.com/api.git",
+		"git::https://github.com/foo/bar.git": "git::https://git.example.com/github/foo/bar.git",
+		"https://gitlab.com/foo/bar.git":      "https://gitlab.com/foo/bar.git",
+		"git@github.com:foo/bar.git":          "git@github.com:foo/bar.git",
+	}
+	for url, want := range tests {
+		if got := m.MirrorURL(url); got != want {
+			t.Errorf("MirrorURL(%q) = %q, want %q", url, got, want)
+		}
+	}
+}
+
+func TestBuildGetterURL_Mirror(t *testing.T) {
+	v := "v1.0.0"
+	dep := &Dependency{URL: "https://github.com/foo/bar.git", Version: &v}
+
+	m := NewManager(t.TempDir(), "")
+	key := m.cacheKeyForDependency(dep, SourceGit)
+
+	m.WithMirrors([]Mirror{{From: "https://github.com/", To: "https://git.example.com/github/"}})
+	if got, want := m.buildGetterURL(dep, SourceGit), "git::https://git.example.com/github/foo/bar.git?ref=v1.0.0&depth=1"; got != want {
+		t.Errorf("buildGetterURL() = %q, want %q", got, want)
+	}
+	if got := m.cacheKeyForDependency(dep, SourceGit); got != key {
+		t.Error("mirror rules should not change the cache key")
+	}
+}
+
+func TestResolve_Mirror(t *testing.T) {
+	var requests []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.URL.Path)
+		if !strings.HasPrefix(r.URL.Path, "/mirror/") {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte("syntax = \"proto3\";"))
+	}))
+	defer srv.Close()
+
+	m := NewManager(t.TempDir(), "").WithMirrors([]Mirror{{From: "https://upstream.invalid/", To: srv.URL + "/mirror/"}})
+	dep := &Dependency{Name: "a", Source: SourceHTTP, URL: "https://upstream.invalid/a.proto?filename=a.proto"}
+	if _, err := m.Resolve(context.Background(), dep); err != nil {
+		t.Fatalf("Resolve() = %v", err)
+	}
+	if len(requests) == 0 {
+		t.Error("mirror was not used")
+	}
+
+	m.WithMirrors([]Mirror{{From: "https://missing.invalid/", To: srv.URL + "/missing/"}})
+	_, err := m.Resolve(context.Background(), &Dependency{Name: "b", Source: SourceHTTP, URL: "https://missing.invalid/b.proto?filename=b.proto"})
+	var resolveErr *ResolveError
+	if !errors.As(err, &resolveErr) {
+		t.Fatalf("Resolve() = %v, want ResolveError", err)
+	}
+	if want := srv.URL + "/missing/b.proto?filename=b.proto"; resolveErr.Mirror != want {
+		t.Errorf("ResolveError.Mirror = %q, want %q", resolveErr.Mirror, want)
+	}
+	if !strings.Contains(resolveErr.Error(), "Mirror:") {
+		t.Errorf("error message does not show the mirror:\n%s", resolveErr.Error())
+	}
+}
@@ -0,0 +1,141 @@
+package depresolver
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"golang.org/x/mod/semver"
+)
+
+// WithRefreshTTL makes Resolve check cached dependencies on mutable refs
+// again once they were fetched or checked longer than ttl ago. Zero disables
+// refreshing, so cached content is used forever.
+func (m *Manager) WithRefreshTTL(ttl time.Duration) *Manager {
+	m.refreshTTL = ttl
+	return m
+}
+
+// IsMutable reports whether the content behind a dependency may change
+// upstream: git branches (including the default branch and "latest") and
+// HTTP URLs. Git tags, commits and object storage URLs are immutable.
+func IsMutable(dep *Dependency, source Source) bool {
+	switch source {
+	case SourceGit:
+		version := dependencyVersion(dep)
+		return version == "" || version == "latest" || !(isFullGitCommit(version) || isLikelyGitTag(version))
+	case SourceHTTP:
+		return true
+	default:
+		return false
+	}
+}
+
+// isLikelyGitTag reports whether ref is a full semantic version tag, e.g.
+// v1.2.3 or 1.2.3-rc.1. Shorthands such as v2 or 1.x and other names such as
+// v1-dev are usually moved, so they are refreshed like branches.
+func isLikelyGitTag(ref string) bool {
+	if !strings.HasPrefix(ref, "v") {
+		ref = "v" + ref
+	}
+	version, _, _ := strings.Cut(ref, "+")
+	return semver.IsValid(ref) && semver.Canonical(ref) == version
+}
+
+// isFullGitCommit reports whether ref is a full 40 character commit SHA.
+// Abbreviated SHAs may become ambiguous, and cannot be told from branch
+// names such as "deadbeef".
+func isFullGitCommit(ref string) bool {
+	return len(ref) == 40 && isLikelyGitCommit(ref)
+}
+
+// httpValidators are the HTTP cache validators of a downloaded archive.
+type httpValidators struct {
+	ETag         string
+	LastModified string
+}
+
+// fetchHTTPValidators returns the ETag and Last-Modified headers of url.
+func fetchHTTPValidators(ctx context.Context, url string) (httpValidators, error) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, httpCheckURL(url), nil)
+	if err != nil {
+		return httpValidators{}, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return httpValidators{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		return httpValidators{}, fmt.Errorf("HEAD %s: %s", url, resp.Status)
+	}
+	return httpValidators{
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+	}, nil
+}
+
+// httpCheckURL strips go-getter syntax from an HTTP dependency URL: the
+// forced getter prefix, a //subdir and the getter query parameters.
+func httpCheckURL(url string) string {
+	base, query, _ := strings.Cut(strings.TrimPrefix(url, "http::"), "?")
+	if scheme, rest, ok := strings.Cut(base, "://"); ok {
+		if path, _, ok := strings.Cut(rest, "//"); ok {
+			base = scheme + "://" + path
+		}
+	}
+
+	var kept []string
+	for _, param := range strings.Split(query, "&") {
+		switch key, _, _ := strings.Cut(param, "="); key {
+		case "", "archive", "checksum", "filename":
+		default:
+			kept = append(kept, param)
+		}
+	}
+	if len(kept) == 0 {
+		return base
+	}
+	return base + "?" + strings.Join(kept, "&")
+}
+
+// isStale reports whether the cached content of dep at dir should be
+// downloaded again. HTTP archives with validators are only downloaded again
+// if their ETag or Last-Modified changed; if the check fails, the cached
+// content is kept.
+func (m *Manager) isStale(ctx context.Context, dep *Dependency, source Source, dir string) bool {
+	if m.offline || m.refreshTTL <= 0 || !IsMutable(dep, source) {
+		return false
+	}
+
+	entry, err := m.readCacheEntry(dir)
+	if err != nil {
+		return false
+	}
+	checked := entry.Checked
+	if checked.IsZero() {
+		checked = entry.Created
+	}
+	if time.Since(checked) < m.refreshTTL {
+		return false
+	}
+
+	if source == SourceHTTP && (entry.ETag != "" || entry.LastModified != "") {
+		validators, err := fetchHTTPValidators(ctx, m.MirrorURL(dep.URL))
+		if err != nil {
+			return false
+		}
+		if validators.ETag == entry.ETag && validators.LastModified == entry.LastModified {
+			entry.Checked = time.Now()
+			_ = writeCacheEntry(entry)
+			return false
+		}
+	}
+	return true
+}
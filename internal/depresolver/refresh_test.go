@@ -0,0 +1,170 @@
+package depresolver
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestIsMutable(t *testing.T) {
+	tests := []struct {
+		source  Source
+		version string
+		want    bool
+	}{
+		{SourceGit, "", true},
+		{SourceGit, "main", true},
+		{SourceGit, "latest", true},
+		{SourceGit, "v1.2.3", false},
+		{SourceGit, "1.2.3", false},
+		{SourceGit, "v1.2.3-rc.1", false},
+		{SourceGit, "v1.2.3+build.1", false},
+		{SourceGit, "v2", true},
+		{SourceGit, "1.0", true},
+		{SourceGit, "1.x", true},
+		{SourceGit, "v1-dev", true},
+		{SourceGit, "a1b2c3d4e5f6a1b2c3d4e5f6a1b2c3d4e5f6a1b2", false},
+		{SourceGit, "a1b2c3d4e5f6", true},
+		{SourceHTTP, "", true},
+		{SourceS3, "", false},
+		{SourceGoMod, "", false},
+	}
+	for _, tt := range tests {
+		dep := &Dependency{URL: "example.com/protos"}
+		if tt.version != "" {
+			dep.Version = strPtr(tt.version)
+		}
+		if got := IsMutable(dep, tt.source); got != tt.want {
+			t.Errorf("IsMutable(%s, %q) = %v, want %v", tt.source, tt.version, got, tt.want)
+		}
+	}
+}
+
+func TestHTTPCheckURL(t *testing.T) {
+	tests := map[string]string{
+		"https://example.com/a.tar.gz":                          "https://example.com/a.tar.gz",
+		"http::https://example.com/a.tar.gz":                    "https://example.com/a.tar.gz",
+		"https://example.com/a.tar.gz//api":                     "https://example.com/a.tar.gz",
+		"https://example.com/download?id=1&archive=zip":         "https://example.com/download?id=1",
+		"https://example.com/a.zip//api?checksum=sha256:00&x=1": "https://example.com/a.zip?x=1",
+	}
+	for in, want := range tests {
+		if got := httpCheckURL(in); got != want {
+			t.Errorf("httpCheckURL(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestIsStale(t *testing.T) {
+	etag := `"v1"`
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", etag)
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	m := NewManager(t.TempDir(), "").WithRefreshTTL(time.Hour)
+	dep := &Dependency{Source: SourceHTTP, URL: srv.URL + "/protos.tar.gz"}
+	dir := m.cachePathForDependency(dep, SourceHTTP)
+	entry := addCacheEntry(t, m, dep, 10, time.Now())
+	entry.ETag = etag
+	if err := writeCacheEntry(entry); err != nil {
+		t.Fatal(err)
+	}
+
+	if m.isStale(ctx, dep, SourceHTTP, dir) {
+		t.Error("entry checked within the TTL should not be stale")
+	}
+
+	entry.Checked = time.Now().Add(-2 * time.Hour)
+	if err := writeCacheEntry(entry); err != nil {
+		t.Fatal(err)
+	}
+	if m.isStale(ctx, dep, SourceHTTP, dir) {
+		t.Error("entry with unchanged ETag should not be stale")
+	}
+	if e, _ := m.readCacheEntry(dir); time.Since(e.Checked) > time.Minute {
+		t.Errorf("Checked not updated: %v", e.Checked)
+	}
+
+	entry.Checked = time.Now().Add(-2 * time.Hour)
+	if err := writeCacheEntry(entry); err != nil {
+		t.Fatal(err)
+	}
+	etag = `"v2"`
+	if !m.isStale(ctx, dep, SourceHTTP, dir) {
+		t.Error("entry with changed ETag should be stale")
+	}
+
+	if m.WithRefreshTTL(0).isStale(ctx, dep, SourceHTTP, dir) {
+		t.Error("entries should never be stale without a TTL")
+	}
+}
+
+func TestFetchHTTPValidators(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodHead || r.URL.RawQuery != "" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL)
+		}
+		w.Header().Set("Last-Modified", "Mon, 02 Jan 2006 15:04:05 GMT")
+	}))
+	defer srv.Close()
+
+	v, err := fetchHTTPValidators(context.Background(), srv.URL+"/a.tar.gz?archive=tar.gz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.LastModified != "Mon, 02 Jan 2006 15:04:05 GMT" || v.ETag != "" {
+		t.Errorf("fetchHTTPValidators() = %+v", v)
+	}
+}
+
+func TestResolveRefreshesChangedHTTPArchive(t *testing.T) {
+	content := "v1"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"`+content+`"`)
+		_, _ = w.Write([]byte(content))
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	m := NewManager(t.TempDir(), "").WithRefreshTTL(time.Hour)
+	dep := &Dependency{Name: "a", Source: SourceHTTP, URL: srv.URL + "/a.proto?filename=a.proto"}
+	read := func() string {
+		t.Helper()
+		res, err := m.Resolve(ctx, dep)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := os.ReadFile(filepath.Join(res.LocalPath, "a.proto"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(data)
+	}
+
+	if got := read(); got != "v1" {
+		t.Fatalf("content = %q, want v1", got)
+	}
+
+	content = "v2"
+	if got := read(); got != "v1" {
+		t.Errorf("content refreshed within the TTL: %q", got)
+	}
+
+	entry, err := m.readCacheEntry(m.cachePathForDependency(dep, SourceHTTP))
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry.Checked = time.Now().Add(-2 * time.Hour)
+	if err := writeCacheEntry(entry); err != nil {
+		t.Fatal(err)
+	}
+	if got := read(); got != "v2" {
+		t.Errorf("content = %q, want v2 after the TTL", got)
+	}
+}
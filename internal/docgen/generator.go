@@ -0,0 +1,171 @@
+// Package docgen renders API documentation for proto files into Markdown or
+// HTML with pongo2 templates. It runs as the builtin-doc plugin of
+// protobuild, an in-process alternative to protoc-gen-doc that accepts the
+// same plugin parameters for its formats.
+package docgen
+
+import (
+	"embed"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/pluginpb"
+
+	"github.com/pubgo/protobuild/internal/protoutil"
+)
+
+//go:embed templates/*
+var templateFS embed.FS
+
+// formats are the builtin templates and their default output filenames.
+var formats = map[string]struct{ template, filename string }{
+	"markdown": {"templates/markdown.tmpl", "index.md"},
+	"md":       {"templates/markdown.tmpl", "index.md"},
+	"html":     {"templates/html.tmpl", "index.html"},
+}
+
+// unsupportedFormats are protoc-gen-doc formats without a builtin template.
+var unsupportedFormats = map[string]bool{"json": true, "docbook": true}
+
+// Options are the plugin parameters, in the protoc-gen-doc form
+// <FORMAT>|<TEMPLATE_FILE>,<OUT_FILE>[,default|source_relative].
+type Options struct {
+	// Template is the template source.
+	Template string
+	// Filename of the rendered document.
+	Filename string
+	// SourceRelative renders one document per proto directory instead of a
+	// single document for all files.
+	SourceRelative bool
+}
+
+// ParseOptions parses the plugin parameters. An empty parameter renders
+// Markdown to index.md. A format that is not builtin is read as a template
+// file.
+func ParseOptions(param string) (Options, error) {
+	var opts Options
+	parts := strings.Split(param, ",")
+	for i := range parts {
+		parts[i] = strings.TrimSpace(parts[i])
+	}
+
+	format := parts[0]
+	if format == "" {
+		format = "markdown"
+	}
+	if unsupportedFormats[format] {
+		return opts, fmt.Errorf("format %s is not supported, use protoc-gen-doc (plugin doc) for it", format)
+	}
+	if f, ok := formats[format]; ok {
+		data, err := templateFS.ReadFile(f.template)
+		if err != nil {
+			return opts, err
+		}
+		opts.Template = string(data)
+		opts.Filename = f.filename
+	} else {
+		data, err := os.ReadFile(format)
+		if err != nil {
+			return opts, fmt.Errorf("read template %s: %w", format, err)
+		}
+		opts.Template = string(data)
+	}
+
+	if len(parts) > 1 && parts[1] != "" {
+		opts.Filename = parts[1]
+	}
+	if opts.Filename == "" {
+		return opts, fmt.Errorf("output filename is required with template %s", format)
+	}
+
+	if len(parts) > 2 {
+		switch parts[2] {
+		case "", "default":
+		case "source_relative":
+			opts.SourceRelative = true
+		default:
+			return opts, fmt.Errorf("unknown paths mode %q, expected default or source_relative", parts[2])
+		}
+	}
+	if len(parts) > 3 {
+		return opts, fmt.Errorf("too many parameters in %q", param)
+	}
+	return opts, nil
+}
+
+// Generate renders documentation for the files to generate in req.
+func Generate(req *pluginpb.CodeGeneratorRequest) (*pluginpb.CodeGeneratorResponse, error) {
+	opts, err := ParseOptions(req.GetParameter())
+	if err != nil {
+		return nil, err
+	}
+
+	registry, err := protodesc.NewFiles(&descriptorpb.FileDescriptorSet{File: req.GetProtoFile()})
+	if err != nil {
+		return nil, err
+	}
+
+	groups := make(map[string][]*File)
+	for _, name := range req.GetFileToGenerate() {
+		fd, err := registry.FindFileByPath(name)
+		if err != nil {
+			return nil, err
+		}
+		file, err := newFile(fd)
+		if err != nil {
+			return nil, err
+		}
+
+		dir := ""
+		if opts.SourceRelative {
+			dir = path.Dir(name)
+		}
+		groups[dir] = append(groups[dir], file)
+	}
+
+	dirs := make([]string, 0, len(groups))
+	for dir := range groups {
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+
+	resp := &pluginpb.CodeGeneratorResponse{
+		SupportedFeatures: proto.Uint64(uint64(pluginpb.CodeGeneratorResponse_FEATURE_PROTO3_OPTIONAL)),
+	}
+	for _, dir := range dirs {
+		content, err := Render(opts.Template, groups[dir])
+		if err != nil {
+			return nil, err
+		}
+		resp.File = append(resp.File, &pluginpb.CodeGeneratorResponse_File{
+			Name:    proto.String(path.Join(dir, opts.Filename)),
+			Content: proto.String(content),
+		})
+	}
+	return resp, nil
+}
+
+// Render executes tpl with the files and their packages. Templates see
+// files, packages and the oneline function, which joins a multi-line
+// description for table cells.
+func Render(tpl string, files []*File) (string, error) {
+	content, err := protoutil.RenderTemplate(tpl, protoutil.Context{
+		"files":    files,
+		"packages": packages(files),
+		"oneline":  oneline,
+	})
+	if err != nil {
+		return "", fmt.Errorf("render template: %w", err)
+	}
+	return content + "\n", nil
+}
+
+func oneline(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
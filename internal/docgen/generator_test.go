@@ -0,0 +1,199 @@
+package docgen
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bufbuild/protocompile"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/pluginpb"
+
+	_ "google.golang.org/genproto/googleapis/api/annotations"
+)
+
+var sources = map[string]string{
+	"acme/library/v1/library.proto": `// Library API.
+syntax = "proto3";
+
+package acme.library.v1;
+
+import "google/api/annotations.proto";
+import "acme/library/v1/book.proto";
+
+// Library manages books.
+service Library {
+  // Gets a book.
+  rpc GetBook(GetBookRequest) returns (Book) {
+    option (google.api.http) = {
+      get: "/v1/{name=books/*}"
+      additional_bindings {get: "/v1/{name=shelves/*/books/*}"}
+    };
+  }
+
+  rpc WatchBooks(GetBookRequest) returns (stream Book) {
+    option deprecated = true;
+  }
+}
+
+message GetBookRequest {
+  // The book name,
+  // e.g. books/1.
+  string name = 1;
+}
+`,
+	"acme/library/v1/book.proto": `syntax = "proto3";
+
+package acme.library.v1;
+
+// A book.
+message Book {
+  string name = 1;
+  repeated string authors = 2;
+  map<string, Genre> genres = 3;
+  optional int32 pages = 4 [deprecated = true];
+
+  message Review {
+    string text = 1;
+  }
+  Review review = 5;
+}
+
+enum Genre {
+  // Unknown genre.
+  GENRE_UNSPECIFIED = 0;
+  FICTION = 1;
+}
+`,
+	"acme/shop/v1/shop.proto": `syntax = "proto3";
+
+package acme.shop.v1;
+
+message Order {
+  string id = 1;
+}
+`,
+}
+
+func request(t *testing.T, param string) *pluginpb.CodeGeneratorRequest {
+	t.Helper()
+
+	compiler := protocompile.Compiler{
+		Resolver: protocompile.CompositeResolver{
+			&protocompile.SourceResolver{Accessor: protocompile.SourceAccessorFromMap(sources)},
+			protocompile.ResolverFunc(func(path string) (protocompile.SearchResult, error) {
+				fd, err := protoregistry.GlobalFiles.FindFileByPath(path)
+				if err != nil {
+					return protocompile.SearchResult{}, err
+				}
+				return protocompile.SearchResult{Desc: fd}, nil
+			}),
+		},
+		SourceInfoMode: protocompile.SourceInfoStandard,
+	}
+	names := []string{"acme/library/v1/library.proto", "acme/library/v1/book.proto", "acme/shop/v1/shop.proto"}
+	files, err := compiler.Compile(context.Background(), names...)
+	if err != nil {
+		t.Fatalf("compile failed: %v", err)
+	}
+
+	req := &pluginpb.CodeGeneratorRequest{FileToGenerate: names, Parameter: proto.String(param)}
+	seen := make(map[string]bool)
+	var add func(fd protoreflect.FileDescriptor)
+	add = func(fd protoreflect.FileDescriptor) {
+		if seen[fd.Path()] {
+			return
+		}
+		seen[fd.Path()] = true
+		for i := 0; i < fd.Imports().Len(); i++ {
+			add(fd.Imports().Get(i).FileDescriptor)
+		}
+		req.ProtoFile = append(req.ProtoFile, protodesc.ToFileDescriptorProto(fd))
+	}
+	for _, fd := range files {
+		add(fd)
+	}
+
+	// Round-trip so options are decoded with the registered extensions.
+	data, err := proto.Marshal(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req = &pluginpb.CodeGeneratorRequest{}
+	if err := proto.Unmarshal(data, req); err != nil {
+		t.Fatal(err)
+	}
+	return req
+}
+
+func TestGenerate_Markdown(t *testing.T) {
+	resp, err := Generate(request(t, ""))
+	if err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+	if len(resp.File) != 1 || resp.File[0].GetName() != "index.md" {
+		t.Fatalf("unexpected files: %v", resp.File)
+	}
+
+	content := resp.File[0].GetContent()
+	for _, want := range []string{
+		"## acme.library.v1\n\nLibrary API.\n",
+		"## acme.shop.v1\n",
+		"### Library\n\nLibrary manages books.\n",
+		"| GetBook | [GetBookRequest](#acme.library.v1.GetBookRequest) | [Book](#acme.library.v1.Book) | `GET /v1/{name=books/*}`<br>`GET /v1/{name=shelves/*/books/*}` | Gets a book. |",
+		"| WatchBooks (deprecated) | [GetBookRequest](#acme.library.v1.GetBookRequest) | stream [Book](#acme.library.v1.Book) |  |  |",
+		"| name | string |  | The book name, e.g. books/1. |",
+		"| authors | string | repeated |  |",
+		"| genres | [map<string, Genre>](#acme.library.v1.Genre) |  |  |",
+		"| pages | int32 | optional |  (deprecated) |",
+		"| review | [Book.Review](#acme.library.v1.Book.Review) |  |  |",
+		"### Book.Review\n",
+		"| GENRE_UNSPECIFIED | 0 | Unknown genre. |",
+		"  - [Genre](#acme.library.v1.Genre)\n",
+	} {
+		if !strings.Contains(content, want) {
+			t.Errorf("output does not contain %q:\n%s", want, content)
+		}
+	}
+}
+
+func TestGenerate_Options(t *testing.T) {
+	resp, err := Generate(request(t, "html,api.html,source_relative"))
+	if err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+	var names []string
+	for _, f := range resp.File {
+		names = append(names, f.GetName())
+	}
+	if strings.Join(names, ",") != "acme/library/v1/api.html,acme/shop/v1/api.html" {
+		t.Fatalf("unexpected files: %v", names)
+	}
+	if content := resp.File[0].GetContent(); !strings.Contains(content, `<h3 id="acme.library.v1.Book">Book</h3>`) ||
+		!strings.Contains(content, "map&lt;string, Genre&gt;") {
+		t.Errorf("unexpected html:\n%s", content)
+	}
+
+	tpl := filepath.Join(t.TempDir(), "custom.tmpl")
+	if err := os.WriteFile(tpl, []byte("{% for pkg in packages %}{{ pkg.Name }}:{{ pkg.Messages|length }} {% endfor %}"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	resp, err = Generate(request(t, tpl+",custom.txt"))
+	if err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+	if got := resp.File[0].GetContent(); got != "acme.library.v1:3 acme.shop.v1:1 \n" {
+		t.Errorf("unexpected custom output %q", got)
+	}
+
+	for _, param := range []string{tpl, "markdown,index.md,flat", "missing.tmpl,out.md", "json,doc.json", "docbook,doc.xml"} {
+		if _, err := Generate(request(t, param)); err == nil {
+			t.Errorf("expected error for %q", param)
+		}
+	}
+}
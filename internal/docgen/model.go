@@ -0,0 +1,292 @@
+package docgen
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"google.golang.org/genproto/googleapis/api/annotations"
+	"google.golang.org/protobuf/reflect/protoreflect"
+
+	"github.com/pubgo/protobuild/internal/protoutil"
+)
+
+// The template model. Field names are what templates refer to, e.g.
+// {{ message.LongName }}; keep them stable, user templates depend on them.
+
+type Package struct {
+	Name     string
+	Files    []string
+	Services []*Service
+	Messages []*Message
+	Enums    []*Enum
+}
+
+type File struct {
+	Name        string
+	Package     string
+	Description string
+	Services    []*Service
+	Messages    []*Message
+	Enums       []*Enum
+}
+
+type Service struct {
+	Name        string
+	FullName    string
+	Description string
+	Methods     []*Method
+}
+
+type Method struct {
+	Name              string
+	Description       string
+	RequestType       string
+	RequestFullType   string
+	RequestStreaming  bool
+	ResponseType      string
+	ResponseFullType  string
+	ResponseStreaming bool
+	Deprecated        bool
+	HTTPRules         []*HTTPRule
+}
+
+// HTTPRule is a google.api.http binding of a method.
+type HTTPRule struct {
+	Method string
+	Path   string
+	Body   string
+}
+
+type Message struct {
+	Name        string
+	LongName    string
+	FullName    string
+	Description string
+	Deprecated  bool
+	Fields      []*Field
+}
+
+type Field struct {
+	Name        string
+	JSONName    string
+	Number      int
+	Description string
+	// Label is repeated, optional or empty.
+	Label string
+	// Type is the scalar type, the long name of a message or enum, or
+	// map<K, V>. FullType is the full name of the message or enum type, for
+	// linking; it is empty for scalars.
+	Type       string
+	FullType   string
+	Deprecated bool
+}
+
+type Enum struct {
+	Name        string
+	LongName    string
+	FullName    string
+	Description string
+	Values      []*EnumValue
+}
+
+type EnumValue struct {
+	Name        string
+	Number      int
+	Description string
+}
+
+func newFile(fd protoreflect.FileDescriptor) (*File, error) {
+	// The file description is the comment on the syntax statement, or on the
+	// package statement.
+	description := comments(fd.SourceLocations().ByPath(protoreflect.SourcePath{12}))
+	if description == "" {
+		description = comments(fd.SourceLocations().ByPath(protoreflect.SourcePath{2}))
+	}
+	file := &File{
+		Name:        fd.Path(),
+		Package:     string(fd.Package()),
+		Description: description,
+	}
+	for i := 0; i < fd.Services().Len(); i++ {
+		svc, err := newService(fd.Services().Get(i))
+		if err != nil {
+			return nil, err
+		}
+		file.Services = append(file.Services, svc)
+	}
+	file.addMessages(fd.Messages())
+	file.addEnums(fd.Enums())
+	return file, nil
+}
+
+// addMessages adds msgs and their nested messages and enums, flattened.
+func (f *File) addMessages(msgs protoreflect.MessageDescriptors) {
+	for i := 0; i < msgs.Len(); i++ {
+		md := msgs.Get(i)
+		if md.IsMapEntry() {
+			continue
+		}
+		f.Messages = append(f.Messages, newMessage(md))
+		f.addMessages(md.Messages())
+		f.addEnums(md.Enums())
+	}
+}
+
+func (f *File) addEnums(enums protoreflect.EnumDescriptors) {
+	for i := 0; i < enums.Len(); i++ {
+		f.Enums = append(f.Enums, newEnum(enums.Get(i)))
+	}
+}
+
+func newService(sd protoreflect.ServiceDescriptor) (*Service, error) {
+	svc := &Service{
+		Name:        string(sd.Name()),
+		FullName:    string(sd.FullName()),
+		Description: descriptorComments(sd),
+	}
+	for i := 0; i < sd.Methods().Len(); i++ {
+		md := sd.Methods().Get(i)
+		method := &Method{
+			Name:              string(md.Name()),
+			Description:       descriptorComments(md),
+			RequestType:       longName(md.Input()),
+			RequestFullType:   string(md.Input().FullName()),
+			RequestStreaming:  md.IsStreamingClient(),
+			ResponseType:      longName(md.Output()),
+			ResponseFullType:  string(md.Output().FullName()),
+			ResponseStreaming: md.IsStreamingServer(),
+			Deprecated:        deprecated(md),
+		}
+
+		rule, err := protoutil.ExtractAPIOptions(md)
+		if err != nil {
+			return nil, err
+		}
+		if rule != nil {
+			for _, r := range append([]*annotations.HttpRule{rule}, rule.GetAdditionalBindings()...) {
+				verb, path := protoutil.ExtractHttpMethod(r)
+				method.HTTPRules = append(method.HTTPRules, &HTTPRule{Method: verb, Path: path, Body: r.GetBody()})
+			}
+		}
+		svc.Methods = append(svc.Methods, method)
+	}
+	return svc, nil
+}
+
+func newMessage(md protoreflect.MessageDescriptor) *Message {
+	msg := &Message{
+		Name:        string(md.Name()),
+		LongName:    longName(md),
+		FullName:    string(md.FullName()),
+		Description: descriptorComments(md),
+		Deprecated:  deprecated(md),
+	}
+	for i := 0; i < md.Fields().Len(); i++ {
+		fd := md.Fields().Get(i)
+		field := &Field{
+			Name:        string(fd.Name()),
+			JSONName:    fd.JSONName(),
+			Number:      int(fd.Number()),
+			Description: descriptorComments(fd),
+			Deprecated:  deprecated(fd),
+		}
+		switch {
+		case fd.IsMap():
+			value, fullType := fieldType(fd.MapValue())
+			field.Type = fmt.Sprintf("map<%s, %s>", fd.MapKey().Kind(), value)
+			field.FullType = fullType
+		case fd.IsList():
+			field.Label = "repeated"
+			field.Type, field.FullType = fieldType(fd)
+		default:
+			if fd.HasOptionalKeyword() {
+				field.Label = "optional"
+			}
+			field.Type, field.FullType = fieldType(fd)
+		}
+		msg.Fields = append(msg.Fields, field)
+	}
+	return msg
+}
+
+func newEnum(ed protoreflect.EnumDescriptor) *Enum {
+	enum := &Enum{
+		Name:        string(ed.Name()),
+		LongName:    longName(ed),
+		FullName:    string(ed.FullName()),
+		Description: descriptorComments(ed),
+	}
+	for i := 0; i < ed.Values().Len(); i++ {
+		vd := ed.Values().Get(i)
+		enum.Values = append(enum.Values, &EnumValue{
+			Name:        string(vd.Name()),
+			Number:      int(vd.Number()),
+			Description: descriptorComments(vd),
+		})
+	}
+	return enum
+}
+
+// packages groups files by package, sorted by name.
+func packages(files []*File) []*Package {
+	index := make(map[string]*Package)
+	var pkgs []*Package
+	for _, f := range files {
+		pkg, ok := index[f.Package]
+		if !ok {
+			pkg = &Package{Name: f.Package}
+			index[f.Package] = pkg
+			pkgs = append(pkgs, pkg)
+		}
+		pkg.Files = append(pkg.Files, f.Name)
+		pkg.Services = append(pkg.Services, f.Services...)
+		pkg.Messages = append(pkg.Messages, f.Messages...)
+		pkg.Enums = append(pkg.Enums, f.Enums...)
+	}
+	sort.Slice(pkgs, func(i, j int) bool { return pkgs[i].Name < pkgs[j].Name })
+	return pkgs
+}
+
+func fieldType(fd protoreflect.FieldDescriptor) (typ, fullType string) {
+	switch {
+	case fd.Message() != nil:
+		return longName(fd.Message()), string(fd.Message().FullName())
+	case fd.Enum() != nil:
+		return longName(fd.Enum()), string(fd.Enum().FullName())
+	default:
+		return fd.Kind().String(), ""
+	}
+}
+
+// longName is the name relative to the package, e.g. Outer.Inner.
+func longName(d protoreflect.Descriptor) string {
+	name := string(d.FullName())
+	if pkg := string(d.ParentFile().Package()); pkg != "" {
+		name = strings.TrimPrefix(name, pkg+".")
+	}
+	return name
+}
+
+func deprecated(d protoreflect.Descriptor) bool {
+	opts, ok := d.Options().(interface{ GetDeprecated() bool })
+	return ok && opts.GetDeprecated()
+}
+
+func descriptorComments(d protoreflect.Descriptor) string {
+	return comments(d.ParentFile().SourceLocations().ByDescriptor(d))
+}
+
+// comments returns the leading comments of loc, or its trailing comments.
+func comments(loc protoreflect.SourceLocation) string {
+	text := loc.LeadingComments
+	if strings.TrimSpace(text) == "" {
+		text = loc.TrailingComments
+	}
+
+	lines := strings.Split(strings.TrimSpace(text), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimSpace(line)
+	}
+	return strings.Join(lines, "\n")
+}
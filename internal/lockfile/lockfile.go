@@ -0,0 +1,67 @@
+// Package lockfile provides exclusive file locks between processes.
+package lockfile
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// ErrTimeout reports that a lock was not acquired in time.
+var ErrTimeout = errors.New("timed out waiting for lock")
+
+// errLocked reports that another process holds the lock.
+var errLocked = errors.New("locked")
+
+const pollInterval = 100 * time.Millisecond
+
+// Lock is an exclusive lock on a file. The operating system releases it
+// when the process exits, so a crashed holder never leaves a stale lock.
+type Lock struct {
+	f *os.File
+}
+
+// Acquire locks the file at path, creating it and its directory if needed.
+// If another process holds the lock, onWait, if set, is called once and
+// Acquire waits up to timeout for it to be released.
+func Acquire(path string, timeout time.Duration, onWait func()) (*Lock, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0o644)
+	if err != nil {
+		return nil, err
+	}
+
+	deadline := time.Now().Add(timeout)
+	for waited := false; ; waited = true {
+		err := tryLock(f)
+		if err == nil {
+			return &Lock{f: f}, nil
+		}
+		if !errors.Is(err, errLocked) {
+			_ = f.Close()
+			return nil, fmt.Errorf("lock %s: %w", path, err)
+		}
+		if time.Now().After(deadline) {
+			_ = f.Close()
+			return nil, fmt.Errorf("%w: %s", ErrTimeout, path)
+		}
+		if !waited && onWait != nil {
+			onWait()
+		}
+		time.Sleep(pollInterval)
+	}
+}
+
+// Unlock releases the lock. The lock file is kept, as removing it would let
+// a waiting process and a new one lock different files.
+func (l *Lock) Unlock() error {
+	if err := unlock(l.f); err != nil {
+		_ = l.f.Close()
+		return err
+	}
+	return l.f.Close()
+}
@@ -0,0 +1,39 @@
+package lockfile
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestAcquire(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "locks", "vendor.lock")
+
+	lock, err := Acquire(path, time.Second, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	waited := false
+	_, err = Acquire(path, 200*time.Millisecond, func() { waited = true })
+	if !errors.Is(err, ErrTimeout) || !waited {
+		t.Fatalf("second Acquire() = %v (waited %v), want timeout after waiting", err, waited)
+	}
+
+	released := make(chan struct{})
+	go func() {
+		time.Sleep(200 * time.Millisecond)
+		_ = lock.Unlock()
+		close(released)
+	}()
+
+	lock2, err := Acquire(path, 5*time.Second, nil)
+	if err != nil {
+		t.Fatalf("Acquire() after release = %v", err)
+	}
+	<-released
+	if err := lock2.Unlock(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -37,6 +37,8 @@ func GoModPath() string {
 }
 
 // LoadVersionGraph loads the module version graph from 'go mod graph'.
+//
+// Deprecated: use LoadWorkspace, which does not run the go command.
 func LoadVersionGraph() map[string]string {
 	modList := strings.Split(result.Wrap(shutil.GoModGraph()).Unwrap(), "\n")
 	modSet := mapset.NewSet[string]()
@@ -67,6 +69,9 @@ func LoadVersionGraph() map[string]string {
 }
 
 // LoadVersions loads module versions from go.mod file.
+//
+// Deprecated: use LoadWorkspace, which also reads go.work and does not
+// panic without a go.mod file.
 func LoadVersions() map[string]string {
 	path := GoModPath()
 	assert.Assert(path == "", "go.mod not exists")
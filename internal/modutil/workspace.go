@@ -0,0 +1,198 @@
+package modutil
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/semver"
+)
+
+// Module is a module known from go.mod and go.work files.
+type Module struct {
+	// Path is the module path to fetch, the replacement for a module
+	// replaced by another module.
+	Path    string
+	Version string
+	// Dir is the directory of a workspace module or of a replacement by a
+	// local directory; Version is empty then.
+	Dir string
+}
+
+// Workspace holds the module requirements of the main module, or of all
+// modules of a go.work workspace, read without running the go command.
+type Workspace struct {
+	// Root is the directory of go.work or go.mod.
+	Root string
+
+	modules map[string]Module
+	sums    map[string]string // "path version" -> h1: hash
+}
+
+// LoadWorkspace loads the workspace of dir: the go.work file found from dir
+// upwards, or $GOWORK, and otherwise the go.mod file. GOWORK=off disables
+// workspaces. Without a go.mod file, the workspace is empty.
+func LoadWorkspace(dir string) (*Workspace, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	ws := &Workspace{modules: make(map[string]Module), sums: make(map[string]string)}
+	if path := goWorkPath(dir); path != "" {
+		return ws, ws.loadWork(path)
+	}
+	if path := getFileByRecursion("go.mod", dir); path != "" {
+		ws.Root = filepath.Dir(path)
+		mod, err := parseModFile(path)
+		if err != nil {
+			return nil, err
+		}
+		ws.addRequires(mod)
+		ws.addReplaces(mod.Replace, ws.Root)
+		return ws, ws.loadSums(filepath.Join(ws.Root, "go.sum"))
+	}
+	return ws, nil
+}
+
+// Lookup returns the module for path: a workspace module, the required
+// version, or the replacement of path.
+func (w *Workspace) Lookup(path string) (Module, bool) {
+	mod, ok := w.modules[path]
+	return mod, ok
+}
+
+// Versions returns the required version of each module.
+func (w *Workspace) Versions() map[string]string {
+	versions := make(map[string]string, len(w.modules))
+	for path, mod := range w.modules {
+		if mod.Version != "" {
+			versions[path] = mod.Version
+		}
+	}
+	return versions
+}
+
+// Sum returns the h1: hash of the module zip of path@version in the go.sum
+// files, or "".
+func (w *Workspace) Sum(path, version string) string {
+	return w.sums[path+" "+version]
+}
+
+func goWorkPath(dir string) string {
+	switch gowork := os.Getenv("GOWORK"); gowork {
+	case "off":
+		return ""
+	case "":
+		return getFileByRecursion("go.work", dir)
+	default:
+		return gowork
+	}
+}
+
+// loadWork loads a go.work file and the go.mod files of its modules. The
+// highest required version of a module wins, like minimal version selection
+// picks it; go.work replacements override those of the modules.
+func (w *Workspace) loadWork(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	work, err := modfile.ParseWork(path, data, nil)
+	if err != nil {
+		return err
+	}
+	w.Root = filepath.Dir(path)
+
+	var uses []Module
+	for _, use := range work.Use {
+		dir := localDir(w.Root, use.Path)
+		mod, err := parseModFile(filepath.Join(dir, "go.mod"))
+		if err != nil {
+			return err
+		}
+		w.addRequires(mod)
+		w.addReplaces(mod.Replace, dir)
+		if err := w.loadSums(filepath.Join(dir, "go.sum")); err != nil {
+			return err
+		}
+		if mod.Module != nil {
+			uses = append(uses, Module{Path: mod.Module.Mod.Path, Dir: dir})
+		}
+	}
+	w.addReplaces(work.Replace, w.Root)
+	for _, use := range uses {
+		w.modules[use.Path] = use
+	}
+	return w.loadSums(filepath.Join(w.Root, "go.work.sum"))
+}
+
+func (w *Workspace) addRequires(mod *modfile.File) {
+	for _, req := range mod.Require {
+		cur, ok := w.modules[req.Mod.Path]
+		if !ok || semver.Compare(req.Mod.Version, cur.Version) > 0 {
+			w.modules[req.Mod.Path] = Module{Path: req.Mod.Path, Version: req.Mod.Version}
+		}
+	}
+}
+
+// addReplaces applies replace directives of a file in dir. A replacement
+// of a specific version only applies if that version is required.
+func (w *Workspace) addReplaces(replaces []*modfile.Replace, dir string) {
+	for _, rep := range replaces {
+		if rep.Old.Version != "" && w.modules[rep.Old.Path].Version != rep.Old.Version {
+			continue
+		}
+		if rep.New.Version == "" {
+			w.modules[rep.Old.Path] = Module{Path: rep.Old.Path, Dir: localDir(dir, rep.New.Path)}
+			continue
+		}
+		w.modules[rep.Old.Path] = Module{Path: rep.New.Path, Version: rep.New.Version}
+	}
+}
+
+// loadSums reads the module zip hashes of a go.sum file, if it exists.
+func (w *Workspace) loadSums(path string) error {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 3 || strings.HasSuffix(fields[1], "/go.mod") {
+			continue
+		}
+		w.sums[fields[0]+" "+fields[1]] = fields[2]
+	}
+	return scanner.Err()
+}
+
+func parseModFile(path string) (*modfile.File, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	mod, err := modfile.Parse(path, data, nil)
+	if err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	return mod, nil
+}
+
+// localDir returns the directory of a go.mod or go.work file path, which
+// is relative to dir.
+func localDir(dir, path string) string {
+	if filepath.IsAbs(path) {
+		return filepath.Clean(path)
+	}
+	return filepath.Join(dir, filepath.FromSlash(path))
+}
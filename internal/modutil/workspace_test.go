@@ -0,0 +1,102 @@
+package modutil
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeFiles(t *testing.T, files map[string]string) {
+	t.Helper()
+	for path, content := range files {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestLoadWorkspace_GoMod(t *testing.T) {
+	t.Setenv("GOWORK", "off")
+	dir := t.TempDir()
+	writeFiles(t, map[string]string{
+		filepath.Join(dir, "go.mod"): `module example.com/app
+
+require (
+	example.com/a v1.0.0
+	example.com/b v1.2.0
+	example.com/c v0.1.0
+)
+
+replace example.com/b => ../b
+
+replace example.com/c v0.1.0 => example.com/fork/c v0.2.0
+
+replace example.com/a v0.9.0 => ./ignored
+`,
+		filepath.Join(dir, "go.sum"): "example.com/a v1.0.0 h1:zip=\nexample.com/a v1.0.0/go.mod h1:mod=\n",
+	})
+
+	ws, err := LoadWorkspace(filepath.Join(dir, "sub"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ws.Root != dir {
+		t.Errorf("Root = %q, want %q", ws.Root, dir)
+	}
+
+	tests := map[string]Module{
+		"example.com/a": {Path: "example.com/a", Version: "v1.0.0"},
+		"example.com/b": {Path: "example.com/b", Dir: filepath.Join(filepath.Dir(dir), "b")},
+		"example.com/c": {Path: "example.com/fork/c", Version: "v0.2.0"},
+	}
+	for path, want := range tests {
+		if got, ok := ws.Lookup(path); !ok || got != want {
+			t.Errorf("Lookup(%q) = %+v, %v, want %+v", path, got, ok, want)
+		}
+	}
+	if got := ws.Sum("example.com/a", "v1.0.0"); got != "h1:zip=" {
+		t.Errorf("Sum() = %q, want the zip hash", got)
+	}
+}
+
+func TestLoadWorkspace_GoWork(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, map[string]string{
+		filepath.Join(dir, "go.work"): "go 1.22\n\nuse (\n\t./api\n\t./svc\n)\n",
+		filepath.Join(dir, "api", "go.mod"): `module example.com/api
+
+require example.com/a v1.1.0
+`,
+		filepath.Join(dir, "svc", "go.mod"): `module example.com/svc
+
+require (
+	example.com/a v1.0.0
+	example.com/api v0.0.0
+)
+`,
+	})
+	t.Setenv("GOWORK", "")
+
+	ws, err := LoadWorkspace(filepath.Join(dir, "svc"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := ws.Lookup("example.com/a"); got.Version != "v1.1.0" {
+		t.Errorf("Lookup(a).Version = %q, want the highest required version", got.Version)
+	}
+	if got, _ := ws.Lookup("example.com/api"); got.Dir != filepath.Join(dir, "api") {
+		t.Errorf("Lookup(api).Dir = %q, want the workspace module", got.Dir)
+	}
+
+	t.Setenv("GOWORK", "off")
+	ws, err = LoadWorkspace(filepath.Join(dir, "svc"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := ws.Lookup("example.com/api"); got.Dir != "" || got.Version != "v0.0.0" {
+		t.Errorf("Lookup(api) with GOWORK=off = %+v, want the required version", got)
+	}
+}
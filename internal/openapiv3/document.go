@@ -0,0 +1,86 @@
+package openapiv3
+
+// Document is an OpenAPI 3.1 document, limited to what the generator emits.
+type Document struct {
+	OpenAPI    string               `yaml:"openapi" json:"openapi"`
+	Info       Info                 `yaml:"info" json:"info"`
+	Tags       []*Tag               `yaml:"tags,omitempty" json:"tags,omitempty"`
+	Paths      map[string]*PathItem `yaml:"paths" json:"paths"`
+	Components Components           `yaml:"components,omitempty" json:"components,omitempty"`
+}
+
+type Info struct {
+	Title       string `yaml:"title" json:"title"`
+	Description string `yaml:"description,omitempty" json:"description,omitempty"`
+	Version     string `yaml:"version" json:"version"`
+}
+
+type Tag struct {
+	Name        string `yaml:"name" json:"name"`
+	Description string `yaml:"description,omitempty" json:"description,omitempty"`
+}
+
+type PathItem struct {
+	Get     *Operation `yaml:"get,omitempty" json:"get,omitempty"`
+	Put     *Operation `yaml:"put,omitempty" json:"put,omitempty"`
+	Post    *Operation `yaml:"post,omitempty" json:"post,omitempty"`
+	Delete  *Operation `yaml:"delete,omitempty" json:"delete,omitempty"`
+	Options *Operation `yaml:"options,omitempty" json:"options,omitempty"`
+	Head    *Operation `yaml:"head,omitempty" json:"head,omitempty"`
+	Patch   *Operation `yaml:"patch,omitempty" json:"patch,omitempty"`
+	Trace   *Operation `yaml:"trace,omitempty" json:"trace,omitempty"`
+}
+
+type Operation struct {
+	Tags        []string             `yaml:"tags,omitempty" json:"tags,omitempty"`
+	Summary     string               `yaml:"summary,omitempty" json:"summary,omitempty"`
+	Description string               `yaml:"description,omitempty" json:"description,omitempty"`
+	OperationID string               `yaml:"operationId" json:"operationId"`
+	Parameters  []*Parameter         `yaml:"parameters,omitempty" json:"parameters,omitempty"`
+	RequestBody *RequestBody         `yaml:"requestBody,omitempty" json:"requestBody,omitempty"`
+	Responses   map[string]*Response `yaml:"responses" json:"responses"`
+	Deprecated  bool                 `yaml:"deprecated,omitempty" json:"deprecated,omitempty"`
+}
+
+type Parameter struct {
+	Name        string  `yaml:"name" json:"name"`
+	In          string  `yaml:"in" json:"in"`
+	Description string  `yaml:"description,omitempty" json:"description,omitempty"`
+	Required    bool    `yaml:"required,omitempty" json:"required,omitempty"`
+	Schema      *Schema `yaml:"schema" json:"schema"`
+}
+
+type RequestBody struct {
+	Required bool                  `yaml:"required" json:"required"`
+	Content  map[string]*MediaType `yaml:"content" json:"content"`
+}
+
+type Response struct {
+	Description string                `yaml:"description" json:"description"`
+	Content     map[string]*MediaType `yaml:"content,omitempty" json:"content,omitempty"`
+}
+
+type MediaType struct {
+	Schema *Schema `yaml:"schema" json:"schema"`
+}
+
+type Components struct {
+	Schemas map[string]*Schema `yaml:"schemas,omitempty" json:"schemas,omitempty"`
+}
+
+// Schema is a JSON schema. In OpenAPI 3.1 a $ref may have siblings such as
+// description.
+type Schema struct {
+	Ref                  string             `yaml:"$ref,omitempty" json:"$ref,omitempty"`
+	Type                 string             `yaml:"type,omitempty" json:"type,omitempty"`
+	Format               string             `yaml:"format,omitempty" json:"format,omitempty"`
+	Description          string             `yaml:"description,omitempty" json:"description,omitempty"`
+	Enum                 []string           `yaml:"enum,omitempty" json:"enum,omitempty"`
+	Items                *Schema            `yaml:"items,omitempty" json:"items,omitempty"`
+	Properties           map[string]*Schema `yaml:"properties,omitempty" json:"properties,omitempty"`
+	AdditionalProperties *Schema            `yaml:"additionalProperties,omitempty" json:"additionalProperties,omitempty"`
+	Required             []string           `yaml:"required,omitempty" json:"required,omitempty"`
+	ReadOnly             bool               `yaml:"readOnly,omitempty" json:"readOnly,omitempty"`
+	WriteOnly            bool               `yaml:"writeOnly,omitempty" json:"writeOnly,omitempty"`
+	Deprecated           bool               `yaml:"deprecated,omitempty" json:"deprecated,omitempty"`
+}
@@ -0,0 +1,378 @@
+// Package openapiv3 generates OpenAPI 3.1 documents from google.api.http
+// annotations. It runs as a builtin protobuild plugin, without an external
+// protoc-gen binary.
+package openapiv3
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+
+	"google.golang.org/genproto/googleapis/api/annotations"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/pluginpb"
+	"gopkg.in/yaml.v3"
+
+	"github.com/pubgo/protobuild/internal/protoutil"
+)
+
+// Options are the plugin parameters, e.g. merge=true,format=json.
+type Options struct {
+	// Merge writes a single document for all files instead of one per service.
+	Merge bool
+	// Filename of the merged document, default openapi.yaml.
+	Filename string
+	// Format is yaml (default) or json.
+	Format string
+	// Title and Version of the document info.
+	Title   string
+	Version string
+	// DefaultRoutes maps methods without google.api.http to
+	// POST /{package}/{service}/{method}.
+	DefaultRoutes bool
+}
+
+// ParseOptions parses comma separated key=value plugin parameters.
+func ParseOptions(param string) (Options, error) {
+	opts := Options{Format: "yaml", Version: "1.0.0"}
+	for _, p := range strings.Split(param, ",") {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			continue
+		}
+		key, value, _ := strings.Cut(p, "=")
+		switch key {
+		case "merge":
+			opts.Merge = value == "" || value == "true"
+		case "filename":
+			opts.Filename = value
+		case "format":
+			if value != "yaml" && value != "json" {
+				return opts, fmt.Errorf("unsupported format %q, expected yaml or json", value)
+			}
+			opts.Format = value
+		case "title":
+			opts.Title = value
+		case "version":
+			opts.Version = value
+		case "default_routes":
+			opts.DefaultRoutes = value == "" || value == "true"
+		default:
+			return opts, fmt.Errorf("unknown parameter %q", key)
+		}
+	}
+	if opts.Filename == "" {
+		opts.Filename = "openapi." + opts.Format
+	}
+	return opts, nil
+}
+
+// Generate generates OpenAPI documents for the files to generate in req.
+func Generate(req *pluginpb.CodeGeneratorRequest) (*pluginpb.CodeGeneratorResponse, error) {
+	opts, err := ParseOptions(req.GetParameter())
+	if err != nil {
+		return nil, err
+	}
+
+	files, err := protodesc.NewFiles(&descriptorpb.FileDescriptorSet{File: req.GetProtoFile()})
+	if err != nil {
+		return nil, err
+	}
+
+	var services []protoreflect.ServiceDescriptor
+	for _, name := range req.GetFileToGenerate() {
+		fd, err := files.FindFileByPath(name)
+		if err != nil {
+			return nil, err
+		}
+		for i := 0; i < fd.Services().Len(); i++ {
+			services = append(services, fd.Services().Get(i))
+		}
+	}
+
+	resp := &pluginpb.CodeGeneratorResponse{
+		SupportedFeatures: proto.Uint64(uint64(pluginpb.CodeGeneratorResponse_FEATURE_PROTO3_OPTIONAL)),
+	}
+	emit := func(name string, doc *Document) error {
+		content, err := encode(doc, opts.Format)
+		if err != nil {
+			return err
+		}
+		resp.File = append(resp.File, &pluginpb.CodeGeneratorResponse_File{
+			Name:    proto.String(name),
+			Content: proto.String(content),
+		})
+		return nil
+	}
+
+	if opts.Merge {
+		if len(services) == 0 {
+			return resp, nil
+		}
+		title := opts.Title
+		if title == "" {
+			title = "API"
+		}
+		g := newGenerator(opts, title, "")
+		for _, sd := range services {
+			if err := g.addService(sd); err != nil {
+				return nil, err
+			}
+		}
+		return resp, emit(opts.Filename, g.doc)
+	}
+
+	for _, sd := range services {
+		title := opts.Title
+		if title == "" {
+			title = string(sd.Name())
+		}
+		g := newGenerator(opts, title, comments(sd))
+		if err := g.addService(sd); err != nil {
+			return nil, err
+		}
+		name := path.Join(path.Dir(sd.ParentFile().Path()), string(sd.Name())+".openapi."+opts.Format)
+		if err := emit(name, g.doc); err != nil {
+			return nil, err
+		}
+	}
+	return resp, nil
+}
+
+func encode(doc *Document, format string) (string, error) {
+	if format == "json" {
+		data, err := json.MarshalIndent(doc, "", "  ")
+		return string(data) + "\n", err
+	}
+	data, err := yaml.Marshal(doc)
+	return string(data), err
+}
+
+type generator struct {
+	opts Options
+	doc  *Document
+	// routes maps "METHOD /path" to the method bound to it.
+	routes map[string]protoreflect.FullName
+}
+
+func newGenerator(opts Options, title, description string) *generator {
+	return &generator{
+		opts:   opts,
+		routes: make(map[string]protoreflect.FullName),
+		doc: &Document{
+			OpenAPI: "3.1.0",
+			Info:    Info{Title: title, Description: description, Version: opts.Version},
+			Paths:   make(map[string]*PathItem),
+			Components: Components{Schemas: map[string]*Schema{
+				statusSchemaName: statusSchema(),
+			}},
+		},
+	}
+}
+
+func (g *generator) addService(sd protoreflect.ServiceDescriptor) error {
+	g.doc.Tags = append(g.doc.Tags, &Tag{Name: string(sd.Name()), Description: comments(sd)})
+	for i := 0; i < sd.Methods().Len(); i++ {
+		md := sd.Methods().Get(i)
+		rule, err := protoutil.ExtractAPIOptions(md)
+		if err != nil {
+			return err
+		}
+		if rule == nil {
+			if !g.opts.DefaultRoutes {
+				continue
+			}
+			rule = protoutil.DefaultAPIOptions(string(sd.ParentFile().Package()), string(sd.Name()), string(md.Name()))
+		}
+
+		rules := append([]*annotations.HttpRule{rule}, rule.GetAdditionalBindings()...)
+		for j, r := range rules {
+			operationID := fmt.Sprintf("%s_%s", sd.Name(), md.Name())
+			if j > 0 {
+				operationID += fmt.Sprint(j)
+			}
+			if err := g.addOperation(sd, md, r, operationID); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// pathParam matches a path template variable such as {name=shelves/*}.
+var pathParam = regexp.MustCompile(`\{([^}=]+)(=[^}]*)?\}`)
+
+// httpMethods are the HTTP methods OpenAPI path items support.
+var httpMethods = map[string]bool{
+	"GET": true, "PUT": true, "POST": true, "DELETE": true,
+	"PATCH": true, "OPTIONS": true, "HEAD": true, "TRACE": true,
+}
+
+func (g *generator) addOperation(sd protoreflect.ServiceDescriptor, md protoreflect.MethodDescriptor, rule *annotations.HttpRule, operationID string) error {
+	method, template := protoutil.ExtractHttpMethod(rule)
+	if template == "" {
+		return fmt.Errorf("%s: google.api.http has no path", md.FullName())
+	}
+
+	// Custom rules may use methods OpenAPI cannot describe; skip only them.
+	method = strings.ToUpper(method)
+	if !httpMethods[method] {
+		fmt.Fprintf(os.Stderr, "⚠️  openapiv3: %s: HTTP method %q is not supported by OpenAPI, skipped\n", md.FullName(), method)
+		return nil
+	}
+
+	op := &Operation{
+		Tags:        []string{string(sd.Name())},
+		Description: comments(md),
+		OperationID: operationID,
+		Responses:   make(map[string]*Response),
+		Deprecated:  md.Options().(interface{ GetDeprecated() bool }).GetDeprecated(),
+	}
+
+	// Path parameters, e.g. /v1/{name=shelves/*} becomes /v1/{name}.
+	input := md.Input()
+	bound := make(map[string]bool)
+	for _, match := range pathParam.FindAllStringSubmatch(template, -1) {
+		fieldPath := match[1]
+		fd := findField(input, fieldPath)
+		if fd == nil {
+			return fmt.Errorf("%s: path parameter %q is not a field of %s", md.FullName(), fieldPath, input.FullName())
+		}
+		// A nested field such as book.name binds the top-level field book.
+		top, _, _ := strings.Cut(fieldPath, ".")
+		bound[top] = true
+		op.Parameters = append(op.Parameters, &Parameter{
+			Name:        fieldPath,
+			In:          "path",
+			Required:    true,
+			Description: comments(fd),
+			Schema:      g.fieldSchema(fd),
+		})
+	}
+	openapiPath := pathParam.ReplaceAllString(template, "{$1}")
+
+	// Request body and query parameters.
+	switch body := rule.GetBody(); body {
+	case "*":
+		op.RequestBody = &RequestBody{Required: true, Content: jsonContent(g.messageRef(input))}
+	default:
+		if body != "" {
+			fd := input.Fields().ByName(protoreflect.Name(body))
+			if fd == nil {
+				return fmt.Errorf("%s: body %q is not a field of %s", md.FullName(), body, input.FullName())
+			}
+			bound[body] = true
+			op.RequestBody = &RequestBody{Required: true, Content: jsonContent(g.fieldSchema(fd))}
+		}
+		op.Parameters = append(op.Parameters, g.queryParams(input, bound)...)
+	}
+
+	// Responses.
+	response := g.messageRef(md.Output())
+	if name := rule.GetResponseBody(); name != "" {
+		if fd := md.Output().Fields().ByName(protoreflect.Name(name)); fd != nil {
+			response = g.fieldSchema(fd)
+		}
+	}
+	op.Responses["200"] = &Response{Description: "OK", Content: jsonContent(response)}
+	op.Responses["default"] = &Response{
+		Description: "An unexpected error response.",
+		Content:     jsonContent(&Schema{Ref: schemaRef(statusSchemaName)}),
+	}
+
+	route := method + " " + openapiPath
+	if prev, ok := g.routes[route]; ok {
+		return fmt.Errorf("%s and %s are both bound to %s", prev, md.FullName(), route)
+	}
+	g.routes[route] = md.FullName()
+
+	item := g.doc.Paths[openapiPath]
+	if item == nil {
+		item = &PathItem{}
+		g.doc.Paths[openapiPath] = item
+	}
+	switch method {
+	case "GET":
+		item.Get = op
+	case "PUT":
+		item.Put = op
+	case "POST":
+		item.Post = op
+	case "DELETE":
+		item.Delete = op
+	case "PATCH":
+		item.Patch = op
+	case "OPTIONS":
+		item.Options = op
+	case "HEAD":
+		item.Head = op
+	case "TRACE":
+		item.Trace = op
+	}
+	return nil
+}
+
+// queryParams maps the fields of msg that are not bound to the path or body
+// to query parameters. Only scalar, enum and well-known scalar message
+// fields can be passed in the query string.
+func (g *generator) queryParams(msg protoreflect.MessageDescriptor, bound map[string]bool) []*Parameter {
+	var params []*Parameter
+	for i := 0; i < msg.Fields().Len(); i++ {
+		fd := msg.Fields().Get(i)
+		if bound[string(fd.Name())] || fd.IsMap() {
+			continue
+		}
+		if fd.Message() != nil {
+			if _, ok := wellKnownScalars[fd.Message().FullName()]; !ok {
+				continue
+			}
+		}
+		params = append(params, &Parameter{
+			Name:        fd.JSONName(),
+			In:          "query",
+			Description: comments(fd),
+			Schema:      g.fieldSchema(fd),
+		})
+	}
+	return params
+}
+
+func findField(msg protoreflect.MessageDescriptor, fieldPath string) protoreflect.FieldDescriptor {
+	var fd protoreflect.FieldDescriptor
+	for _, name := range strings.Split(fieldPath, ".") {
+		if msg == nil {
+			return nil
+		}
+		fd = msg.Fields().ByName(protoreflect.Name(name))
+		if fd == nil {
+			return nil
+		}
+		msg = fd.Message()
+	}
+	return fd
+}
+
+func jsonContent(schema *Schema) map[string]*MediaType {
+	return map[string]*MediaType{"application/json": {Schema: schema}}
+}
+
+// comments returns the leading comments of d, or its trailing comments.
+func comments(d protoreflect.Descriptor) string {
+	loc := d.ParentFile().SourceLocations().ByDescriptor(d)
+	text := loc.LeadingComments
+	if strings.TrimSpace(text) == "" {
+		text = loc.TrailingComments
+	}
+
+	lines := strings.Split(strings.TrimSpace(text), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimSpace(line)
+	}
+	return strings.Join(lines, "\n")
+}
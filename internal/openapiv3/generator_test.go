@@ -0,0 +1,279 @@
+package openapiv3
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/bufbuild/protocompile"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/pluginpb"
+	"gopkg.in/yaml.v3"
+
+	_ "google.golang.org/genproto/googleapis/api/annotations"
+)
+
+const libraryProto = `syntax = "proto3";
+
+package acme.library.v1;
+
+import "google/api/annotations.proto";
+import "google/api/field_behavior.proto";
+import "google/protobuf/timestamp.proto";
+
+// Library manages books.
+service Library {
+  // Gets a book.
+  rpc GetBook(GetBookRequest) returns (Book) {
+    option (google.api.http) = {get: "/v1/{name=shelves/*/books/*}"};
+  }
+
+  // Creates a book.
+  rpc CreateBook(CreateBookRequest) returns (Book) {
+    option (google.api.http) = {
+      post: "/v1/{parent=shelves/*}/books"
+      body: "book"
+    };
+  }
+
+  rpc Internal(GetBookRequest) returns (Book);
+}
+
+// A book.
+message Book {
+  // The resource name.
+  string name = 1 [(google.api.field_behavior) = OUTPUT_ONLY];
+  string title = 2 [(google.api.field_behavior) = REQUIRED];
+  int64 pages = 3;
+  google.protobuf.Timestamp create_time = 4;
+  Genre genre = 5;
+  map<string, string> labels = 6;
+}
+
+enum Genre {
+  GENRE_UNSPECIFIED = 0;
+  FICTION = 1;
+}
+
+message GetBookRequest {
+  string name = 1;
+  bool include_drafts = 2;
+}
+
+message CreateBookRequest {
+  string parent = 1;
+  Book book = 2;
+  string book_id = 3;
+}
+`
+
+func request(t *testing.T, param string) *pluginpb.CodeGeneratorRequest {
+	t.Helper()
+	return requestFor(t, libraryProto, param)
+}
+
+func requestFor(t *testing.T, source, param string) *pluginpb.CodeGeneratorRequest {
+	t.Helper()
+
+	compiler := protocompile.Compiler{
+		Resolver: protocompile.CompositeResolver{
+			&protocompile.SourceResolver{Accessor: protocompile.SourceAccessorFromMap(map[string]string{
+				"acme/library/v1/library.proto": source,
+			})},
+			protocompile.ResolverFunc(func(path string) (protocompile.SearchResult, error) {
+				fd, err := protoregistry.GlobalFiles.FindFileByPath(path)
+				if err != nil {
+					return protocompile.SearchResult{}, err
+				}
+				return protocompile.SearchResult{Desc: fd}, nil
+			}),
+		},
+		SourceInfoMode: protocompile.SourceInfoStandard,
+	}
+	files, err := compiler.Compile(context.Background(), "acme/library/v1/library.proto")
+	if err != nil {
+		t.Fatalf("compile failed: %v", err)
+	}
+
+	req := &pluginpb.CodeGeneratorRequest{FileToGenerate: []string{"acme/library/v1/library.proto"}, Parameter: proto.String(param)}
+	seen := make(map[string]bool)
+	var add func(fd protoreflect.FileDescriptor)
+	add = func(fd protoreflect.FileDescriptor) {
+		if seen[fd.Path()] {
+			return
+		}
+		seen[fd.Path()] = true
+		for i := 0; i < fd.Imports().Len(); i++ {
+			add(fd.Imports().Get(i).FileDescriptor)
+		}
+		req.ProtoFile = append(req.ProtoFile, protodesc.ToFileDescriptorProto(fd))
+	}
+	add(files[0])
+
+	// Round-trip so options are decoded with the registered extensions.
+	data, err := proto.Marshal(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req = &pluginpb.CodeGeneratorRequest{}
+	if err := proto.Unmarshal(data, req); err != nil {
+		t.Fatal(err)
+	}
+	return req
+}
+
+func TestGenerate(t *testing.T) {
+	resp, err := Generate(request(t, ""))
+	if err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+	if len(resp.File) != 1 || resp.File[0].GetName() != "acme/library/v1/Library.openapi.yaml" {
+		t.Fatalf("unexpected files: %v", resp.File)
+	}
+
+	var doc Document
+	if err := yaml.Unmarshal([]byte(resp.File[0].GetContent()), &doc); err != nil {
+		t.Fatalf("invalid yaml: %v", err)
+	}
+	if doc.OpenAPI != "3.1.0" || doc.Info.Title != "Library" || doc.Info.Description != "Library manages books." {
+		t.Errorf("unexpected info: %+v", doc.Info)
+	}
+	if len(doc.Paths) != 2 {
+		t.Errorf("expected 2 paths, got %v", doc.Paths)
+	}
+
+	get := doc.Paths["/v1/{name}"].Get
+	if get == nil || get.OperationID != "Library_GetBook" || get.Description != "Gets a book." {
+		t.Fatalf("unexpected get operation: %+v", get)
+	}
+	var params []string
+	for _, p := range get.Parameters {
+		params = append(params, p.In+":"+p.Name)
+	}
+	if strings.Join(params, ",") != "path:name,query:includeDrafts" {
+		t.Errorf("unexpected get parameters: %v", params)
+	}
+
+	create := doc.Paths["/v1/{parent}/books"].Post
+	if create == nil || create.RequestBody.Content["application/json"].Schema.Ref != "#/components/schemas/acme.library.v1.Book" {
+		t.Fatalf("unexpected create operation: %+v", create)
+	}
+	if len(create.Parameters) != 2 || create.Parameters[1].Name != "bookId" {
+		t.Errorf("unexpected create parameters: %+v", create.Parameters)
+	}
+
+	book := doc.Components.Schemas["acme.library.v1.Book"]
+	if book == nil || book.Description != "A book." {
+		t.Fatalf("unexpected book schema: %+v", book)
+	}
+	if !book.Properties["name"].ReadOnly || book.Properties["name"].Description != "The resource name." {
+		t.Errorf("unexpected name property: %+v", book.Properties["name"])
+	}
+	if len(book.Required) != 1 || book.Required[0] != "title" {
+		t.Errorf("unexpected required: %v", book.Required)
+	}
+	if p := book.Properties["pages"]; p.Type != "string" || p.Format != "int64" {
+		t.Errorf("unexpected pages property: %+v", p)
+	}
+	if p := book.Properties["createTime"]; p.Type != "string" || p.Format != "date-time" {
+		t.Errorf("unexpected createTime property: %+v", p)
+	}
+	if p := book.Properties["labels"]; p.Type != "object" || p.AdditionalProperties.Type != "string" {
+		t.Errorf("unexpected labels property: %+v", p)
+	}
+	if genre := doc.Components.Schemas["acme.library.v1.Genre"]; genre == nil || len(genre.Enum) != 2 {
+		t.Errorf("unexpected genre schema: %+v", genre)
+	}
+}
+
+func TestGenerate_Options(t *testing.T) {
+	resp, err := Generate(request(t, "merge=true,format=json,title=Acme,default_routes=true"))
+	if err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+	if len(resp.File) != 1 || resp.File[0].GetName() != "openapi.json" {
+		t.Fatalf("unexpected files: %v", resp.File)
+	}
+	content := resp.File[0].GetContent()
+	if !strings.Contains(content, `"title": "Acme"`) || !strings.Contains(content, `"operationId": "Library_Internal"`) {
+		t.Errorf("unexpected document:\n%s", content)
+	}
+
+	if _, err := Generate(request(t, "unknown=1")); err == nil {
+		t.Error("expected error for unknown parameter")
+	}
+}
+
+const bindingsProto = `syntax = "proto3";
+
+package acme.library.v1;
+
+import "google/api/annotations.proto";
+import "google/protobuf/wrappers.proto";
+
+service Shelves {
+  rpc GetShelf(GetShelfRequest) returns (Shelf) {
+    option (google.api.http) = {get: "/v1/{shelf.value=shelves/*}"};
+  }
+
+  rpc SearchShelves(GetShelfRequest) returns (Shelf) {
+    option (google.api.http) = {custom: {kind: "SEARCH", path: "/v1/shelves"}};
+  }
+}
+
+service Archive {
+  rpc GetShelf(GetShelfRequest) returns (Shelf) {
+    option (google.api.http) = {get: "/v1/{shelf.value=shelves/*}"};
+  }
+}
+
+message Shelf {
+  string name = 1;
+}
+
+message GetShelfRequest {
+  google.protobuf.StringValue shelf = 1;
+  string view = 2;
+}
+`
+
+func TestGenerate_Bindings(t *testing.T) {
+	resp, err := Generate(requestFor(t, bindingsProto, ""))
+	if err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+
+	var doc Document
+	for _, f := range resp.File {
+		if strings.HasSuffix(f.GetName(), "Shelves.openapi.yaml") {
+			if err := yaml.Unmarshal([]byte(f.GetContent()), &doc); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	// The custom SEARCH rule is skipped, the rest is generated.
+	if item := doc.Paths["/v1/shelves"]; item != nil {
+		t.Errorf("expected the custom rule to be skipped, got %+v", item)
+	}
+	op := doc.Paths["/v1/{shelf.value}"].Get
+	if op == nil {
+		t.Fatalf("missing GET /v1/{shelf.value}: %v", doc.Paths)
+	}
+	var names []string
+	for _, p := range op.Parameters {
+		names = append(names, p.In+":"+p.Name)
+	}
+	if strings.Join(names, ",") != "path:shelf.value,query:view" {
+		t.Errorf("parameters = %v, want the nested path field bound", names)
+	}
+
+	// Merged, both services bind the same route.
+	_, err = Generate(requestFor(t, bindingsProto, "merge=true"))
+	if err == nil || !strings.Contains(err.Error(), "acme.library.v1.Shelves.GetShelf and acme.library.v1.Archive.GetShelf") {
+		t.Errorf("Generate() error = %v, want a duplicate route error", err)
+	}
+}
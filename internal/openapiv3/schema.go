@@ -0,0 +1,167 @@
+package openapiv3
+
+import (
+	"slices"
+
+	"google.golang.org/genproto/googleapis/api/annotations"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+const statusSchemaName = "google.rpc.Status"
+
+// wellKnownScalars are the well-known types with a scalar JSON mapping.
+var wellKnownScalars = map[protoreflect.FullName]func() *Schema{
+	"google.protobuf.Timestamp":   func() *Schema { return &Schema{Type: "string", Format: "date-time"} },
+	"google.protobuf.Duration":    func() *Schema { return &Schema{Type: "string"} },
+	"google.protobuf.FieldMask":   func() *Schema { return &Schema{Type: "string"} },
+	"google.protobuf.DoubleValue": func() *Schema { return &Schema{Type: "number", Format: "double"} },
+	"google.protobuf.FloatValue":  func() *Schema { return &Schema{Type: "number", Format: "float"} },
+	"google.protobuf.Int64Value":  func() *Schema { return &Schema{Type: "string", Format: "int64"} },
+	"google.protobuf.UInt64Value": func() *Schema { return &Schema{Type: "string", Format: "uint64"} },
+	"google.protobuf.Int32Value":  func() *Schema { return &Schema{Type: "integer", Format: "int32"} },
+	"google.protobuf.UInt32Value": func() *Schema { return &Schema{Type: "integer", Format: "int64"} },
+	"google.protobuf.BoolValue":   func() *Schema { return &Schema{Type: "boolean"} },
+	"google.protobuf.StringValue": func() *Schema { return &Schema{Type: "string"} },
+	"google.protobuf.BytesValue":  func() *Schema { return &Schema{Type: "string", Format: "byte"} },
+}
+
+// wellKnownObjects are the other well-known types, mapped inline.
+var wellKnownObjects = map[protoreflect.FullName]func() *Schema{
+	"google.protobuf.Empty":     func() *Schema { return &Schema{Type: "object"} },
+	"google.protobuf.Struct":    func() *Schema { return &Schema{Type: "object", AdditionalProperties: &Schema{}} },
+	"google.protobuf.Value":     func() *Schema { return &Schema{} },
+	"google.protobuf.ListValue": func() *Schema { return &Schema{Type: "array", Items: &Schema{}} },
+	"google.protobuf.Any": func() *Schema {
+		return &Schema{
+			Type:                 "object",
+			Properties:           map[string]*Schema{"@type": {Type: "string"}},
+			AdditionalProperties: &Schema{},
+		}
+	},
+}
+
+func schemaRef(name string) string {
+	return "#/components/schemas/" + name
+}
+
+// fieldSchema returns the schema of a field, including its description.
+func (g *generator) fieldSchema(fd protoreflect.FieldDescriptor) *Schema {
+	var schema *Schema
+	switch {
+	case fd.IsMap():
+		schema = &Schema{Type: "object", AdditionalProperties: g.singularSchema(fd.MapValue())}
+	case fd.IsList():
+		schema = &Schema{Type: "array", Items: g.singularSchema(fd)}
+	default:
+		schema = g.singularSchema(fd)
+	}
+	schema.Description = comments(fd)
+	schema.Deprecated = fd.Options().(interface{ GetDeprecated() bool }).GetDeprecated()
+
+	for _, behavior := range fieldBehaviors(fd) {
+		switch behavior {
+		case annotations.FieldBehavior_OUTPUT_ONLY:
+			schema.ReadOnly = true
+		case annotations.FieldBehavior_INPUT_ONLY:
+			schema.WriteOnly = true
+		}
+	}
+	return schema
+}
+
+// singularSchema returns the schema of a single value of fd, following the
+// proto3 JSON mapping.
+func (g *generator) singularSchema(fd protoreflect.FieldDescriptor) *Schema {
+	switch fd.Kind() {
+	case protoreflect.BoolKind:
+		return &Schema{Type: "boolean"}
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		return &Schema{Type: "integer", Format: "int32"}
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		return &Schema{Type: "integer", Format: "int64"}
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		return &Schema{Type: "string", Format: "int64"}
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		return &Schema{Type: "string", Format: "uint64"}
+	case protoreflect.FloatKind:
+		return &Schema{Type: "number", Format: "float"}
+	case protoreflect.DoubleKind:
+		return &Schema{Type: "number", Format: "double"}
+	case protoreflect.StringKind:
+		return &Schema{Type: "string"}
+	case protoreflect.BytesKind:
+		return &Schema{Type: "string", Format: "byte"}
+	case protoreflect.EnumKind:
+		return g.enumRef(fd.Enum())
+	default:
+		return g.messageRef(fd.Message())
+	}
+}
+
+// messageRef returns a reference to the component schema of md, adding it
+// and the messages it references to the document.
+func (g *generator) messageRef(md protoreflect.MessageDescriptor) *Schema {
+	if fn, ok := wellKnownScalars[md.FullName()]; ok {
+		return fn()
+	}
+	if fn, ok := wellKnownObjects[md.FullName()]; ok {
+		return fn()
+	}
+
+	name := string(md.FullName())
+	if _, ok := g.doc.Components.Schemas[name]; ok {
+		return &Schema{Ref: schemaRef(name)}
+	}
+
+	schema := &Schema{
+		Type:        "object",
+		Description: comments(md),
+		Properties:  make(map[string]*Schema),
+		Deprecated:  md.Options().(interface{ GetDeprecated() bool }).GetDeprecated(),
+	}
+	// Register before visiting fields so recursive messages terminate.
+	g.doc.Components.Schemas[name] = schema
+	for i := 0; i < md.Fields().Len(); i++ {
+		fd := md.Fields().Get(i)
+		schema.Properties[fd.JSONName()] = g.fieldSchema(fd)
+		if slices.Contains(fieldBehaviors(fd), annotations.FieldBehavior_REQUIRED) {
+			schema.Required = append(schema.Required, fd.JSONName())
+		}
+	}
+	return &Schema{Ref: schemaRef(name)}
+}
+
+// enumRef returns a reference to the component schema of ed.
+func (g *generator) enumRef(ed protoreflect.EnumDescriptor) *Schema {
+	name := string(ed.FullName())
+	if _, ok := g.doc.Components.Schemas[name]; !ok {
+		schema := &Schema{Type: "string", Description: comments(ed)}
+		for i := 0; i < ed.Values().Len(); i++ {
+			schema.Enum = append(schema.Enum, string(ed.Values().Get(i).Name()))
+		}
+		g.doc.Components.Schemas[name] = schema
+	}
+	return &Schema{Ref: schemaRef(name)}
+}
+
+func fieldBehaviors(fd protoreflect.FieldDescriptor) []annotations.FieldBehavior {
+	if !proto.HasExtension(fd.Options(), annotations.E_FieldBehavior) {
+		return nil
+	}
+	behaviors, _ := proto.GetExtension(fd.Options(), annotations.E_FieldBehavior).([]annotations.FieldBehavior)
+	return behaviors
+}
+
+// statusSchema is the google.rpc.Status error model used by gRPC gateways.
+func statusSchema() *Schema {
+	return &Schema{
+		Type:        "object",
+		Description: "The error model used by gRPC and HTTP gateways.",
+		Properties: map[string]*Schema{
+			"code":    {Type: "integer", Format: "int32"},
+			"message": {Type: "string"},
+			"details": {Type: "array", Items: wellKnownObjects["google.protobuf.Any"]()},
+		},
+	}
+}
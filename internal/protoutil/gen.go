@@ -3,20 +3,19 @@ package protoutil
 import (
 	"bytes"
 	"fmt"
-	"go/format"
 	"log"
 	"os"
 	"strings"
-	"unicode"
 
 	pongo "github.com/flosch/pongo2/v5"
-	"github.com/golang/protobuf/proto"
-	"github.com/golang/protobuf/protoc-gen-go/descriptor"
 	"github.com/pubgo/funk/v2/assert"
 	options "google.golang.org/genproto/googleapis/api/annotations"
 	"google.golang.org/protobuf/compiler/protogen"
 	gp "google.golang.org/protobuf/proto"
 	"google.golang.org/protobuf/reflect/protoreflect"
+	descriptor "google.golang.org/protobuf/types/descriptorpb"
+
+	"github.com/pubgo/protobuild/pkg/plugin"
 )
 
 func Append(s *string, args ...string) {
@@ -66,27 +65,11 @@ func getGoPackage(fd *descriptor.FileDescriptorProto) string {
 // If there's a simple name, it returns ("", Pkg, true).
 // If the option implies an import path, it returns (impPath, Pkg, true).
 func goPackageOption(d *descriptor.FileDescriptorProto) (impPath, pkg string, ok bool) {
-	pkg = getGoPackage(d)
-	if pkg == "" {
-		return
-	}
-
-	ok = true
-	// The presence of a slash implies there's an import path.
-	slash := strings.LastIndex(pkg, "/")
-	if slash < 0 {
+	if d.GetOptions().GetGoPackage() == "" {
 		return
 	}
-
-	impPath, pkg = pkg, pkg[slash+1:]
-	// A semicolon-delimited suffix overrides the package name.
-	sc := strings.IndexByte(impPath, ';')
-	if sc < 0 {
-		return
-	}
-
-	impPath, pkg = impPath[:sc], impPath[sc+1:]
-	return
+	path, name := plugin.ParseGoPackage(d.GetOptions().GetGoPackage())
+	return string(path), string(name), true
 }
 
 // goPackageName
@@ -118,63 +101,10 @@ func getTypeName(pkg, mth string) string {
 	return mth
 }
 
-// Is c an ASCII lower-case letter?
-func isASCIILower(c byte) bool {
-	return 'a' <= c && c <= 'z'
-}
-
-// Is c an ASCII digit?
-func isASCIIDigit(c byte) bool {
-	return '0' <= c && c <= '9'
-}
-
 // CamelCase
-// returns the CamelCased name.
-// If there is an interior underscore followed by a lower case letter,
-// drop the underscore and convert the letter to upper case.
-// There is a remote possibility of this rewrite causing a name collision,
-// but it's so remote we're prepared to pretend it's nonexistent - since the
-// C++ generator lowercases names, it's extremely unlikely to have two fields
-// with different capitalizations.
+// returns the CamelCased name, see plugin.CamelCase.
 // In short, _my_field_name_2 becomes XMyFieldName_2.
-func CamelCase(s string) string {
-	if s == "" {
-		return ""
-	}
-	t := make([]byte, 0, 32)
-	i := 0
-	if s[0] == '_' {
-		// Need a capital letter; drop the '_'.
-		t = append(t, 'X')
-		i++
-	}
-	// Invariant: if the next letter is lower case, it must be converted
-	// to upper case.
-	// That is, we process a word at a time, where words are marked by _ or
-	// upper case letter. Digits are treated as words.
-	for ; i < len(s); i++ {
-		c := s[i]
-		if c == '_' && i+1 < len(s) && isASCIILower(s[i+1]) {
-			continue // Skip the underscore in s.
-		}
-		if isASCIIDigit(c) {
-			t = append(t, c)
-			continue
-		}
-		// Assume we have a letter now - if not, it's a bogus identifier.
-		// The next word is a sequence of characters that must start upper case.
-		if isASCIILower(c) {
-			c ^= ' ' // Make it a capital letter.
-		}
-		t = append(t, c) // Guaranteed not lower case.
-		// Accept lower case sequence that follows.
-		for i+1 < len(s) && isASCIILower(s[i+1]) {
-			i++
-			t = append(t, s[i])
-		}
-	}
-	return string(t)
-}
+func CamelCase(s string) string { return plugin.CamelCase(s) }
 
 // DefaultAPIOptions
 // This generates an HttpRule that matches the gRPC mapping to HTTP/2 described in
@@ -211,73 +141,15 @@ func ExtractAPIOptions(mth protoreflect.MethodDescriptor) (*options.HttpRule, er
 }
 
 func ExtractHttpMethod(opts *options.HttpRule) (method, path string) {
-	var (
-		httpMethod   string
-		pathTemplate string
-	)
-
-	switch {
-	case opts.GetGet() != "":
-		httpMethod = "GET"
-		pathTemplate = opts.GetGet()
-
-	case opts.GetPut() != "":
-		httpMethod = "PUT"
-		pathTemplate = opts.GetPut()
-
-	case opts.GetPost() != "":
-		httpMethod = "POST"
-		pathTemplate = opts.GetPost()
-
-	case opts.GetDelete() != "":
-		httpMethod = "DELETE"
-		pathTemplate = opts.GetDelete()
-
-	case opts.GetPatch() != "":
-		httpMethod = "PATCH"
-		pathTemplate = opts.GetPatch()
-
-	case opts.GetCustom() != nil:
-		custom := opts.GetCustom()
-		httpMethod = custom.Kind
-		pathTemplate = custom.Path
-
-	default:
-		return "", ""
-	}
-
-	return httpMethod, pathTemplate
+	b := plugin.HTTPBindingOf(opts)
+	return b.Method, b.Path
 }
 
-func UnExport(s string) string {
-	if len(s) == 0 {
-		return ""
-	}
-	return strings.ToLower(s[:1]) + s[1:]
-}
+func UnExport(s string) string { return plugin.UnExport(s) }
 
 // Camel2Case
 // 驼峰式写法转为下划线写法
-func Camel2Case(name string) string {
-	name = trim(name)
-	buf := new(bytes.Buffer)
-	for i, r := range name {
-		if !unicode.IsUpper(r) {
-			buf.WriteRune(r)
-			continue
-		}
-
-		if i != 0 {
-			buf.WriteRune('-')
-		}
-		buf.WriteRune(unicode.ToLower(r))
-	}
-	return strings.NewReplacer(".", "-", "_", "-", "--", "-").Replace(buf.String())
-}
-
-func trim(s string) string {
-	return strings.Trim(strings.TrimSpace(s), ".-_/")
-}
+func Camel2Case(name string) string { return plugin.KebabCase(name) }
 
 func Import(name string) func(id string) protogen.GoIdent {
 	pkg := protogen.GoImportPath(name)
@@ -298,14 +170,24 @@ func Gen(g *protogen.GeneratedFile, tpl string, m pongo.Context) {
 }
 
 func Template(tpl string, m pongo.Context) string {
+	return assert.Must1(RenderTemplate(tpl, m))
+}
+
+// RenderTemplate is Template for user supplied templates, returning parse and
+// execution errors instead of panicking.
+func RenderTemplate(tpl string, m pongo.Context) (string, error) {
 	m["unExport"] = UnExport
 
 	temp, err := pongo.FromString(strings.TrimSpace(tpl))
-	assert.Must(err)
+	if err != nil {
+		return "", err
+	}
 
 	g := bytes.NewBuffer(nil)
-	assert.Must(temp.ExecuteWriter(m, g))
-	return g.String()
+	if err := temp.ExecuteWriter(m, g); err != nil {
+		return "", err
+	}
+	return g.String(), nil
 }
 
 func goZeroValue(f *descriptor.FieldDescriptorProto) string {
@@ -352,81 +234,38 @@ func goPkgLastElement(f *descriptor.FileDescriptorProto) string {
 }
 
 func httpBody(m *descriptor.MethodDescriptorProto) string {
-	ext, err := proto.GetExtension(m.Options, options.E_Http)
-	if err != nil {
-		return err.Error()
-	}
-	opts, ok := ext.(*options.HttpRule)
+	opts, ok := gp.GetExtension(m.GetOptions(), options.E_Http).(*options.HttpRule)
 	if !ok {
-		return fmt.Sprintf("extension is %T; want an HttpRule", ext)
+		return ""
 	}
-	return opts.Body
+	return opts.GetBody()
 }
 
 func httpVerb(m *descriptor.MethodDescriptorProto) string {
-	ext, err := proto.GetExtension(m.Options, options.E_Http)
-	if err != nil {
-		return err.Error()
-	}
-	opts, ok := ext.(*options.HttpRule)
+	opts, ok := gp.GetExtension(m.GetOptions(), options.E_Http).(*options.HttpRule)
 	if !ok {
-		return fmt.Sprintf("extension is %T; want an HttpRule", ext)
-	}
-
-	switch t := opts.Pattern.(type) {
-	default:
 		return ""
-	case *options.HttpRule_Get:
-		return "GET"
-	case *options.HttpRule_Post:
-		return "POST"
-	case *options.HttpRule_Put:
-		return "PUT"
-	case *options.HttpRule_Delete:
-		return "DELETE"
-	case *options.HttpRule_Patch:
-		return "PATCH"
-	case *options.HttpRule_Custom:
-		return t.Custom.Kind
 	}
+	return plugin.HTTPBindingOf(opts).Method
 }
 
 func httpPathsAdditionalBindings(m *descriptor.MethodDescriptorProto) []string {
-	ext, err := proto.GetExtension(m.Options, options.E_Http)
-	if err != nil {
-		panic(err.Error())
-	}
-	opts, ok := ext.(*options.HttpRule)
+	opts, ok := gp.GetExtension(m.GetOptions(), options.E_Http).(*options.HttpRule)
 	if !ok {
-		panic(fmt.Sprintf("extension is %T; want an HttpRule", ext))
+		return nil
 	}
 
 	var httpPaths []string
-	optsAdditionalBindings := opts.GetAdditionalBindings()
-	for _, optAdditionalBindings := range optsAdditionalBindings {
-		switch t := optAdditionalBindings.Pattern.(type) {
-		case *options.HttpRule_Get:
-			httpPaths = append(httpPaths, t.Get)
-		case *options.HttpRule_Post:
-			httpPaths = append(httpPaths, t.Post)
-		case *options.HttpRule_Put:
-			httpPaths = append(httpPaths, t.Put)
-		case *options.HttpRule_Delete:
-			httpPaths = append(httpPaths, t.Delete)
-		case *options.HttpRule_Patch:
-			httpPaths = append(httpPaths, t.Patch)
-		case *options.HttpRule_Custom:
-			httpPaths = append(httpPaths, t.Custom.Path)
-		default:
-			// nothing
+	for _, binding := range opts.GetAdditionalBindings() {
+		if path := plugin.HTTPBindingOf(binding).Path; path != "" {
+			httpPaths = append(httpPaths, path)
 		}
 	}
-
 	return httpPaths
 }
 
 // CodeFormat go code format
 func CodeFormat(buf *bytes.Buffer) (string, error) {
-	code, err := format.Source(buf.Bytes())
+	code, err := plugin.FormatSource(buf.Bytes())
 	return string(code), err
 }
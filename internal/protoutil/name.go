@@ -1,11 +1,10 @@
 package protoutil
 
 import (
-	"bytes"
 	"path/filepath"
 	"strings"
-	"unicode"
-	"unicode/utf8"
+
+	"github.com/pubgo/protobuild/pkg/plugin"
 )
 
 // A Name describes an identifier of an Entity (Message, Field, Enum, Service,
@@ -52,59 +51,7 @@ func (n Name) UpperDotNotation() Name { return n.Transform(strings.Title, string
 // Split breaks apart Name n into its constituent components. Precedence
 // follows dot notation, then underscores (excluding underscore prefixes), then
 // camelcase. Numbers are treated as standalone components.
-func (n Name) Split() (parts []string) {
-	ns := string(n)
-
-	switch {
-	case ns == "":
-		return []string{""}
-	case strings.LastIndex(ns, ".") >= 0:
-		return strings.Split(ns, ".")
-	case strings.LastIndex(ns, "_") > 0: // leading underscore does not count
-		parts = strings.Split(ns, "_")
-		if parts[0] == "" {
-			parts[1] = "_" + parts[1]
-			return parts[1:]
-		}
-		return
-	default: // camelCase
-		buf := &bytes.Buffer{}
-		var capt, lodash, num bool
-		for _, r := range ns {
-			uc := unicode.IsUpper(r) || unicode.IsTitle(r)
-			dg := unicode.IsDigit(r)
-
-			if r == '_' && buf.Len() == 0 && len(parts) == 0 {
-				lodash = true
-			}
-
-			if uc && !capt && buf.Len() > 0 && !lodash { // new upper letter
-				parts = append(parts, buf.String())
-				buf.Reset()
-			} else if dg && !num && buf.Len() > 0 && !lodash { // new digit
-				parts = append(parts, buf.String())
-				buf.Reset()
-			} else if !uc && capt && buf.Len() > 1 { // upper to lower
-				if ss := buf.String(); len(ss) > 1 &&
-					(len(ss) != 2 || ss[0] != '_') {
-					pr, _ := utf8.DecodeLastRuneInString(ss)
-					parts = append(parts, strings.TrimSuffix(ss, string(pr)))
-					buf.Reset()
-					buf.WriteRune(pr)
-				}
-			} else if !dg && num && buf.Len() >= 1 {
-				parts = append(parts, buf.String())
-				buf.Reset()
-			}
-
-			num = dg
-			capt = uc
-			buf.WriteRune(r)
-		}
-		parts = append(parts, buf.String())
-		return
-	}
-}
+func (n Name) Split() (parts []string) { return plugin.SplitName(string(n)) }
 
 // NameTransformer is a function that mutates a string. Many of the methods in
 // the standard strings package satisfy this signature.
@@ -0,0 +1,229 @@
+package protoutil
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/types/pluginpb"
+)
+
+// Template scopes. A template is rendered once per request (all), per proto
+// file, per service or per message, depending on the top-level directory it
+// is in.
+const (
+	ScopeAll     = "all"
+	ScopeFile    = "file"
+	ScopeService = "service"
+	ScopeMessage = "message"
+)
+
+// pluginTemplate is a template file of a template plugin.
+type pluginTemplate struct {
+	scope string
+	// name renders the output filename, relative to the plugin output.
+	name string
+	body string
+}
+
+// TemplatePlugin returns a code generator that renders the pongo2 templates
+// in dir, so plugins can be written without Go code.
+//
+// Templates are *.tmpl files under dir/all, dir/file, dir/service and
+// dir/message. The path of a template below its scope directory, without the
+// .tmpl suffix, is itself a template for the output filename, e.g.
+// service/{{ file.GeneratedFilenamePrefix }}_{{ service.GoName|lower }}.go.tmpl.
+// Output is trimmed; templates that render to blank output produce no file.
+//
+// Templates see:
+//
+//	files    []*protogen.File   the files to generate
+//	file     *protogen.File     file, service and message scopes
+//	service  *protogen.Service  service scope
+//	message  *protogen.Message  message scope, nested messages included
+//	params   map[string]string  plugin options not handled by protogen
+//
+// and the functions unExport, camelCase and snakeCase. Generated .go files
+// are formatted like those of any protogen plugin.
+func TemplatePlugin(dir string) func(req *pluginpb.CodeGeneratorRequest) (*pluginpb.CodeGeneratorResponse, error) {
+	return func(req *pluginpb.CodeGeneratorRequest) (*pluginpb.CodeGeneratorResponse, error) {
+		templates, err := loadPluginTemplates(dir)
+		if err != nil {
+			return nil, err
+		}
+
+		params := make(map[string]string)
+		opts := protogen.Options{
+			ParamFunc: func(name, value string) error {
+				params[name] = value
+				return nil
+			},
+		}
+		plugin, err := opts.New(withDefaultImportPaths(req))
+		if err != nil {
+			return nil, err
+		}
+		plugin.SupportedFeatures = uint64(pluginpb.CodeGeneratorResponse_FEATURE_PROTO3_OPTIONAL)
+
+		var files []*protogen.File
+		for _, f := range plugin.Files {
+			if f.Generate {
+				files = append(files, f)
+			}
+		}
+
+		for _, tpl := range templates {
+			var contexts []Context
+			switch tpl.scope {
+			case ScopeAll:
+				contexts = append(contexts, Context{})
+			case ScopeFile:
+				for _, f := range files {
+					contexts = append(contexts, Context{"file": f})
+				}
+			case ScopeService:
+				for _, f := range files {
+					for _, s := range f.Services {
+						contexts = append(contexts, Context{"file": f, "service": s})
+					}
+				}
+			case ScopeMessage:
+				for _, f := range files {
+					for _, m := range allMessages(f.Messages) {
+						contexts = append(contexts, Context{"file": f, "message": m})
+					}
+				}
+			}
+
+			for _, ctx := range contexts {
+				if err := renderPluginTemplate(plugin, tpl, files, params, ctx); err != nil {
+					return nil, err
+				}
+			}
+		}
+		return plugin.Response(), nil
+	}
+}
+
+func renderPluginTemplate(plugin *protogen.Plugin, tpl *pluginTemplate, files []*protogen.File, params map[string]string, ctx Context) error {
+	newContext := func() Context {
+		c := Context{
+			"files":     files,
+			"params":    params,
+			"camelCase": CamelCase,
+			"snakeCase": func(s string) string { return Name(s).LowerSnakeCase().String() },
+		}
+		for k, v := range ctx {
+			c[k] = v
+		}
+		return c
+	}
+
+	name, err := RenderTemplate(tpl.name, newContext())
+	if err != nil {
+		return fmt.Errorf("render filename of %s/%s.tmpl: %w", tpl.scope, tpl.name, err)
+	}
+	content, err := RenderTemplate(tpl.body, newContext())
+	if err != nil {
+		return fmt.Errorf("render %s/%s.tmpl: %w", tpl.scope, tpl.name, err)
+	}
+	content = strings.TrimSpace(content)
+	if content == "" {
+		return nil
+	}
+
+	var importPath protogen.GoImportPath
+	if f, ok := ctx["file"].(*protogen.File); ok {
+		importPath = f.GoImportPath
+	}
+	g := plugin.NewGeneratedFile(path.Clean(name), importPath)
+	_, err = g.Write([]byte(content + "\n"))
+	return err
+}
+
+// loadPluginTemplates reads the *.tmpl files of a template plugin directory.
+func loadPluginTemplates(dir string) ([]*pluginTemplate, error) {
+	var templates []*pluginTemplate
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || !strings.HasSuffix(p, ".tmpl") {
+			return err
+		}
+
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		scope, name, ok := strings.Cut(filepath.ToSlash(rel), "/")
+		switch {
+		case !ok:
+			return fmt.Errorf("template %s must be in an all, file, service or message directory", p)
+		case scope != ScopeAll && scope != ScopeFile && scope != ScopeService && scope != ScopeMessage:
+			return fmt.Errorf("template %s: unknown scope %q, expected all, file, service or message", p, scope)
+		}
+
+		body, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		templates = append(templates, &pluginTemplate{scope: scope, name: strings.TrimSuffix(name, ".tmpl"), body: string(body)})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("load templates from %s: %w", dir, err)
+	}
+	if len(templates) == 0 {
+		return nil, fmt.Errorf("no *.tmpl templates found in %s", dir)
+	}
+
+	sort.Slice(templates, func(i, j int) bool {
+		if templates[i].scope != templates[j].scope {
+			return templates[i].scope < templates[j].scope
+		}
+		return templates[i].name < templates[j].name
+	})
+	return templates, nil
+}
+
+// withDefaultImportPaths maps files without a go_package option to their
+// directory, so templates for other languages do not require go_package.
+func withDefaultImportPaths(req *pluginpb.CodeGeneratorRequest) *pluginpb.CodeGeneratorRequest {
+	var defaults []string
+	for _, f := range req.GetProtoFile() {
+		if f.GetOptions().GetGoPackage() == "" {
+			defaults = append(defaults, fmt.Sprintf("M%s=%s", f.GetName(), path.Dir(f.GetName())))
+		}
+	}
+	if len(defaults) == 0 {
+		return req
+	}
+
+	// Explicit M options come later and take precedence.
+	if req.GetParameter() != "" {
+		defaults = append(defaults, req.GetParameter())
+	}
+	param := strings.Join(defaults, ",")
+	return &pluginpb.CodeGeneratorRequest{
+		FileToGenerate:        req.FileToGenerate,
+		Parameter:             &param,
+		ProtoFile:             req.ProtoFile,
+		SourceFileDescriptors: req.SourceFileDescriptors,
+		CompilerVersion:       req.CompilerVersion,
+	}
+}
+
+func allMessages(msgs []*protogen.Message) []*protogen.Message {
+	var all []*protogen.Message
+	for _, m := range msgs {
+		if m.Desc.IsMapEntry() {
+			continue
+		}
+		all = append(all, m)
+		all = append(all, allMessages(m.Messages)...)
+	}
+	return all
+}
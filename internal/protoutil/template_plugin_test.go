@@ -0,0 +1,150 @@
+package protoutil
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bufbuild/protocompile"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/types/pluginpb"
+)
+
+func writeTemplates(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func templateRequest(t *testing.T, param string, sources map[string]string) *pluginpb.CodeGeneratorRequest {
+	t.Helper()
+	var names []string
+	for name := range sources {
+		names = append(names, name)
+	}
+
+	compiler := protocompile.Compiler{
+		Resolver: protocompile.WithStandardImports(&protocompile.SourceResolver{
+			Accessor: protocompile.SourceAccessorFromMap(sources),
+		}),
+		SourceInfoMode: protocompile.SourceInfoStandard,
+	}
+	files, err := compiler.Compile(context.Background(), names...)
+	if err != nil {
+		t.Fatalf("compile failed: %v", err)
+	}
+
+	req := &pluginpb.CodeGeneratorRequest{FileToGenerate: names, Parameter: proto.String(param)}
+	for _, fd := range files {
+		req.ProtoFile = append(req.ProtoFile, protodesc.ToFileDescriptorProto(fd))
+	}
+	return req
+}
+
+func TestTemplatePlugin(t *testing.T) {
+	dir := writeTemplates(t, map[string]string{
+		"all/index.md.tmpl": "{% for f in files %}- {{ f.Desc.Path() }}\n{% endfor %}",
+		"file/{{ file.GeneratedFilenamePrefix }}.consts.go.tmpl": `package {{ file.GoPackageName }}
+
+const FileName = "{{ file.Desc.Path() }}"`,
+		"service/{{ file.GeneratedFilenamePrefix }}_{{ snakeCase(service.GoName) }}.txt.tmpl": "{% for m in service.Methods %}{{ params.prefix }}{{ m.GoName }}({{ m.Input.GoIdent.GoName }})\n{% endfor %}",
+		"message/{{ message.Desc.FullName() }}.txt.tmpl":                                      "{% if message.Fields %}{% for f in message.Fields %}{{ f.Desc.JSONName() }} {% endfor %}{% endif %}",
+		"README.txt": "not a template",
+	})
+
+	req := templateRequest(t, "paths=source_relative,prefix=rpc.", map[string]string{
+		"acme/v1/greeter.proto": `syntax = "proto3";
+package acme.v1;
+option go_package = "example.com/acme/v1;acmev1";
+
+service GreeterService {
+  rpc SayHello(HelloRequest) returns (HelloReply);
+}
+
+message HelloRequest {
+  string user_name = 1;
+  message Empty {}
+}
+
+message HelloReply {
+  string message = 1;
+}
+`,
+	})
+
+	resp, err := TemplatePlugin(dir)(req)
+	if err != nil {
+		t.Fatalf("TemplatePlugin() error = %v", err)
+	}
+	if resp.Error != nil {
+		t.Fatalf("response error = %s", resp.GetError())
+	}
+
+	got := make(map[string]string)
+	for _, f := range resp.File {
+		got[f.GetName()] = f.GetContent()
+	}
+	want := map[string]string{
+		"index.md":                            "- acme/v1/greeter.proto\n",
+		"acme/v1/greeter.consts.go":           "package acmev1\n\nconst FileName = \"acme/v1/greeter.proto\"\n",
+		"acme/v1/greeter_greeter_service.txt": "rpc.SayHello(HelloRequest)\n",
+		"acme.v1.HelloRequest.txt":            "userName\n",
+		"acme.v1.HelloReply.txt":              "message\n",
+	}
+	for name, content := range want {
+		if got[name] != content {
+			t.Errorf("%s = %q, want %q", name, got[name], content)
+		}
+	}
+	if _, ok := got["acme.v1.HelloRequest.Empty.txt"]; ok {
+		t.Error("empty render should not produce a file")
+	}
+	if len(got) != len(want) {
+		t.Errorf("unexpected files: %q", got)
+	}
+}
+
+func TestTemplatePlugin_NoGoPackage(t *testing.T) {
+	dir := writeTemplates(t, map[string]string{
+		"file/{{ file.Desc.Path() }}.txt.tmpl": "{{ file.Desc.Package() }}",
+	})
+	req := templateRequest(t, "", map[string]string{
+		"acme/v1/a.proto": "syntax = \"proto3\";\npackage acme.v1;\nmessage A {}\n",
+	})
+
+	resp, err := TemplatePlugin(dir)(req)
+	if err != nil {
+		t.Fatalf("TemplatePlugin() error = %v", err)
+	}
+	if len(resp.File) != 1 || resp.File[0].GetName() != "acme/v1/a.proto.txt" || resp.File[0].GetContent() != "acme.v1\n" {
+		t.Errorf("unexpected files: %v", resp.File)
+	}
+}
+
+func TestTemplatePlugin_Errors(t *testing.T) {
+	req := templateRequest(t, "", map[string]string{
+		"a.proto": "syntax = \"proto3\";\noption go_package = \"example.com/a\";\nmessage A {}\n",
+	})
+
+	for name, files := range map[string]map[string]string{
+		"no templates":  {"README.md": "x"},
+		"root template": {"a.txt.tmpl": "x"},
+		"unknown scope": {"enum/a.txt.tmpl": "x"},
+		"bad template":  {"all/a.txt.tmpl": "{% for %}"},
+	} {
+		if _, err := TemplatePlugin(writeTemplates(t, files))(req); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
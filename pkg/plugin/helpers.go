@@ -0,0 +1,155 @@
+package plugin
+
+import (
+	"fmt"
+	"go/format"
+	"go/token"
+	"path"
+	"strings"
+	"unicode"
+
+	"google.golang.org/genproto/googleapis/api/annotations"
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// HTTPBinding is a google.api.http binding of a method.
+type HTTPBinding struct {
+	// Method is the HTTP method, e.g. GET, or the custom kind.
+	Method       string
+	Path         string
+	Body         string
+	ResponseBody string
+}
+
+// HTTPBindingOf returns the binding of a single rule, without its
+// additional bindings. Method and Path are empty if the rule has no pattern.
+func HTTPBindingOf(rule *annotations.HttpRule) HTTPBinding {
+	b := HTTPBinding{Body: rule.GetBody(), ResponseBody: rule.GetResponseBody()}
+	switch p := rule.GetPattern().(type) {
+	case *annotations.HttpRule_Get:
+		b.Method, b.Path = "GET", p.Get
+	case *annotations.HttpRule_Put:
+		b.Method, b.Path = "PUT", p.Put
+	case *annotations.HttpRule_Post:
+		b.Method, b.Path = "POST", p.Post
+	case *annotations.HttpRule_Delete:
+		b.Method, b.Path = "DELETE", p.Delete
+	case *annotations.HttpRule_Patch:
+		b.Method, b.Path = "PATCH", p.Patch
+	case *annotations.HttpRule_Custom:
+		b.Method, b.Path = p.Custom.GetKind(), p.Custom.GetPath()
+	}
+	return b
+}
+
+// HTTPRule returns the google.api.http option of m, or nil.
+func HTTPRule(m *protogen.Method) (*annotations.HttpRule, error) {
+	opts := m.Desc.Options()
+	if opts == nil || !proto.HasExtension(opts, annotations.E_Http) {
+		return nil, nil
+	}
+
+	ext := proto.GetExtension(opts, annotations.E_Http)
+	rule, ok := ext.(*annotations.HttpRule)
+	if !ok {
+		return nil, fmt.Errorf("%s: google.api.http is %T, want an HttpRule", m.Desc.FullName(), ext)
+	}
+	return rule, nil
+}
+
+// HTTPBindings returns the google.api.http binding of m followed by its
+// additional bindings. It returns nil if m has no google.api.http option.
+func HTTPBindings(m *protogen.Method) ([]HTTPBinding, error) {
+	rule, err := HTTPRule(m)
+	if err != nil || rule == nil {
+		return nil, err
+	}
+
+	var bindings []HTTPBinding
+	for _, r := range append([]*annotations.HttpRule{rule}, rule.GetAdditionalBindings()...) {
+		bindings = append(bindings, HTTPBindingOf(r))
+	}
+	return bindings, nil
+}
+
+// DefaultHTTPBinding is the binding protobuild uses for methods without a
+// google.api.http option: POST /{package}/{service}/{method} with the whole
+// request as body. The path is built from the proto names, as in the
+// OpenAPI documents protobuild generates.
+func DefaultHTTPBinding(m *protogen.Method) HTTPBinding {
+	pkg := string(m.Desc.ParentFile().Package())
+	path := fmt.Sprintf("%s/%s/%s", KebabCase(pkg), KebabCase(string(m.Parent.Desc.Name())), KebabCase(string(m.Desc.Name())))
+	return HTTPBinding{Method: "POST", Path: "/" + KebabCase(path), Body: "*"}
+}
+
+// GoZeroValue returns the Go zero value of the field as generated by
+// protoc-gen-go, e.g. 0, "" or nil.
+func GoZeroValue(field *protogen.Field) string {
+	fd := field.Desc
+	if fd.IsList() || fd.IsMap() {
+		return "nil"
+	}
+	// Fields with explicit presence are pointers, except in real oneofs.
+	if fd.HasPresence() && fd.Message() == nil && (field.Oneof == nil || field.Oneof.Desc.IsSynthetic()) {
+		return "nil"
+	}
+
+	switch fd.Kind() {
+	case protoreflect.BoolKind:
+		return "false"
+	case protoreflect.StringKind:
+		return `""`
+	case protoreflect.BytesKind, protoreflect.MessageKind, protoreflect.GroupKind:
+		return "nil"
+	default:
+		// Numbers and enums; an untyped constant is assignable to both.
+		return "0"
+	}
+}
+
+// GoPackage returns the Go import path and package name set by the
+// go_package option of fd. ok is false if the file has no go_package.
+func GoPackage(fd protoreflect.FileDescriptor) (importPath protogen.GoImportPath, name protogen.GoPackageName, ok bool) {
+	opts, _ := fd.Options().(*descriptorpb.FileOptions)
+	if opts.GetGoPackage() == "" {
+		return "", "", false
+	}
+	importPath, name = ParseGoPackage(opts.GetGoPackage())
+	return importPath, name, true
+}
+
+// ParseGoPackage interprets a go_package option: "example.com/foo;foopb"
+// is the import path example.com/foo with the package name foopb,
+// "example.com/foo" is named after its last element, and a plain "foopb" is
+// a package name without an import path.
+func ParseGoPackage(opt string) (importPath protogen.GoImportPath, name protogen.GoPackageName) {
+	if p, n, ok := strings.Cut(opt, ";"); ok {
+		return protogen.GoImportPath(p), protogen.GoPackageName(n)
+	}
+	if !strings.Contains(opt, "/") {
+		return "", protogen.GoPackageName(opt)
+	}
+	return protogen.GoImportPath(opt), goSanitized(path.Base(opt))
+}
+
+// goSanitized turns s into a valid Go package name, as protoc-gen-go does.
+func goSanitized(s string) protogen.GoPackageName {
+	s = strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return r
+		}
+		return '_'
+	}, s)
+	if r := []rune(s); len(r) == 0 || unicode.IsDigit(r[0]) || token.IsKeyword(s) {
+		s = "_" + s
+	}
+	return protogen.GoPackageName(s)
+}
+
+// FormatSource formats Go source code like gofmt.
+func FormatSource(src []byte) ([]byte, error) {
+	return format.Source(src)
+}
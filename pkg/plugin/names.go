@@ -0,0 +1,152 @@
+package plugin
+
+import (
+	"bytes"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// CamelCase converts a proto name to a Go style CamelCase name, e.g.
+// foo_bar to FooBar. It follows protoc-gen-go: an interior underscore
+// followed by a lower case letter is dropped and the letter upper-cased, a
+// leading underscore becomes X, so _my_field_name_2 becomes XMyFieldName_2.
+func CamelCase(s string) string {
+	if s == "" {
+		return ""
+	}
+	t := make([]byte, 0, 32)
+	i := 0
+	if s[0] == '_' {
+		// Need a capital letter; drop the '_'.
+		t = append(t, 'X')
+		i++
+	}
+	// Invariant: if the next letter is lower case, it must be converted
+	// to upper case.
+	// That is, we process a word at a time, where words are marked by _ or
+	// upper case letter. Digits are treated as words.
+	for ; i < len(s); i++ {
+		c := s[i]
+		if c == '_' && i+1 < len(s) && isASCIILower(s[i+1]) {
+			continue // Skip the underscore in s.
+		}
+		if isASCIIDigit(c) {
+			t = append(t, c)
+			continue
+		}
+		// Assume we have a letter now - if not, it's a bogus identifier.
+		// The next word is a sequence of characters that must start upper case.
+		if isASCIILower(c) {
+			c ^= ' ' // Make it a capital letter.
+		}
+		t = append(t, c) // Guaranteed not lower case.
+		// Accept lower case sequence that follows.
+		for i+1 < len(s) && isASCIILower(s[i+1]) {
+			i++
+			t = append(t, s[i])
+		}
+	}
+	return string(t)
+}
+
+// KebabCase converts a CamelCase name to kebab-case, e.g. FooBar to foo-bar.
+// Dots and underscores become dashes too.
+func KebabCase(s string) string {
+	s = strings.Trim(strings.TrimSpace(s), ".-_/")
+	buf := new(bytes.Buffer)
+	for i, r := range s {
+		if !unicode.IsUpper(r) {
+			buf.WriteRune(r)
+			continue
+		}
+
+		if i != 0 {
+			buf.WriteRune('-')
+		}
+		buf.WriteRune(unicode.ToLower(r))
+	}
+	return strings.NewReplacer(".", "-", "_", "-", "--", "-").Replace(buf.String())
+}
+
+// SnakeCase converts a name to snake_case, e.g. FooBar to foo_bar.
+func SnakeCase(s string) string {
+	parts := SplitName(s)
+	for i, p := range parts {
+		parts[i] = strings.ToLower(p)
+	}
+	return strings.Join(parts, "_")
+}
+
+// UnExport lower-cases the first letter of a Go identifier.
+func UnExport(s string) string {
+	if len(s) == 0 {
+		return ""
+	}
+	return strings.ToLower(s[:1]) + s[1:]
+}
+
+// SplitName breaks a name into its words. Precedence follows dot notation,
+// then underscores (excluding underscore prefixes), then camelcase. Numbers
+// are words of their own.
+func SplitName(s string) (parts []string) {
+	switch {
+	case s == "":
+		return []string{""}
+	case strings.LastIndex(s, ".") >= 0:
+		return strings.Split(s, ".")
+	case strings.LastIndex(s, "_") > 0: // leading underscore does not count
+		parts = strings.Split(s, "_")
+		if parts[0] == "" {
+			parts[1] = "_" + parts[1]
+			return parts[1:]
+		}
+		return
+	default: // camelCase
+		buf := &bytes.Buffer{}
+		var capt, lodash, num bool
+		for _, r := range s {
+			uc := unicode.IsUpper(r) || unicode.IsTitle(r)
+			dg := unicode.IsDigit(r)
+
+			if r == '_' && buf.Len() == 0 && len(parts) == 0 {
+				lodash = true
+			}
+
+			if uc && !capt && buf.Len() > 0 && !lodash { // new upper letter
+				parts = append(parts, buf.String())
+				buf.Reset()
+			} else if dg && !num && buf.Len() > 0 && !lodash { // new digit
+				parts = append(parts, buf.String())
+				buf.Reset()
+			} else if !uc && capt && buf.Len() > 1 { // upper to lower
+				if ss := buf.String(); len(ss) > 1 &&
+					(len(ss) != 2 || ss[0] != '_') {
+					pr, _ := utf8.DecodeLastRuneInString(ss)
+					parts = append(parts, strings.TrimSuffix(ss, string(pr)))
+					buf.Reset()
+					buf.WriteRune(pr)
+				}
+			} else if !dg && num && buf.Len() >= 1 {
+				parts = append(parts, buf.String())
+				buf.Reset()
+			}
+
+			num = dg
+			capt = uc
+			buf.WriteRune(r)
+		}
+		parts = append(parts, buf.String())
+		return
+	}
+}
+
+// Is c an ASCII lower-case letter?
+func isASCIILower(c byte) bool {
+	return 'a' <= c && c <= 'z'
+}
+
+// Is c an ASCII digit?
+func isASCIIDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
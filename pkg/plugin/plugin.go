@@ -0,0 +1,157 @@
+// Package plugin is an SDK for writing protoc-gen-* plugins on top of
+// protogen, with the helpers protobuild uses for its own generators.
+//
+// A minimal plugin:
+//
+//	func main() {
+//		plugin.Options{}.Run(func(p *plugin.Plugin) error {
+//			for _, f := range p.Files {
+//				if !f.Generate {
+//					continue
+//				}
+//				g := p.NewGeneratedFile(f.GeneratedFilenamePrefix+".hello.go", f.GoImportPath)
+//				g.P("package ", f.GoPackageName)
+//			}
+//			return nil
+//		})
+//	}
+//
+// Use the plugintest package to run a plugin against in-memory protos and
+// compare its output with golden files.
+package plugin
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/pluginpb"
+)
+
+// Options configure how a plugin is run.
+type Options struct {
+	// Flags, if set, receives the plugin parameters that protogen does not
+	// handle itself (paths, module, M...), and unknown parameters are an
+	// error. Otherwise they are collected into Plugin.Params.
+	Flags *flag.FlagSet
+
+	// SupportedFeatures are reported to protoc. Proto3 optional is always
+	// supported.
+	SupportedFeatures pluginpb.CodeGeneratorResponse_Feature
+
+	// ImportRewriteFunc rewrites the import paths of generated Go files.
+	ImportRewriteFunc func(protogen.GoImportPath) protogen.GoImportPath
+}
+
+// Plugin is a protogen plugin with its parsed parameters.
+type Plugin struct {
+	*protogen.Plugin
+
+	// Params are the plugin parameters not handled by protogen, when
+	// Options.Flags is not set.
+	Params Params
+}
+
+// Run reads a CodeGeneratorRequest from stdin, runs fn and writes the
+// response to stdout. It exits the process on failure, like protogen.
+func (o Options) Run(fn func(p *Plugin) error) {
+	if err := o.run(os.Stdin, os.Stdout, fn); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", filepath.Base(os.Args[0]), err)
+		os.Exit(1)
+	}
+}
+
+func (o Options) run(r io.Reader, w io.Writer, fn func(p *Plugin) error) error {
+	in, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	req := &pluginpb.CodeGeneratorRequest{}
+	if err := proto.Unmarshal(in, req); err != nil {
+		return err
+	}
+
+	resp, err := o.Generate(req, fn)
+	if err != nil {
+		return err
+	}
+	out, err := proto.Marshal(resp)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(out)
+	return err
+}
+
+// Generate runs fn on req. An error returned by fn is reported in the
+// response, as protoc expects; the returned error is for invalid requests.
+func (o Options) Generate(req *pluginpb.CodeGeneratorRequest, fn func(p *Plugin) error) (*pluginpb.CodeGeneratorResponse, error) {
+	params := make(Params)
+	opts := protogen.Options{
+		ParamFunc: func(name, value string) error {
+			params[name] = append(params[name], value)
+			return nil
+		},
+		ImportRewriteFunc: o.ImportRewriteFunc,
+	}
+	if o.Flags != nil {
+		opts.ParamFunc = o.Flags.Set
+	}
+
+	gen, err := opts.New(req)
+	if err != nil {
+		return nil, err
+	}
+	gen.SupportedFeatures = uint64(o.SupportedFeatures | pluginpb.CodeGeneratorResponse_FEATURE_PROTO3_OPTIONAL)
+
+	if err := fn(&Plugin{Plugin: gen, Params: params}); err != nil {
+		gen.Error(err)
+	}
+	return gen.Response(), nil
+}
+
+// Params are plugin parameters by name. A parameter may be repeated, e.g.
+// include=a,include=b.
+type Params map[string][]string
+
+// Has reports whether the parameter is set.
+func (p Params) Has(name string) bool {
+	_, ok := p[name]
+	return ok
+}
+
+// Get returns the last value of the parameter, or "".
+func (p Params) Get(name string) string {
+	values := p[name]
+	if len(values) == 0 {
+		return ""
+	}
+	return values[len(values)-1]
+}
+
+// Values returns all values of the parameter.
+func (p Params) Values(name string) []string {
+	return p[name]
+}
+
+// Bool returns the parameter as a bool. A parameter without a value, as in
+// "debug", is true; a missing parameter is false.
+func (p Params) Bool(name string) (bool, error) {
+	if !p.Has(name) {
+		return false, nil
+	}
+	value := p.Get(name)
+	if value == "" {
+		return true, nil
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("parameter %s: %w", name, err)
+	}
+	return b, nil
+}
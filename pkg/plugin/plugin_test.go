@@ -0,0 +1,231 @@
+package plugin_test
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"testing"
+
+	_ "google.golang.org/genproto/googleapis/api/annotations"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/pubgo/protobuild/pkg/plugin"
+	"github.com/pubgo/protobuild/pkg/plugin/plugintest"
+)
+
+var sources = map[string]string{
+	"acme/v1/greeter.proto": `syntax = "proto3";
+
+package acme.v1;
+
+import "google/api/annotations.proto";
+
+option go_package = "example.com/acme/v1;acmev1";
+
+service GreeterService {
+  rpc SayHello(HelloRequest) returns (HelloReply) {
+    option (google.api.http) = {
+      post: "/v1/hello"
+      body: "*"
+      additional_bindings {get: "/v1/hello/{user_name}"}
+    };
+  }
+  rpc SayBye(HelloRequest) returns (HelloReply);
+}
+
+message HelloRequest {
+  string user_name = 1;
+  optional int32 times = 2;
+  repeated string tags = 3;
+  bytes avatar = 4;
+  Mood mood = 5;
+  HelloReply last = 6;
+  oneof kind {
+    bool loud = 7;
+  }
+}
+
+message HelloReply {
+  string message = 1;
+}
+
+enum Mood {
+  MOOD_UNSPECIFIED = 0;
+}
+`,
+}
+
+// routes generates a route table per file, using the SDK helpers.
+func routes(p *plugin.Plugin) error {
+	prefix := p.Params.Get("prefix")
+	for _, f := range p.Files {
+		if !f.Generate || len(f.Services) == 0 {
+			continue
+		}
+
+		g := p.NewGeneratedFile(f.GeneratedFilenamePrefix+".routes.go", f.GoImportPath)
+		g.P("package ", f.GoPackageName)
+		g.P()
+		for _, svc := range f.Services {
+			var routes []plugin.HTTPBinding
+			for _, m := range svc.Methods {
+				bindings, err := plugin.HTTPBindings(m)
+				if err != nil {
+					return err
+				}
+				if bindings == nil {
+					bindings = []plugin.HTTPBinding{plugin.DefaultHTTPBinding(m)}
+				}
+				routes = append(routes, bindings...)
+			}
+
+			g.P("// ", svc.GoName, "Routes are the HTTP routes of ", svc.Desc.FullName(), ".")
+			g.P("var ", plugin.UnExport(svc.GoName), "Routes = []string{")
+			for _, r := range routes {
+				g.P(`"`, r.Method, " ", prefix, r.Path, `",`)
+			}
+			g.P("}")
+		}
+
+		for _, msg := range f.Messages {
+			g.P()
+			g.P("func reset", msg.GoIdent.GoName, "(m *", msg.GoIdent, ") {")
+			for _, field := range msg.Fields {
+				if field.Oneof != nil && !field.Oneof.Desc.IsSynthetic() {
+					continue
+				}
+				g.P("m.", field.GoName, " = ", plugin.GoZeroValue(field), " // ", plugin.SnakeCase(field.GoName))
+			}
+			g.P("}")
+		}
+	}
+	return nil
+}
+
+func TestRoutesGolden(t *testing.T) {
+	files := plugintest.Run(t, plugin.Options{}, routes, sources, "paths=source_relative,prefix=/api")
+	plugintest.Golden(t, "testdata/golden", files)
+}
+
+func TestGenerate_Errors(t *testing.T) {
+	req := plugintest.Request(t, sources, "")
+
+	resp, err := plugin.Options{}.Generate(req, func(p *plugin.Plugin) error {
+		return errors.New("boom")
+	})
+	if err != nil || resp.GetError() != "boom" {
+		t.Errorf("plugin errors should be reported in the response, got %v, %q", err, resp.GetError())
+	}
+
+	flags := flag.NewFlagSet("test", flag.ContinueOnError)
+	verbose := flags.Bool("verbose", false, "")
+	req.Parameter = proto.String("verbose=true")
+	if _, err := (plugin.Options{Flags: flags}).Generate(req, func(*plugin.Plugin) error { return nil }); err != nil || !*verbose {
+		t.Errorf("flags not parsed: %v", err)
+	}
+	req.Parameter = proto.String("unknown=1")
+	if _, err := (plugin.Options{Flags: flags}).Generate(req, func(*plugin.Plugin) error { return nil }); err == nil {
+		t.Error("expected error for unknown flag")
+	}
+}
+
+func TestParams(t *testing.T) {
+	req := plugintest.Request(t, sources, "include=a,include=b,debug,strict=false,level=x")
+	var params plugin.Params
+	resp, err := plugin.Options{}.Generate(req, func(p *plugin.Plugin) error {
+		params = p.Params
+		return nil
+	})
+	if err != nil || resp.Error != nil {
+		t.Fatalf("Generate() = %v, %v", err, resp.GetError())
+	}
+	if got := params.Values("include"); len(got) != 2 || params.Get("include") != "b" {
+		t.Errorf("include = %v", got)
+	}
+	if b, err := params.Bool("debug"); !b || err != nil {
+		t.Errorf("debug = %v, %v", b, err)
+	}
+	if b, err := params.Bool("strict"); b || err != nil {
+		t.Errorf("strict = %v, %v", b, err)
+	}
+	if b, err := params.Bool("missing"); b || err != nil {
+		t.Errorf("missing = %v, %v", b, err)
+	}
+	if _, err := params.Bool("level"); err == nil {
+		t.Error("expected error for non-bool level")
+	}
+}
+
+func TestHelpers(t *testing.T) {
+	if got := plugin.SnakeCase("UserName"); got != "user_name" {
+		t.Errorf("SnakeCase() = %q", got)
+	}
+	if got := plugin.KebabCase("UserName"); got != "user-name" {
+		t.Errorf("KebabCase() = %q", got)
+	}
+	if got := plugin.CamelCase("user_name"); got != "UserName" {
+		t.Errorf("CamelCase() = %q", got)
+	}
+	if got := plugin.UnExport("UserName"); got != "userName" {
+		t.Errorf("UnExport() = %q", got)
+	}
+
+	goPackages := map[string][2]string{
+		"example.com/acme/v1;acmev1": {"example.com/acme/v1", "acmev1"},
+		"example.com/acme/go-api":    {"example.com/acme/go-api", "go_api"},
+		"example.com/acme/v1":        {"example.com/acme/v1", "v1"},
+		"acmev1":                     {"", "acmev1"},
+	}
+	for opt, want := range goPackages {
+		if path, name := plugin.ParseGoPackage(opt); string(path) != want[0] || string(name) != want[1] {
+			t.Errorf("ParseGoPackage(%q) = %q, %q, want %q", opt, path, name, want)
+		}
+	}
+	if got, err := plugin.FormatSource([]byte("package a\nvar  x=1")); err != nil || !bytes.Equal(got, []byte("package a\n\nvar x = 1\n")) {
+		t.Errorf("FormatSource() = %q, %v", got, err)
+	}
+}
+
+func TestGoPackage(t *testing.T) {
+	req := plugintest.Request(t, sources, "")
+	resp, err := plugin.Options{}.Generate(req, func(p *plugin.Plugin) error {
+		path, name, ok := plugin.GoPackage(p.Files[len(p.Files)-1].Desc)
+		if !ok || path != "example.com/acme/v1" || name != "acmev1" {
+			t.Errorf("GoPackage() = %q, %q, %v", path, name, ok)
+		}
+		return nil
+	})
+	if err != nil || resp.Error != nil {
+		t.Fatalf("Generate() = %v, %v", err, resp.GetError())
+	}
+}
+
+func TestDefaultHTTPBinding(t *testing.T) {
+	req := plugintest.Request(t, map[string]string{"acme/v1/otp.proto": `syntax = "proto3";
+
+package acme.v1;
+
+option go_package = "example.com/acme/v1;acmev1";
+
+service OtpService {
+  rpc get2fa(Empty) returns (Empty);
+}
+
+message Empty {}
+`}, "")
+
+	var got plugin.HTTPBinding
+	resp, err := plugin.Options{}.Generate(req, func(p *plugin.Plugin) error {
+		got = plugin.DefaultHTTPBinding(p.Files[0].Services[0].Methods[0])
+		return nil
+	})
+	if err != nil || resp.Error != nil {
+		t.Fatalf("Generate() = %v, %v", err, resp.GetError())
+	}
+
+	// The path uses the proto name get2fa, not the Go name Get2Fa.
+	want := plugin.HTTPBinding{Method: "POST", Path: "/acme-v1/otp-service/get2fa", Body: "*"}
+	if got != want {
+		t.Errorf("DefaultHTTPBinding() = %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,175 @@
+// Package plugintest runs plugins built with the plugin package against
+// in-memory proto files and compares their output with golden files.
+//
+//	func TestGenerate(t *testing.T) {
+//		files := plugintest.Run(t, plugin.Options{}, generate, map[string]string{
+//			"acme/v1/a.proto": `syntax = "proto3"; ...`,
+//		}, "paths=source_relative")
+//		plugintest.Golden(t, "testdata/golden", files)
+//	}
+//
+// Run the tests with -plugintest.update to rewrite the golden files.
+package plugintest
+
+import (
+	"context"
+	"flag"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/bufbuild/protocompile"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/pluginpb"
+
+	"github.com/pubgo/protobuild/pkg/plugin"
+)
+
+var update = flag.Bool("plugintest.update", false, "rewrite plugintest golden files")
+
+// Request compiles sources, proto files by import path, into a
+// CodeGeneratorRequest that generates all of them. Imports that are not in
+// sources resolve to the standard imports and the descriptors registered in
+// the Go registry, so importing e.g. the Go package of google/api/annotations
+// makes google/api/annotations.proto available.
+func Request(t testing.TB, sources map[string]string, param string) *pluginpb.CodeGeneratorRequest {
+	t.Helper()
+
+	names := make([]string, 0, len(sources))
+	for name := range sources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	compiler := protocompile.Compiler{
+		Resolver: protocompile.CompositeResolver{
+			protocompile.WithStandardImports(&protocompile.SourceResolver{
+				Accessor: protocompile.SourceAccessorFromMap(sources),
+			}),
+			protocompile.ResolverFunc(func(path string) (protocompile.SearchResult, error) {
+				fd, err := protoregistry.GlobalFiles.FindFileByPath(path)
+				if err != nil {
+					return protocompile.SearchResult{}, err
+				}
+				return protocompile.SearchResult{Desc: fd}, nil
+			}),
+		},
+		SourceInfoMode: protocompile.SourceInfoStandard,
+	}
+	files, err := compiler.Compile(context.Background(), names...)
+	if err != nil {
+		t.Fatalf("plugintest: compile failed: %v", err)
+	}
+
+	req := &pluginpb.CodeGeneratorRequest{FileToGenerate: names}
+	if param != "" {
+		req.Parameter = proto.String(param)
+	}
+	seen := make(map[string]bool)
+	var add func(fd protoreflect.FileDescriptor)
+	add = func(fd protoreflect.FileDescriptor) {
+		if seen[fd.Path()] {
+			return
+		}
+		seen[fd.Path()] = true
+		for i := 0; i < fd.Imports().Len(); i++ {
+			add(fd.Imports().Get(i).FileDescriptor)
+		}
+		req.ProtoFile = append(req.ProtoFile, protodesc.ToFileDescriptorProto(fd))
+	}
+	for _, fd := range files {
+		add(fd)
+	}
+
+	// Round-trip through the wire format like protoc, so options are decoded
+	// with the registered extension types.
+	data, err := proto.Marshal(req)
+	if err != nil {
+		t.Fatalf("plugintest: %v", err)
+	}
+	req = &pluginpb.CodeGeneratorRequest{}
+	if err := proto.Unmarshal(data, req); err != nil {
+		t.Fatalf("plugintest: %v", err)
+	}
+	return req
+}
+
+// Run runs fn on sources with the plugin parameter param and returns the
+// generated files by name. It fails the test if the plugin reports an error.
+func Run(t testing.TB, opts plugin.Options, fn func(p *plugin.Plugin) error, sources map[string]string, param string) map[string]string {
+	t.Helper()
+
+	resp, err := opts.Generate(Request(t, sources, param), fn)
+	if err != nil {
+		t.Fatalf("plugintest: %v", err)
+	}
+	if resp.Error != nil {
+		t.Fatalf("plugintest: plugin failed: %s", resp.GetError())
+	}
+
+	files := make(map[string]string, len(resp.File))
+	for _, f := range resp.File {
+		files[f.GetName()] = f.GetContent()
+	}
+	return files
+}
+
+// Golden compares files with the golden files in dir, which mirrors the
+// generated file names. Missing and unexpected files are errors. With
+// -plugintest.update, dir is rewritten with files instead.
+func Golden(t testing.TB, dir string, files map[string]string) {
+	t.Helper()
+
+	if *update {
+		if err := os.RemoveAll(dir); err != nil {
+			t.Fatal(err)
+		}
+		for name, content := range files {
+			path := filepath.Join(dir, filepath.FromSlash(name))
+			if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return
+	}
+
+	golden := make(map[string]string)
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		golden[filepath.ToSlash(rel)] = string(data)
+		return err
+	})
+	if err != nil {
+		t.Fatalf("plugintest: read golden files: %v (run with -plugintest.update to create them)", err)
+	}
+
+	for name, want := range golden {
+		got, ok := files[name]
+		switch {
+		case !ok:
+			t.Errorf("plugintest: %s was not generated", name)
+		case got != want:
+			t.Errorf("plugintest: %s differs from golden file:\n--- got\n%s\n--- want\n%s", name, got, want)
+		}
+	}
+	for name := range files {
+		if _, ok := golden[name]; !ok {
+			t.Errorf("plugintest: unexpected file %s", name)
+		}
+	}
+}
@@ -0,0 +1,21 @@
+package acmev1
+
+// GreeterServiceRoutes are the HTTP routes of acme.v1.GreeterService.
+var greeterServiceRoutes = []string{
+	"POST /api/v1/hello",
+	"GET /api/v1/hello/{user_name}",
+	"POST /api/acme-v1/greeter-service/say-bye",
+}
+
+func resetHelloRequest(m *HelloRequest) {
+	m.UserName = "" // user_name
+	m.Times = nil   // times
+	m.Tags = nil    // tags
+	m.Avatar = nil  // avatar
+	m.Mood = 0      // mood
+	m.Last = nil    // last
+}
+
+func resetHelloReply(m *HelloReply) {
+	m.Message = "" // message
+}